@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
 
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -12,37 +15,52 @@ import (
 
 type PostgresAccountRepository struct {
 	log *zap.SugaredLogger
-	db  *sqlx.DB
+	db  sqlx.ExtContext
 }
 
 type DBAccount struct {
-	ID            int64   `db:"id"`
-	AccountNumber string  `db:"account_number"`
-	Balance       float32 `db:"balance"`
+	ID            int64         `db:"id"`
+	AccountNumber string        `db:"account_number"`
+	Kind          string        `db:"kind"`
+	Currency      string        `db:"currency"`
+	Balance       domain.Amount `db:"balance"`
+	Status        string        `db:"status"`
+	OpenedAt      time.Time     `db:"opened_at"`
+	FrozenAt      *time.Time    `db:"frozen_at"`
+	ClosedAt      *time.Time    `db:"closed_at"`
+	// OverdraftLimit is NULL for accounts without a limit.
+	OverdraftLimit  *domain.Amount `db:"overdraft_limit"`
+	OverdraftPolicy string         `db:"overdraft_policy"`
 }
 
-func NewPostgresAccountRepository(log *zap.SugaredLogger, db *sqlx.DB) *PostgresAccountRepository {
+// NewPostgresAccountRepository builds an account repository over db, which can
+// be either a connection pool or a running transaction.
+func NewPostgresAccountRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresAccountRepository {
 	return &PostgresAccountRepository{
 		log: log,
 		db:  db,
 	}
 }
 
+// Insert creates the account. When another transaction created the same
+// account number first it returns database.ErrDBDuplicatedEntry, without
+// failing the running transaction as a unique violation would.
 func (b PostgresAccountRepository) Insert(ctx context.Context, m *domain.Account) (*domain.Account, error) {
 	q := `
-	INSERT INTO accounts (account_number, balance)
-		 VALUES(:account_number, :balance)
+	INSERT INTO accounts (account_number, kind, currency, balance, status, opened_at, overdraft_limit, overdraft_policy)
+		 VALUES(:account_number, :kind, :currency, :balance, :status, :opened_at, :overdraft_limit, :overdraft_policy)
+		 ON CONFLICT (account_number) DO NOTHING
 		 RETURNING id;
 	`
 
-	rows, err := b.db.NamedQueryContext(ctx, q, fromAccountDomain(m))
-	if err != nil {
-		return nil, fmt.Errorf("failed to insert in accounts table: %w", err)
-	}
-	rows.Next()
-	if err = rows.Scan(&m.ID); err != nil {
+	var inserted DBAccount
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromAccountDomain(m), &inserted); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, fmt.Errorf("account number '%s': %w", m.AccountNumber, database.ErrDBDuplicatedEntry)
+		}
 		return nil, fmt.Errorf("failed to insert in accounts table: %w", err)
 	}
+	m.ID = inserted.ID
 
 	return m, nil
 }
@@ -51,43 +69,210 @@ func (b PostgresAccountRepository) Update(ctx context.Context, m *domain.Account
 	q := `
 	UPDATE accounts SET
 		account_number = :account_number,
-		balance = :balance
+		currency = :currency,
+		balance = :balance,
+		status = :status,
+		frozen_at = :frozen_at,
+		closed_at = :closed_at,
+		overdraft_limit = :overdraft_limit,
+		overdraft_policy = :overdraft_policy
 		WHERE id = :id;
 	`
 
-	_, err := b.db.NamedExecContext(ctx, q, fromAccountDomain(m))
-	if err != nil {
+	if err := database.NamedExecContext(ctx, b.log, b.db, q, fromAccountDomain(m)); err != nil {
 		return nil, fmt.Errorf("failed to update id %d in accounts table: %w", m.ID, err)
 	}
 
 	return m, nil
 }
 
-func (b PostgresAccountRepository) GetByAccountNumber(_ context.Context, accountNumber string) (*domain.Account, error) {
+// GetByAccountNumber returns the account, or database.ErrDBNotFound.
+func (b PostgresAccountRepository) GetByAccountNumber(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	return b.getByAccountNumber(ctx, "SELECT * FROM accounts WHERE account_number = $1 LIMIT 1", accountNumber)
+}
+
+// GetByAccountNumberForUpdate reads the account and locks its row until the
+// running transaction ends, so concurrent imports into the account wait for
+// each other instead of overwriting each other's balance.
+func (b PostgresAccountRepository) GetByAccountNumberForUpdate(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	return b.getByAccountNumber(ctx, "SELECT * FROM accounts WHERE account_number = $1 FOR UPDATE", accountNumber)
+}
+
+// List returns up to limit customer accounts by account number, starting
+// after the given one, so the last number of a page starts the next one.
+func (b PostgresAccountRepository) List(ctx context.Context, after string, limit int) ([]*domain.Account, error) {
+	q := `
+	SELECT * FROM accounts
+		WHERE kind = 'customer' AND account_number > :after
+		ORDER BY account_number
+		LIMIT :limit;
+	`
+
+	data := struct {
+		After string `db:"after"`
+		Limit int    `db:"limit"`
+	}{
+		After: after,
+		Limit: limit,
+	}
+
+	var entities []DBAccount
+	if err := database.NamedQuerySlice(ctx, b.log, b.db, q, data, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list accounts table: %w", err)
+	}
+
+	accounts := make([]*domain.Account, 0, len(entities))
+	for _, e := range entities {
+		accounts = append(accounts, e.toAccountDomain())
+	}
+
+	return accounts, nil
+}
+
+// GetByIDForUpdate reads the account with the id and locks it like
+// GetByAccountNumberForUpdate, or returns database.ErrDBNotFound.
+func (b PostgresAccountRepository) GetByIDForUpdate(ctx context.Context, id int64) (*domain.Account, error) {
+	var entities []DBAccount
+	if err := database.QuerySlice(ctx, b.log, b.db, "SELECT * FROM accounts WHERE id = $1 FOR UPDATE", []any{id}, &entities); err != nil {
+		return nil, fmt.Errorf("failed to select id %d from accounts table: %w", id, err)
+	}
+
+	if len(entities) == 0 {
+		return nil, database.ErrDBNotFound
+	}
+	return entities[0].toAccountDomain(), nil
+}
+
+func (b PostgresAccountRepository) getByAccountNumber(ctx context.Context, q string, accountNumber string) (*domain.Account, error) {
 	var entities []DBAccount
-	err := b.db.Select(&entities, "SELECT * FROM accounts WHERE account_number = $1 LIMIT 1", accountNumber)
+	err := sqlx.SelectContext(ctx, b.db, &entities, q, accountNumber)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select account_number '%s' from accounts table: %w", accountNumber, err)
 	}
 
 	if len(entities) == 0 {
-		return nil, fmt.Errorf("entity not found")
+		return nil, database.ErrDBNotFound
 	}
 	return entities[0].toAccountDomain(), nil
 }
 
+// computeBalancesQuery adds up the transactions of the customer accounts and
+// the ledger entries posted to them by hand, which have no transaction.
+const computeBalancesQuery = `
+	SELECT a.id, a.account_number, a.currency, a.balance,
+			COALESCE(t.total, 0) AS transactions,
+			COALESCE(l.total, 0) AS adjustments
+		FROM accounts a
+		LEFT JOIN (
+			SELECT account_id, SUM(amount) AS total
+				FROM transactions
+				GROUP BY account_id
+		) t ON t.account_id = a.id
+		LEFT JOIN (
+			SELECT le.account_id, SUM(le.amount) AS total
+				FROM ledger_entries le
+				JOIN journal_entries j ON j.id = le.journal_entry_id
+				WHERE j.transaction_id IS NULL
+				GROUP BY le.account_id
+		) l ON l.account_id = a.id
+		WHERE a.kind = 'customer'
+`
+
+type DBAccountBalance struct {
+	ID            int64         `db:"id"`
+	AccountNumber string        `db:"account_number"`
+	Currency      string        `db:"currency"`
+	Balance       domain.Amount `db:"balance"`
+	Transactions  domain.Amount `db:"transactions"`
+	Adjustments   domain.Amount `db:"adjustments"`
+}
+
+// ComputeBalances recomputes the balance of every customer account from its
+// transactions and manual journal entries.
+func (b PostgresAccountRepository) ComputeBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	var entities []DBAccountBalance
+	if err := database.QuerySlice(ctx, b.log, b.db, computeBalancesQuery+" ORDER BY a.account_number;", nil, &entities); err != nil {
+		return nil, fmt.Errorf("failed to compute balances from accounts table: %w", err)
+	}
+
+	balances := make([]domain.AccountBalance, 0, len(entities))
+	for _, e := range entities {
+		balances = append(balances, e.toAccountBalanceDomain())
+	}
+
+	return balances, nil
+}
+
+// ComputeBalance recomputes the balance of one customer account, or returns
+// database.ErrDBNotFound.
+func (b PostgresAccountRepository) ComputeBalance(ctx context.Context, accountID int64) (*domain.AccountBalance, error) {
+	var entities []DBAccountBalance
+	if err := database.QuerySlice(ctx, b.log, b.db, computeBalancesQuery+" AND a.id = $1;", []any{accountID}, &entities); err != nil {
+		return nil, fmt.Errorf("failed to compute balance of id %d from accounts table: %w", accountID, err)
+	}
+
+	if len(entities) == 0 {
+		return nil, database.ErrDBNotFound
+	}
+	balance := entities[0].toAccountBalanceDomain()
+	return &balance, nil
+}
+
+func (db DBAccountBalance) toAccountBalanceDomain() domain.AccountBalance {
+	return domain.AccountBalance{
+		AccountID:     db.ID,
+		AccountNumber: db.AccountNumber,
+		Currency:      db.Currency,
+		Stored:        db.Balance,
+		Transactions:  db.Transactions,
+		Adjustments:   db.Adjustments,
+	}
+}
+
 func fromAccountDomain(model *domain.Account) *DBAccount {
+	kind := model.Kind
+	if kind == "" {
+		kind = domain.AccountCustomer
+	}
+	status := model.Status
+	if status == "" {
+		status = domain.AccountActive
+	}
+	policy := model.OverdraftPolicy
+	if policy == "" {
+		policy = domain.OverdraftRejectFile
+	}
+	openedAt := model.OpenedAt
+	if openedAt.IsZero() {
+		openedAt = time.Now()
+	}
 	return &DBAccount{
-		ID:            model.ID,
-		AccountNumber: model.AccountNumber,
-		Balance:       model.Balance,
+		ID:              model.ID,
+		AccountNumber:   model.AccountNumber,
+		Kind:            string(kind),
+		Currency:        model.Currency,
+		Balance:         model.Balance,
+		Status:          string(status),
+		OpenedAt:        openedAt,
+		FrozenAt:        model.FrozenAt,
+		ClosedAt:        model.ClosedAt,
+		OverdraftLimit:  model.OverdraftLimit,
+		OverdraftPolicy: string(policy),
 	}
 }
 
 func (db DBAccount) toAccountDomain() *domain.Account {
 	return &domain.Account{
-		ID:            db.ID,
-		AccountNumber: db.AccountNumber,
-		Balance:       db.Balance,
+		ID:              db.ID,
+		AccountNumber:   db.AccountNumber,
+		Kind:            domain.AccountKind(db.Kind),
+		Currency:        db.Currency,
+		Balance:         db.Balance,
+		Status:          domain.AccountStatus(db.Status),
+		OpenedAt:        db.OpenedAt,
+		FrozenAt:        db.FrozenAt,
+		ClosedAt:        db.ClosedAt,
+		OverdraftLimit:  db.OverdraftLimit,
+		OverdraftPolicy: domain.OverdraftPolicy(db.OverdraftPolicy),
 	}
 }
@@ -0,0 +1,136 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/repositories"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/fedepezzola/transactions/foundation/logger"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB connects to the migrated database described by the
+// TRANSACTIONS_DB_* variables, defaulting to the one of docker-compose.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	env := func(name string, def string) string {
+		if v := os.Getenv("TRANSACTIONS_DB_" + name); v != "" {
+			return v
+		}
+		return def
+	}
+
+	db, err := database.Open(database.Config{
+		User:       env("USER", "postgres"),
+		Password:   env("PASSWORD", "postgres"),
+		Host:       env("HOST", "localhost"),
+		Name:       env("NAME", "transactions"),
+		DisableTLS: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, database.StatusCheck(ctx, db))
+
+	return db
+}
+
+func Test_ProcessTransactionsStream_concurrent_imports_keep_both_files(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountNumber := fmt.Sprintf("it-%d", time.Now().UnixNano())
+
+	transactionService := service.NewTransactionService(log,
+		repositories.NewPostgresTransactor(log, db),
+		repositories.NewPostgresAccountRepository(log, db),
+		repositories.NewPostgresTransactionRepository(log, db),
+		repositories.NewNotificationsRepository(log, nil),
+	)
+
+	// Every import finds the account missing, so they also race to create it.
+	files := make([]string, 4)
+	want := domain.Amount(0)
+	for f := range files {
+		var data strings.Builder
+		data.WriteString("Id,Date,Transaction\n")
+		for i := range 200 {
+			amount := domain.MustParseAmount(fmt.Sprintf("%d.%02d", f+1, i%100))
+			fmt.Fprintf(&data, "%d-%d,2024-07-15,%s\n", f, i, amount)
+			want += amount
+		}
+		files[f] = data.String()
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(files))
+	for f, data := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader, err := service.NewCSVStatementReader(strings.NewReader(data), service.DefaultCSVFormat())
+			if err != nil {
+				errs[f] = err
+				return
+			}
+			_, errs[f] = transactionService.ProcessTransactionsStream(ctx, accountNumber, reader, service.ImportOptions{BatchSize: 50})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	account, err := repositories.NewPostgresAccountRepository(log, db).GetByAccountNumber(ctx, accountNumber)
+	require.NoError(t, err)
+	assert.Equal(t, want, account.Balance)
+
+	var stored domain.Amount
+	require.NoError(t, db.GetContext(ctx, &stored, "SELECT SUM(amount) FROM transactions WHERE account_id = $1", account.ID))
+	assert.Equal(t, want, stored)
+}
+
+func Test_PostgresAccountRepository_round_trips_the_kind(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountRepository := repositories.NewPostgresAccountRepository(log, db)
+
+	for _, kind := range []domain.AccountKind{"", domain.AccountCustomer, domain.AccountClearing} {
+		accountNumber := fmt.Sprintf("it-%s-%d", kind, time.Now().UnixNano())
+		inserted, err := accountRepository.Insert(ctx, &domain.Account{AccountNumber: accountNumber, Kind: kind, Currency: "USD"})
+		require.NoError(t, err)
+
+		want := kind
+		if want == "" {
+			want = domain.AccountCustomer
+		}
+		got, err := accountRepository.GetByAccountNumber(ctx, accountNumber)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.Kind)
+		assert.Equal(t, want == domain.AccountClearing, got.IsClearing())
+
+		got, err = accountRepository.GetByIDForUpdate(ctx, inserted.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.Kind)
+	}
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type PostgresFXRateRepository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+type DBFXRate struct {
+	ID            int64       `db:"id"`
+	RateDate      time.Time   `db:"rate_date"`
+	BaseCurrency  string      `db:"base_currency"`
+	QuoteCurrency string      `db:"quote_currency"`
+	Rate          domain.Rate `db:"rate"`
+}
+
+// NewPostgresFXRateRepository builds an FX rate repository over db, which can
+// be either a connection pool or a running transaction.
+func NewPostgresFXRateRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresFXRateRepository {
+	return &PostgresFXRateRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Upsert stores the rate of the currency pair on its date, replacing the one
+// loaded before.
+func (b PostgresFXRateRepository) Upsert(ctx context.Context, m *domain.FXRate) (*domain.FXRate, error) {
+	q := `
+	INSERT INTO fx_rates (rate_date, base_currency, quote_currency, rate)
+		 VALUES(:rate_date, :base_currency, :quote_currency, :rate)
+		 ON CONFLICT (base_currency, quote_currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate
+		 RETURNING id;
+	`
+
+	var inserted DBFXRate
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromFXRateDomain(m), &inserted); err != nil {
+		return nil, fmt.Errorf("failed to upsert in fx_rates table: %w", err)
+	}
+	m.ID = inserted.ID
+
+	return m, nil
+}
+
+// GetRate returns the latest rate of the currency pair on or before date, so
+// days without a quote, like weekends, use the last one published, or
+// database.ErrDBNotFound.
+func (b PostgresFXRateRepository) GetRate(ctx context.Context, baseCurrency string, quoteCurrency string, date time.Time) (*domain.FXRate, error) {
+	q := `
+	SELECT * FROM fx_rates
+		WHERE base_currency = :base_currency AND quote_currency = :quote_currency AND rate_date <= :rate_date
+		ORDER BY rate_date DESC
+		LIMIT 1;
+	`
+
+	data := DBFXRate{
+		RateDate:      date,
+		BaseCurrency:  baseCurrency,
+		QuoteCurrency: quoteCurrency,
+	}
+
+	var entity DBFXRate
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, data, &entity); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to select %s/%s rate from fx_rates table: %w", baseCurrency, quoteCurrency, err)
+	}
+
+	return entity.toFXRateDomain(), nil
+}
+
+func fromFXRateDomain(model *domain.FXRate) *DBFXRate {
+	return &DBFXRate{
+		ID:            model.ID,
+		RateDate:      model.Date,
+		BaseCurrency:  model.BaseCurrency,
+		QuoteCurrency: model.QuoteCurrency,
+		Rate:          model.Rate,
+	}
+}
+
+func (db DBFXRate) toFXRateDomain() *domain.FXRate {
+	return &domain.FXRate{
+		ID:            db.ID,
+		Date:          db.RateDate,
+		BaseCurrency:  db.BaseCurrency,
+		QuoteCurrency: db.QuoteCurrency,
+		Rate:          db.Rate,
+	}
+}
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// importJobColumns are the columns of a job read back with its status, which
+// leave out the content.
+const importJobColumns = `id, account_number, format, on_error, dry_run, require_balance_match, currency,
+	status, attempts, rows_processed, rows_rejected, stats, error, created_at, started_at, finished_at`
+
+type PostgresImportJobRepository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+type DBImportJob struct {
+	ID                  int64      `db:"id"`
+	AccountNumber       string     `db:"account_number"`
+	Format              string     `db:"format"`
+	OnError             string     `db:"on_error"`
+	DryRun              bool       `db:"dry_run"`
+	RequireBalanceMatch bool       `db:"require_balance_match"`
+	Currency            string     `db:"currency"`
+	Content             []byte     `db:"content"`
+	Status              string     `db:"status"`
+	Attempts            int        `db:"attempts"`
+	RowsProcessed       int        `db:"rows_processed"`
+	RowsRejected        int        `db:"rows_rejected"`
+	Stats               *string    `db:"stats"`
+	Error               *string    `db:"error"`
+	CreatedAt           time.Time  `db:"created_at"`
+	StartedAt           *time.Time `db:"started_at"`
+	FinishedAt          *time.Time `db:"finished_at"`
+}
+
+// NewPostgresImportJobRepository builds an import job repository over db,
+// which can be either a connection pool or a running transaction. Leases are
+// measured with the clock of the database, so workers on different hosts
+// agree on when they expire.
+func NewPostgresImportJobRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresImportJobRepository {
+	return &PostgresImportJobRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (b PostgresImportJobRepository) Insert(ctx context.Context, m *domain.ImportJob) (*domain.ImportJob, error) {
+	q := `
+	INSERT INTO import_jobs (account_number, format, on_error, dry_run, require_balance_match, currency, content, status, created_at)
+		 VALUES(:account_number, :format, :on_error, :dry_run, :require_balance_match, :currency, :content, :status, :created_at)
+		 RETURNING id;
+	`
+
+	var inserted DBImportJob
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromImportJobDomain(m), &inserted); err != nil {
+		return nil, fmt.Errorf("failed to insert in import_jobs table: %w", err)
+	}
+	m.ID = inserted.ID
+
+	return m, nil
+}
+
+func (b PostgresImportJobRepository) GetByID(ctx context.Context, id int64) (*domain.ImportJob, error) {
+	q := `SELECT ` + importJobColumns + ` FROM import_jobs WHERE id = :id;`
+
+	data := struct {
+		ID int64 `db:"id"`
+	}{
+		ID: id,
+	}
+
+	var entity DBImportJob
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, data, &entity); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to select id %d from import_jobs table: %w", id, err)
+	}
+
+	return entity.toImportJobDomain(), nil
+}
+
+// Claim skips the jobs being claimed by other workers at the same time, so
+// each job goes to one worker only.
+func (b PostgresImportJobRepository) Claim(ctx context.Context, lease time.Duration) (*domain.ImportJob, error) {
+	q := `
+	UPDATE import_jobs SET
+		status = 'running',
+		attempts = attempts + 1,
+		started_at = now(),
+		lease_expires_at = now() + make_interval(secs => :lease_seconds)
+		WHERE id = (
+			SELECT id FROM import_jobs
+				WHERE status = 'queued' OR (status = 'running' AND lease_expires_at < now())
+				ORDER BY id
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + importJobColumns + `, content;
+	`
+
+	data := struct {
+		LeaseSeconds float64 `db:"lease_seconds"`
+	}{
+		LeaseSeconds: lease.Seconds(),
+	}
+
+	var entity DBImportJob
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, data, &entity); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to claim from import_jobs table: %w", err)
+	}
+
+	return entity.toImportJobDomain(), nil
+}
+
+func (b PostgresImportJobRepository) Heartbeat(ctx context.Context, m *domain.ImportJob, lease time.Duration) error {
+	q := `
+	UPDATE import_jobs SET
+		lease_expires_at = now() + make_interval(secs => :lease_seconds),
+		rows_processed = :rows_processed,
+		rows_rejected = :rows_rejected
+		WHERE id = :id AND attempts = :attempts AND status = 'running'
+		RETURNING id;
+	`
+
+	data := struct {
+		DBImportJob
+		LeaseSeconds float64 `db:"lease_seconds"`
+	}{
+		DBImportJob:  *fromImportJobDomain(m),
+		LeaseSeconds: lease.Seconds(),
+	}
+
+	return b.updateRunning(ctx, q, data, m.ID)
+}
+
+func (b PostgresImportJobRepository) Finish(ctx context.Context, m *domain.ImportJob) error {
+	q := `
+	UPDATE import_jobs SET
+		status = :status,
+		rows_processed = :rows_processed,
+		rows_rejected = :rows_rejected,
+		stats = :stats,
+		error = :error,
+		content = NULL,
+		lease_expires_at = NULL,
+		finished_at = now()
+		WHERE id = :id AND attempts = :attempts AND status = 'running'
+		RETURNING id;
+	`
+
+	return b.updateRunning(ctx, q, fromImportJobDomain(m), m.ID)
+}
+
+// Release does not count the attempt, as the job did not fail.
+func (b PostgresImportJobRepository) Release(ctx context.Context, m *domain.ImportJob) error {
+	q := `
+	UPDATE import_jobs SET
+		status = 'queued',
+		attempts = attempts - 1,
+		lease_expires_at = NULL
+		WHERE id = :id AND attempts = :attempts AND status = 'running'
+		RETURNING id;
+	`
+
+	return b.updateRunning(ctx, q, fromImportJobDomain(m), m.ID)
+}
+
+// updateRunning runs an update of a job held by an attempt, which returns the
+// id of the job when the attempt still holds it.
+func (b PostgresImportJobRepository) updateRunning(ctx context.Context, q string, data any, id int64) error {
+	var updated DBImportJob
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, data, &updated); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to update id %d in import_jobs table: %w", id, err)
+	}
+
+	return nil
+}
+
+func fromImportJobDomain(model *domain.ImportJob) *DBImportJob {
+	var stats, jobErr *string
+	if len(model.Stats) > 0 {
+		s := string(model.Stats)
+		stats = &s
+	}
+	if model.Error != "" {
+		jobErr = &model.Error
+	}
+	return &DBImportJob{
+		ID:                  model.ID,
+		AccountNumber:       model.AccountNumber,
+		Format:              model.Format,
+		OnError:             model.OnError,
+		DryRun:              model.DryRun,
+		RequireBalanceMatch: model.RequireBalanceMatch,
+		Currency:            model.Currency,
+		Content:             model.Content,
+		Status:              string(model.Status),
+		Attempts:            model.Attempts,
+		RowsProcessed:       model.RowsProcessed,
+		RowsRejected:        model.RowsRejected,
+		Stats:               stats,
+		Error:               jobErr,
+		CreatedAt:           model.CreatedAt,
+		StartedAt:           model.StartedAt,
+		FinishedAt:          model.FinishedAt,
+	}
+}
+
+func (db DBImportJob) toImportJobDomain() *domain.ImportJob {
+	m := &domain.ImportJob{
+		ID:                  db.ID,
+		AccountNumber:       db.AccountNumber,
+		Format:              db.Format,
+		OnError:             db.OnError,
+		DryRun:              db.DryRun,
+		RequireBalanceMatch: db.RequireBalanceMatch,
+		Currency:            db.Currency,
+		Content:             db.Content,
+		Status:              domain.ImportJobStatus(db.Status),
+		Attempts:            db.Attempts,
+		RowsProcessed:       db.RowsProcessed,
+		RowsRejected:        db.RowsRejected,
+		CreatedAt:           db.CreatedAt,
+		StartedAt:           db.StartedAt,
+		FinishedAt:          db.FinishedAt,
+	}
+	if db.Stats != nil {
+		m.Stats = []byte(*db.Stats)
+	}
+	if db.Error != nil {
+		m.Error = *db.Error
+	}
+	return m
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/repositories"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/fedepezzola/transactions/foundation/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ImportJobService_resumes_jobs_of_stopped_workers(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountNumber := fmt.Sprintf("it-%d", time.Now().UnixNano())
+	lease := time.Second
+
+	jobRepository := repositories.NewPostgresImportJobRepository(log, db)
+	jobService := service.NewImportJobService(log, jobRepository,
+		service.NewTransactionService(log,
+			repositories.NewPostgresTransactor(log, db),
+			repositories.NewPostgresAccountRepository(log, db),
+			repositories.NewPostgresTransactionRepository(log, db),
+			repositories.NewNotificationsRepository(log, nil),
+		),
+		service.ImportJobConfig{CSVFormat: service.DefaultCSVFormat(), Lease: lease},
+	)
+
+	job, err := jobService.Submit(ctx, &domain.ImportJob{
+		AccountNumber: accountNumber,
+		Content:       []byte("Id,Date,Transaction\n0,2024-07-15,60.5\n1,2024-07-28,-10.3\n2,2024-08-02,-20.46\n"),
+	})
+	require.NoError(t, err)
+
+	// A worker claims the job and stops without a word. Jobs left behind by
+	// earlier runs are claimed first.
+	var stopped *domain.ImportJob
+	for stopped == nil || stopped.ID != job.ID {
+		stopped, err = jobRepository.Claim(ctx, lease)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, domain.ImportJobRunning, stopped.Status)
+	assert.Equal(t, 1, stopped.Attempts)
+	assert.NotEmpty(t, stopped.Content)
+
+	// Nobody else takes it while its lease lasts.
+	_, err = jobRepository.Claim(ctx, lease)
+	assert.ErrorIs(t, err, database.ErrDBNotFound)
+
+	// Once it expires a worker takes the job over, after the ones left
+	// behind.
+	time.Sleep(lease + 100*time.Millisecond)
+	got, err := jobService.Get(ctx, job.ID)
+	for err == nil && !got.Finished() {
+		var ran bool
+		ran, err = jobService.RunNext(ctx)
+		require.NoError(t, err)
+		require.True(t, ran)
+		got, err = jobService.Get(ctx, job.ID)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportJobSucceeded, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, 3, got.RowsProcessed)
+	assert.Empty(t, got.Content)
+	assert.NotNil(t, got.FinishedAt)
+	stats, err := service.ImportJobStats(got)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("29.74"), stats.FileBalance)
+
+	// The stopped worker can not touch the job anymore.
+	stopped.Status = domain.ImportJobFailed
+	assert.ErrorIs(t, jobRepository.Heartbeat(ctx, stopped, lease), database.ErrDBNotFound)
+	assert.ErrorIs(t, jobRepository.Finish(ctx, stopped), database.ErrDBNotFound)
+	assert.ErrorIs(t, jobRepository.Release(ctx, stopped), database.ErrDBNotFound)
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type PostgresIngestionBatchRepository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+type DBIngestionBatch struct {
+	ID          int64      `db:"id"`
+	AccountID   int64      `db:"account_id"`
+	ContentHash *string    `db:"content_hash"`
+	RowCount    int        `db:"row_count"`
+	StartedAt   time.Time  `db:"started_at"`
+	FinishedAt  *time.Time `db:"finished_at"`
+}
+
+func NewPostgresIngestionBatchRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresIngestionBatchRepository {
+	return &PostgresIngestionBatchRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (b PostgresIngestionBatchRepository) Insert(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+	q := `
+	INSERT INTO ingestion_batches (account_id, content_hash, row_count, started_at, finished_at)
+		 VALUES(:account_id, :content_hash, :row_count, :started_at, :finished_at)
+		 RETURNING id;
+	`
+
+	var inserted DBIngestionBatch
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromIngestionBatchDomain(m), &inserted); err != nil {
+		return nil, fmt.Errorf("failed to insert in ingestion_batches table: %w", err)
+	}
+	m.ID = inserted.ID
+
+	return m, nil
+}
+
+// Update stores the batch. A content hash already imported for the account
+// returns database.ErrDBDuplicatedEntry.
+func (b PostgresIngestionBatchRepository) Update(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+	q := `
+	UPDATE ingestion_batches SET
+		content_hash = :content_hash,
+		row_count = :row_count,
+		finished_at = :finished_at
+		WHERE id = :id;
+	`
+
+	if err := database.NamedExecContext(ctx, b.log, b.db, q, fromIngestionBatchDomain(m)); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update id %d in ingestion_batches table: %w", m.ID, err)
+	}
+
+	return m, nil
+}
+
+// GetByContentHash returns the finished batch that imported the content into
+// the account, or database.ErrDBNotFound.
+func (b PostgresIngestionBatchRepository) GetByContentHash(ctx context.Context, accountID int64, contentHash string) (*domain.IngestionBatch, error) {
+	q := `
+	SELECT * FROM ingestion_batches
+		WHERE account_id = :account_id AND content_hash = :content_hash
+		LIMIT 1;
+	`
+
+	data := struct {
+		AccountID   int64  `db:"account_id"`
+		ContentHash string `db:"content_hash"`
+	}{
+		AccountID:   accountID,
+		ContentHash: contentHash,
+	}
+
+	var entity DBIngestionBatch
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, data, &entity); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to select content_hash '%s' from ingestion_batches table: %w", contentHash, err)
+	}
+
+	return entity.toIngestionBatchDomain(), nil
+}
+
+func fromIngestionBatchDomain(model *domain.IngestionBatch) *DBIngestionBatch {
+	var hash *string
+	if model.ContentHash != "" {
+		hash = &model.ContentHash
+	}
+	return &DBIngestionBatch{
+		ID:          model.ID,
+		AccountID:   model.AccountID,
+		ContentHash: hash,
+		RowCount:    model.RowCount,
+		StartedAt:   model.StartedAt,
+		FinishedAt:  model.FinishedAt,
+	}
+}
+
+func (db DBIngestionBatch) toIngestionBatchDomain() *domain.IngestionBatch {
+	var hash string
+	if db.ContentHash != nil {
+		hash = *db.ContentHash
+	}
+	return &domain.IngestionBatch{
+		ID:          db.ID,
+		AccountID:   db.AccountID,
+		ContentHash: hash,
+		RowCount:    db.RowCount,
+		StartedAt:   db.StartedAt,
+		FinishedAt:  db.FinishedAt,
+	}
+}
+
+// ClearReversedContentHashes sets the content hash of the batches whose
+// transactions are all reversed to NULL, so the same content can be imported
+// into the account again.
+func (b PostgresIngestionBatchRepository) ClearReversedContentHashes(ctx context.Context, batchIDs []int64) error {
+	q := `
+	UPDATE ingestion_batches b SET content_hash = NULL
+		WHERE b.id = ANY($1) AND NOT EXISTS (
+			SELECT 1 FROM transactions t WHERE t.batch_id = b.id AND NOT t.reversed
+		);
+	`
+
+	if err := database.ExecContext(ctx, b.log, b.db, q, []any{pq.Array(batchIDs)}); err != nil {
+		return fmt.Errorf("failed to clear content_hash in ingestion_batches table: %w", err)
+	}
+
+	return nil
+}
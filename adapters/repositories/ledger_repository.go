@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type PostgresLedgerRepository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+type DBJournalEntry struct {
+	ID            int64     `db:"id"`
+	BatchID       *int64    `db:"batch_id"`
+	TransactionID *int64    `db:"transaction_id"`
+	Description   string    `db:"description"`
+	PostedAt      time.Time `db:"posted_at"`
+}
+
+type DBLedgerTotal struct {
+	Currency string        `db:"currency"`
+	Entries  int           `db:"entries"`
+	Total    domain.Amount `db:"total"`
+}
+
+// journalEntryColumns and ledgerEntryColumns are the columns written for every
+// journal entry and ledger entry.
+var (
+	journalEntryColumns = []string{"id", "batch_id", "transaction_id", "description", "posted_at"}
+	ledgerEntryColumns  = []string{"id", "journal_entry_id", "account_id", "amount", "currency"}
+)
+
+// maxBulkPostRows keeps the inserts of a post under the parameter limit of
+// Postgres, counting the lines of every journal entry.
+var maxBulkPostRows = database.MaxParams / len(ledgerEntryColumns)
+
+// NewPostgresLedgerRepository builds a ledger repository over db, which can be
+// either a connection pool or a running transaction.
+func NewPostgresLedgerRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresLedgerRepository {
+	return &PostgresLedgerRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Post stores the journal entries and their lines with multi-row inserts and
+// sets their ids, which are reserved before the inserts. Entries are not
+// validated here.
+func (b PostgresLedgerRepository) Post(ctx context.Context, entries []*domain.JournalEntry) error {
+	for start := 0; start < len(entries); {
+		end, lines := start, 0
+		for end < len(entries) && (end == start || lines+len(entries[end].Lines) <= maxBulkPostRows) {
+			lines += len(entries[end].Lines)
+			end++
+		}
+		if err := b.post(ctx, entries[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+
+	return nil
+}
+
+func (b PostgresLedgerRepository) post(ctx context.Context, entries []*domain.JournalEntry) error {
+	ids, err := nextIDs(ctx, b.log, b.db, "journal_entries", len(entries))
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]any, len(entries))
+	var lines []*domain.LedgerEntry
+	for i, e := range entries {
+		e.ID = ids[i]
+		j := fromJournalEntryDomain(e)
+		rows[i] = []any{j.ID, j.BatchID, j.TransactionID, j.Description, j.PostedAt}
+		for k := range e.Lines {
+			l := &e.Lines[k]
+			l.JournalEntryID = e.ID
+			lines = append(lines, l)
+		}
+	}
+
+	q, args := multiRowInsert("journal_entries", journalEntryColumns, rows)
+	if err := database.ExecContext(ctx, b.log, b.db, q+";", args); err != nil {
+		return fmt.Errorf("failed to insert %d rows in journal_entries table: %w", len(entries), err)
+	}
+
+	lineIDs, err := nextIDs(ctx, b.log, b.db, "ledger_entries", len(lines))
+	if err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for i, l := range lines {
+		l.ID = lineIDs[i]
+		rows = append(rows, []any{l.ID, l.JournalEntryID, l.AccountID, l.Amount, l.Currency})
+	}
+
+	q, args = multiRowInsert("ledger_entries", ledgerEntryColumns, rows)
+	if err := database.ExecContext(ctx, b.log, b.db, q+";", args); err != nil {
+		return fmt.Errorf("failed to insert %d rows in ledger_entries table: %w", len(lines), err)
+	}
+
+	return nil
+}
+
+// Totals adds up the ledger entries of every currency.
+func (b PostgresLedgerRepository) Totals(ctx context.Context) ([]domain.LedgerTotal, error) {
+	q := `
+	SELECT currency, COUNT(*) AS entries, SUM(amount) AS total
+		FROM ledger_entries
+		GROUP BY currency
+		ORDER BY currency;
+	`
+
+	var entities []DBLedgerTotal
+	if err := database.QuerySlice(ctx, b.log, b.db, q, nil, &entities); err != nil {
+		return nil, fmt.Errorf("failed to sum ledger_entries table: %w", err)
+	}
+
+	totals := make([]domain.LedgerTotal, 0, len(entities))
+	for _, e := range entities {
+		totals = append(totals, domain.LedgerTotal{
+			Currency: e.Currency,
+			Entries:  e.Entries,
+			Total:    e.Total,
+		})
+	}
+
+	return totals, nil
+}
+
+// UnbalancedEntries returns the ids of the journal entries whose lines do not
+// add up to zero in some currency.
+func (b PostgresLedgerRepository) UnbalancedEntries(ctx context.Context) ([]int64, error) {
+	q := `
+	SELECT DISTINCT journal_entry_id
+		FROM ledger_entries
+		GROUP BY journal_entry_id, currency
+		HAVING SUM(amount) <> 0
+		ORDER BY journal_entry_id;
+	`
+
+	var ids []int64
+	if err := database.QuerySlice(ctx, b.log, b.db, q, nil, &ids); err != nil {
+		return nil, fmt.Errorf("failed to check ledger_entries table: %w", err)
+	}
+
+	return ids, nil
+}
+
+func fromJournalEntryDomain(model *domain.JournalEntry) *DBJournalEntry {
+	var batchID, transactionID *int64
+	if model.BatchID != 0 {
+		batchID = &model.BatchID
+	}
+	if model.TransactionID != 0 {
+		transactionID = &model.TransactionID
+	}
+	return &DBJournalEntry{
+		ID:            model.ID,
+		BatchID:       batchID,
+		TransactionID: transactionID,
+		Description:   model.Description,
+		PostedAt:      model.PostedAt,
+	}
+}
@@ -9,6 +9,9 @@ import (
 
 type NotificationsListener interface {
 	Update(data any) error
+	// Alert is called for events that need attention, like an overdraft
+	// limit breach.
+	Alert(data any) error
 }
 
 type NotificationsRepository struct {
@@ -24,9 +27,22 @@ func NewNotificationsRepository(log *zap.SugaredLogger, listeners []Notification
 }
 
 func (n *NotificationsRepository) Notify(data any) error {
+	return n.each(func(listener NotificationsListener) error {
+		return listener.Update(data)
+	})
+}
+
+// Alert hands data to the Alert method of every listener.
+func (n *NotificationsRepository) Alert(data any) error {
+	return n.each(func(listener NotificationsListener) error {
+		return listener.Alert(data)
+	})
+}
+
+func (n *NotificationsRepository) each(fn func(NotificationsListener) error) error {
 	var wrappedErrors error = nil
 	for _, listener := range n.listeners {
-		err := listener.Update(data)
+		err := fn(listener)
 		if err != nil {
 			wrappedErrors = errors.Join(wrappedErrors, err)
 		}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type PostgresReversalRepository struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+type DBReversal struct {
+	ID            int64     `db:"id"`
+	BatchID       *int64    `db:"batch_id"`
+	TransactionID *int64    `db:"transaction_id"`
+	Reason        string    `db:"reason"`
+	ReversedBy    string    `db:"reversed_by"`
+	ReversedAt    time.Time `db:"reversed_at"`
+}
+
+// NewPostgresReversalRepository builds a reversal repository over db, which
+// can be either a connection pool or a running transaction.
+func NewPostgresReversalRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresReversalRepository {
+	return &PostgresReversalRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Insert records the reversal. Its offsetting transactions are stored apart.
+func (b PostgresReversalRepository) Insert(ctx context.Context, m *domain.Reversal) (*domain.Reversal, error) {
+	q := `
+	INSERT INTO reversals (batch_id, transaction_id, reason, reversed_by, reversed_at)
+		 VALUES(:batch_id, :transaction_id, :reason, :reversed_by, :reversed_at)
+		 RETURNING id;
+	`
+
+	var inserted DBReversal
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromReversalDomain(m), &inserted); err != nil {
+		return nil, fmt.Errorf("failed to insert in reversals table: %w", err)
+	}
+	m.ID = inserted.ID
+
+	return m, nil
+}
+
+func fromReversalDomain(model *domain.Reversal) *DBReversal {
+	return &DBReversal{
+		ID:            model.ID,
+		BatchID:       nullID(model.BatchID),
+		TransactionID: nullID(model.TransactionID),
+		Reason:        model.Reason,
+		ReversedBy:    model.ReversedBy,
+		ReversedAt:    model.ReversedAt,
+	}
+}
@@ -2,31 +2,65 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 type PostgresTransactionRepository struct {
 	log *zap.SugaredLogger
-	db  *sqlx.DB
+	db  sqlx.ExtContext
 }
 
 type DBTransaction struct {
-	ID                  int64     `db:"id"`
-	AccountID           int64     `db:"account_id"`
-	ProcessingTimestamp time.Time `db:"processing_timestamp"`
-	FileTransactionID   int       `db:"file_transaction_id"`
-	TrasactionMonth     int       `db:"transaction_month"`
-	TrasactionDay       int       `db:"transaction_day"`
-	Amount              float32   `db:"amount"`
+	ID                    int64         `db:"id"`
+	AccountID             int64         `db:"account_id"`
+	BatchID               *int64        `db:"batch_id"`
+	ProcessingTimestamp   time.Time     `db:"processing_timestamp"`
+	FileTransactionID     string        `db:"file_transaction_id"`
+	TransactionDate       time.Time     `db:"transaction_date"`
+	ValueDate             *time.Time    `db:"value_date"`
+	Amount                domain.Amount `db:"amount"`
+	Currency              string        `db:"currency"`
+	OriginalAmount        domain.Amount `db:"original_amount"`
+	FXRate                domain.Rate   `db:"fx_rate"`
+	Description           *string       `db:"description"`
+	Counterparty          *string       `db:"counterparty"`
+	Reference             *string       `db:"reference"`
+	Type                  *string       `db:"type"`
+	ReversalID            *int64        `db:"reversal_id"`
+	ReversesTransactionID *int64        `db:"reverses_transaction_id"`
+	// Reversed is set by MarkReversed, and only read to leave reversed
+	// transactions out of the rows already imported.
+	Reversed bool `db:"reversed"`
+	// ReversedByID is only selected by the queries that join the offsetting
+	// transaction.
+	ReversedByID *int64 `db:"reversed_by_id"`
 }
 
-func NewPostgresTransactionRepository(log *zap.SugaredLogger, db *sqlx.DB) *PostgresTransactionRepository {
+// transactionColumns are the columns written for every transaction, in the
+// order of DBTransaction.values.
+var transactionColumns = []string{
+	"id", "account_id", "batch_id", "processing_timestamp", "file_transaction_id", "transaction_date", "value_date",
+	"amount", "currency", "original_amount", "fx_rate", "description", "counterparty", "reference", "type",
+	"reversal_id", "reverses_transaction_id",
+}
+
+// maxBulkInsertRows keeps a bulk insert under the parameter limit of Postgres.
+var maxBulkInsertRows = database.MaxParams / len(transactionColumns)
+
+// NewPostgresTransactionRepository builds a transaction repository over db,
+// which can be either a connection pool or a running transaction.
+func NewPostgresTransactionRepository(log *zap.SugaredLogger, db sqlx.ExtContext) *PostgresTransactionRepository {
 	return &PostgresTransactionRepository{
 		log: log,
 		db:  db,
@@ -35,30 +69,349 @@ func NewPostgresTransactionRepository(log *zap.SugaredLogger, db *sqlx.DB) *Post
 
 func (b PostgresTransactionRepository) Insert(ctx context.Context, m *domain.Transaction) (*domain.Transaction, error) {
 	q := `
-	INSERT INTO transactions (account_id, processing_timestamp, file_transaction_id, transaction_month, transaction_day, amount)
-		 VALUES(:account_id, :processing_timestamp, :file_transaction_id, :transaction_month, :transaction_day, :amount)
+	INSERT INTO transactions (account_id, batch_id, processing_timestamp, file_transaction_id, transaction_date, value_date, amount, currency, original_amount, fx_rate, description, counterparty, reference, type, reversal_id, reverses_transaction_id)
+		 VALUES(:account_id, :batch_id, :processing_timestamp, :file_transaction_id, :transaction_date, :value_date, :amount, :currency, :original_amount, :fx_rate, :description, :counterparty, :reference, :type, :reversal_id, :reverses_transaction_id)
 		 RETURNING id;
 	`
 
-	rows, err := b.db.NamedQueryContext(ctx, q, fromTransactionDomain(m))
-	if err != nil {
+	var inserted DBTransaction
+	if err := database.NamedQueryStruct(ctx, b.log, b.db, q, fromTransactionDomain(m), &inserted); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to insert in transactions table: %w", err)
 	}
-	rows.Next()
-	if err = rows.Scan(&m.ID); err != nil {
-		return nil, fmt.Errorf("failed to insert in accounts table: %w", err)
-	}
+	m.ID = inserted.ID
 
 	return m, nil
 }
 
+// BulkInsert stores txns with multi-row inserts, splitting them when they do
+// not fit in one query, and sets their ids. The ids are reserved before the
+// insert, as Postgres does not promise to return them in the order of the
+// VALUES list.
+func (b PostgresTransactionRepository) BulkInsert(ctx context.Context, txns []*domain.Transaction) error {
+	for start := 0; start < len(txns); start += maxBulkInsertRows {
+		end := min(start+maxBulkInsertRows, len(txns))
+		if err := b.bulkInsert(ctx, txns[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b PostgresTransactionRepository) bulkInsert(ctx context.Context, txns []*domain.Transaction) error {
+	ids, err := nextIDs(ctx, b.log, b.db, "transactions", len(txns))
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]any, len(txns))
+	for i, txn := range txns {
+		t := fromTransactionDomain(txn)
+		t.ID = ids[i]
+		rows[i] = t.values()
+	}
+	q, args := multiRowInsert("transactions", transactionColumns, rows)
+
+	if err := database.ExecContext(ctx, b.log, b.db, q+";", args); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return err
+		}
+		return fmt.Errorf("failed to insert %d rows in transactions table: %w", len(txns), err)
+	}
+	for i, txn := range txns {
+		txn.ID = ids[i]
+	}
+
+	return nil
+}
+
+// selectWithReversal reads transactions with the id of the transaction that
+// offsets them, if any.
+const selectWithReversal = `
+	SELECT t.*, r.id AS reversed_by_id
+		FROM transactions t
+		LEFT JOIN transactions r ON r.reverses_transaction_id = t.id
+`
+
+// GetByID returns the transaction, or database.ErrDBNotFound.
+func (b PostgresTransactionRepository) GetByID(ctx context.Context, id int64) (*domain.Transaction, error) {
+	var entities []DBTransaction
+	if err := database.QuerySlice(ctx, b.log, b.db, selectWithReversal+" WHERE t.id = $1;", []any{id}, &entities); err != nil {
+		return nil, fmt.Errorf("failed to select id %d from transactions table: %w", id, err)
+	}
+
+	if len(entities) == 0 {
+		return nil, database.ErrDBNotFound
+	}
+	return entities[0].toTransactionDomain(), nil
+}
+
+// GetByBatchID returns the transactions imported by an ingestion batch in
+// file order.
+func (b PostgresTransactionRepository) GetByBatchID(ctx context.Context, batchID int64) ([]*domain.Transaction, error) {
+	q := selectWithReversal + `
+		WHERE t.batch_id = :batch_id
+		ORDER BY t.id;
+	`
+
+	data := struct {
+		BatchID int64 `db:"batch_id"`
+	}{
+		BatchID: batchID,
+	}
+
+	var entities []DBTransaction
+	if err := database.NamedQuerySlice(ctx, b.log, b.db, q, data, &entities); err != nil {
+		return nil, fmt.Errorf("failed to select batch_id %d from transactions table: %w", batchID, err)
+	}
+
+	txns := make([]*domain.Transaction, 0, len(entities))
+	for _, e := range entities {
+		txns = append(txns, e.toTransactionDomain())
+	}
+
+	return txns, nil
+}
+
+// ImportedFileIDs returns the ones of fileTransactionIDs already imported into
+// the account. Offsetting transactions keep the file id of the one they
+// reverse, so they are left out, and so are reversed transactions.
+func (b PostgresTransactionRepository) ImportedFileIDs(ctx context.Context, accountID int64, fileTransactionIDs []string) ([]string, error) {
+	q := `
+	SELECT file_transaction_id FROM transactions
+		WHERE account_id = $1 AND file_transaction_id = ANY($2) AND reverses_transaction_id IS NULL AND NOT reversed;
+	`
+
+	var ids []string
+	if err := database.QuerySlice(ctx, b.log, b.db, q, []any{accountID, pq.Array(fileTransactionIDs)}, &ids); err != nil {
+		return nil, fmt.Errorf("failed to select file_transaction_id of account_id %d from transactions table: %w", accountID, err)
+	}
+
+	return ids, nil
+}
+
+// MarkReversed flags the transactions as reversed once their offsetting
+// transactions are stored.
+func (b PostgresTransactionRepository) MarkReversed(ctx context.Context, ids []int64) error {
+	q := `UPDATE transactions SET reversed = true WHERE id = ANY($1);`
+
+	if err := database.ExecContext(ctx, b.log, b.db, q, []any{pq.Array(ids)}); err != nil {
+		return fmt.Errorf("failed to update reversed in transactions table: %w", err)
+	}
+
+	return nil
+}
+
+// ListByAccount returns a page of the transactions of the account matching
+// the query, which must be validated, using the cursor of the previous page
+// as a keyset so deep pages cost the same as the first one.
+func (b PostgresTransactionRepository) ListByAccount(ctx context.Context, query domain.TransactionQuery) (*domain.TransactionPage, error) {
+	var q strings.Builder
+	q.WriteString(selectWithReversal + " WHERE t.account_id = :account_id")
+	data := map[string]any{
+		"account_id": query.AccountID,
+		// One more row tells whether there is a next page.
+		"limit": query.Limit + 1,
+	}
+
+	if query.From != nil {
+		q.WriteString(" AND t.transaction_date >= :from")
+		data["from"] = query.From.Format(time.DateOnly)
+	}
+	if query.To != nil {
+		q.WriteString(" AND t.transaction_date <= :to")
+		data["to"] = query.To.Format(time.DateOnly)
+	}
+	if query.MinAmount != nil {
+		q.WriteString(" AND t.amount >= :min_amount")
+		data["min_amount"] = *query.MinAmount
+	}
+	if query.MaxAmount != nil {
+		q.WriteString(" AND t.amount <= :max_amount")
+		data["max_amount"] = *query.MaxAmount
+	}
+	switch query.Sign {
+	case domain.SignPositive:
+		q.WriteString(" AND t.amount >= 0")
+	case domain.SignNegative:
+		q.WriteString(" AND t.amount < 0")
+	}
+	if query.BatchID != 0 {
+		q.WriteString(" AND t.batch_id = :batch_id")
+		data["batch_id"] = query.BatchID
+	}
+
+	column, direction, op := "t.transaction_date", "ASC", ">"
+	if query.SortBy == domain.SortByAmount {
+		column = "t.amount"
+	}
+	if query.Descending {
+		direction, op = "DESC", "<"
+	}
+	if query.After != nil {
+		q.WriteString(" AND (" + column + " " + op + " :after_key OR (" + column + " = :after_key AND t.id " + op + " :after_id))")
+		data["after_id"] = query.After.ID
+		if query.SortBy == domain.SortByAmount {
+			data["after_key"] = query.After.Amount
+		} else {
+			data["after_key"] = query.After.Date.Format(time.DateOnly)
+		}
+	}
+	q.WriteString(" ORDER BY " + column + " " + direction + ", t.id " + direction + " LIMIT :limit;")
+
+	var entities []DBTransaction
+	if err := database.NamedQuerySlice(ctx, b.log, b.db, q.String(), data, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list account_id %d from transactions table: %w", query.AccountID, err)
+	}
+
+	page := &domain.TransactionPage{
+		Transactions: make([]*domain.Transaction, 0, min(len(entities), query.Limit)),
+	}
+	for i, e := range entities {
+		if i == query.Limit {
+			page.Next = domain.CursorOf(page.Transactions[i-1])
+			break
+		}
+		page.Transactions = append(page.Transactions, e.toTransactionDomain())
+	}
+
+	return page, nil
+}
+
 func fromTransactionDomain(model *domain.Transaction) *DBTransaction {
+	var batchID *int64
+	if model.BatchID != 0 {
+		batchID = &model.BatchID
+	}
 	return &DBTransaction{
-		FileTransactionID:   model.FileTransactionID,
-		AccountID:           model.AccountID,
-		ProcessingTimestamp: model.ProcessingTimestamp,
-		TrasactionMonth:     model.Month,
-		TrasactionDay:       model.Day,
-		Amount:              model.Amount,
+		BatchID:               batchID,
+		ReversalID:            nullID(model.ReversalID),
+		ReversesTransactionID: nullID(model.ReversesTransactionID),
+		FileTransactionID:     model.FileTransactionID,
+		AccountID:             model.AccountID,
+		ProcessingTimestamp:   model.ProcessingTimestamp,
+		TransactionDate:       model.Date,
+		ValueDate:             model.ValueDate,
+		Amount:                model.Amount,
+		Currency:              model.Currency,
+		OriginalAmount:        model.OriginalAmount,
+		FXRate:                model.FXRate,
+		Description:           nullString(model.Description),
+		Counterparty:          nullString(model.Counterparty),
+		Reference:             nullString(model.Reference),
+		Type:                  nullString(model.Type),
+	}
+}
+
+// values returns the fields written for the transaction, in the order of
+// transactionColumns.
+func (db DBTransaction) values() []any {
+	return []any{
+		db.ID, db.AccountID, db.BatchID, db.ProcessingTimestamp, db.FileTransactionID, db.TransactionDate, db.ValueDate,
+		db.Amount, db.Currency, db.OriginalAmount, db.FXRate, db.Description, db.Counterparty, db.Reference, db.Type,
+		db.ReversalID, db.ReversesTransactionID,
+	}
+}
+
+func (db DBTransaction) toTransactionDomain() *domain.Transaction {
+	var batchID int64
+	if db.BatchID != nil {
+		batchID = *db.BatchID
+	}
+	return &domain.Transaction{
+		ID:                    db.ID,
+		AccountID:             db.AccountID,
+		BatchID:               batchID,
+		ProcessingTimestamp:   db.ProcessingTimestamp,
+		FileTransactionID:     db.FileTransactionID,
+		Date:                  db.TransactionDate,
+		ValueDate:             db.ValueDate,
+		Amount:                db.Amount,
+		Currency:              db.Currency,
+		OriginalAmount:        db.OriginalAmount,
+		FXRate:                db.FXRate,
+		Description:           stringValue(db.Description),
+		Counterparty:          stringValue(db.Counterparty),
+		Reference:             stringValue(db.Reference),
+		Type:                  stringValue(db.Type),
+		ReversalID:            idValue(db.ReversalID),
+		ReversesTransactionID: idValue(db.ReversesTransactionID),
+		ReversedByID:          idValue(db.ReversedByID),
+	}
+}
+
+// multiRowInsert builds an INSERT of rows into table with numbered parameters,
+// returning the query and its arguments.
+func multiRowInsert(table string, columns []string, rows [][]any) (string, []any) {
+	var q strings.Builder
+	q.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+
+	args := make([]any, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteString("(")
+		for j := range row {
+			if j > 0 {
+				q.WriteString(", ")
+			}
+			q.WriteString("$" + strconv.Itoa(len(args)+j+1))
+		}
+		q.WriteString(")")
+		args = append(args, row...)
+	}
+
+	return q.String(), args
+}
+
+// nextIDs reserves n ids from the sequence of the id column of table, so rows
+// inserted together know their ids without matching the rows returned.
+func nextIDs(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, table string, n int) ([]int64, error) {
+	q := "SELECT nextval(pg_get_serial_sequence('" + table + "', 'id')) FROM generate_series(1, $1);"
+
+	var ids []int64
+	if err := database.QuerySlice(ctx, log, db, q, []any{n}, &ids); err != nil {
+		return nil, fmt.Errorf("failed to reserve %d ids of %s table: %w", n, table, err)
+	}
+	if len(ids) != n {
+		return nil, fmt.Errorf("failed to reserve ids of %s table: %d ids returned for %d rows", table, len(ids), n)
+	}
+
+	return ids, nil
+}
+
+// nullID stores zero ids as NULL.
+func nullID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// idValue reads a nullable id column as zero when NULL.
+func idValue(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// stringValue reads a nullable column as an empty string when NULL.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
 	}
+	return *s
 }
@@ -0,0 +1,194 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/repositories"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ListByAccount_pages_follow_the_sort_order(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountNumber := fmt.Sprintf("it-%d", time.Now().UnixNano())
+
+	transactionService := service.NewTransactionService(log,
+		repositories.NewPostgresTransactor(log, db),
+		repositories.NewPostgresAccountRepository(log, db),
+		repositories.NewPostgresTransactionRepository(log, db),
+		repositories.NewNotificationsRepository(log, nil),
+	)
+
+	// Few distinct dates and amounts, so pages have to break ties by id.
+	var data strings.Builder
+	data.WriteString("Id,Date,Transaction\n")
+	for i := range 30 {
+		fmt.Fprintf(&data, "%d,2024-07-%02d,%d.50\n", i, 1+i%4, i%5-2)
+	}
+	reader, err := service.NewCSVStatementReader(strings.NewReader(data.String()), service.DefaultCSVFormat())
+	require.NoError(t, err)
+	_, err = transactionService.ProcessTransactionsStream(ctx, accountNumber, reader, service.ImportOptions{})
+	require.NoError(t, err)
+
+	for _, query := range []domain.TransactionQuery{
+		{SortBy: domain.SortByDate},
+		{SortBy: domain.SortByAmount, Descending: true},
+		{SortBy: domain.SortByAmount, Sign: domain.SignNegative},
+	} {
+		all, err := transactionService.ListTransactions(ctx, accountNumber, query)
+		require.NoError(t, err)
+		assert.Nil(t, all.Next)
+
+		var paged []*domain.Transaction
+		query.Limit = 7
+		for {
+			page, err := transactionService.ListTransactions(ctx, accountNumber, query)
+			require.NoError(t, err)
+			paged = append(paged, page.Transactions...)
+			if page.Next == nil {
+				break
+			}
+			query.After = page.Next
+		}
+		assert.Equal(t, all.Transactions, paged, "%+v", query)
+	}
+}
+
+func Test_BulkInsert_sets_the_id_of_every_row(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	account, err := repositories.NewPostgresAccountRepository(log, db).Insert(ctx, &domain.Account{
+		AccountNumber: fmt.Sprintf("it-%d", time.Now().UnixNano()),
+		Currency:      "USD",
+	})
+	require.NoError(t, err)
+
+	txns := make([]*domain.Transaction, 50)
+	for i := range txns {
+		amount := domain.MustParseAmount(fmt.Sprintf("%d.25", i))
+		txns[i] = &domain.Transaction{
+			AccountID:           account.ID,
+			ProcessingTimestamp: time.Now(),
+			FileTransactionID:   fmt.Sprintf("row-%d", i),
+			Date:                time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+			Amount:              amount,
+			Currency:            "USD",
+			OriginalAmount:      amount,
+			FXRate:              domain.MustParseRate("1"),
+		}
+	}
+
+	transactionRepository := repositories.NewPostgresTransactionRepository(log, db)
+	require.NoError(t, transactionRepository.BulkInsert(ctx, txns))
+
+	for _, txn := range txns {
+		got, err := transactionRepository.GetByID(ctx, txn.ID)
+		require.NoError(t, err)
+		assert.Equal(t, txn.FileTransactionID, got.FileTransactionID)
+		assert.Equal(t, txn.Amount, got.Amount)
+	}
+}
+
+func Test_ProcessTransactionsStream_imports_rows_once_across_files(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountNumber := fmt.Sprintf("it-%d", time.Now().UnixNano())
+
+	transactionService := service.NewTransactionService(log,
+		repositories.NewPostgresTransactor(log, db),
+		repositories.NewPostgresAccountRepository(log, db),
+		repositories.NewPostgresTransactionRepository(log, db),
+		repositories.NewNotificationsRepository(log, nil),
+	)
+	importFile := func(data string) (*service.AccountStats, error) {
+		reader, err := service.NewCSVStatementReader(strings.NewReader(data), service.DefaultCSVFormat())
+		require.NoError(t, err)
+		return transactionService.ProcessTransactionsStream(ctx, accountNumber, reader, service.ImportOptions{})
+	}
+
+	first := "Id,Date,Transaction\n0,2024-07-15,60.5\n1,2024-07-28,-10.3\n"
+	stats, err := importFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+
+	// The next statement overlaps the first one.
+	stats, err = importFile("Id,Date,Transaction\n1,2024-07-28,-10.3\n2,2024-08-02,-20.46\n")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TransactionCount)
+	assert.Equal(t, []string{"1"}, stats.DuplicateIDs)
+	assert.Equal(t, domain.MustParseAmount("29.74"), stats.Balance)
+
+	_, err = importFile(first)
+	assert.ErrorIs(t, err, service.ErrFileAlreadyImported)
+
+	// Files without rows do not match each other.
+	for range 2 {
+		_, err = importFile("Id,Date,Transaction\n")
+		require.NoError(t, err)
+	}
+}
+
+func Test_ReverseBatch_lets_the_file_be_imported_again(t *testing.T) {
+	db := openTestDB(t)
+	log, err := logger.New("TRANSACTIONS-TEST")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountNumber := fmt.Sprintf("it-%d", time.Now().UnixNano())
+
+	transactor := repositories.NewPostgresTransactor(log, db)
+	transactionRepository := repositories.NewPostgresTransactionRepository(log, db)
+	transactionService := service.NewTransactionService(log,
+		transactor,
+		repositories.NewPostgresAccountRepository(log, db),
+		transactionRepository,
+		repositories.NewNotificationsRepository(log, nil),
+	)
+	reversalService := service.NewReversalService(log, transactor)
+	importFile := func(data string) (*service.AccountStats, error) {
+		reader, err := service.NewCSVStatementReader(strings.NewReader(data), service.DefaultCSVFormat())
+		require.NoError(t, err)
+		return transactionService.ProcessTransactionsStream(ctx, accountNumber, reader, service.ImportOptions{})
+	}
+
+	file := "Id,Date,Transaction\n0,2024-07-15,60.5\n1,2024-07-28,-10.3\n"
+	stats, err := importFile(file)
+	require.NoError(t, err)
+	txns, err := transactionRepository.GetByBatchID(ctx, stats.BatchID)
+	require.NoError(t, err)
+	require.Len(t, txns, 2)
+
+	// Part of the batch is still imported, so the file is too.
+	_, err = reversalService.ReverseTransaction(ctx, txns[1].ID, "ops", "charged twice")
+	require.NoError(t, err)
+	_, err = importFile(file)
+	assert.ErrorIs(t, err, service.ErrFileAlreadyImported)
+
+	_, err = reversalService.ReverseBatch(ctx, stats.BatchID, "ops", "wrong file")
+	require.NoError(t, err)
+
+	stats, err = importFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+	assert.Empty(t, stats.DuplicateIDs)
+	assert.Equal(t, domain.MustParseAmount("50.2"), stats.Balance)
+}
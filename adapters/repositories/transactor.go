@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// PostgresTransactor runs service work inside a single database transaction.
+type PostgresTransactor struct {
+	log *zap.SugaredLogger
+	db  *sqlx.DB
+}
+
+func NewPostgresTransactor(log *zap.SugaredLogger, db *sqlx.DB) *PostgresTransactor {
+	return &PostgresTransactor{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran begins a transaction, hands fn repositories bound to it and
+// commits when fn succeeds. Any error from fn rolls everything back.
+func (t PostgresTransactor) WithinTran(ctx context.Context, fn func(service.Repositories) error) error {
+	//nolint:wrapcheck
+	return database.WithinTran(ctx, t.log, t.db, func(tx sqlx.ExtContext) error {
+		return fn(service.Repositories{
+			Account:     NewPostgresAccountRepository(t.log, tx),
+			Transaction: NewPostgresTransactionRepository(t.log, tx),
+			Batch:       NewPostgresIngestionBatchRepository(t.log, tx),
+			FXRate:      NewPostgresFXRateRepository(t.log, tx),
+			Ledger:      NewPostgresLedgerRepository(t.log, tx),
+			Reversal:    NewPostgresReversalRepository(t.log, tx),
+			ImportJob:   NewPostgresImportJobRepository(t.log, tx),
+		})
+	})
+}
@@ -0,0 +1,306 @@
+// Package rest serves the accounts and imports of the module over HTTP.
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"go.uber.org/zap"
+)
+
+// DefaultMaxUploadSize is the largest statement accepted when
+// Config.MaxUploadSize is not set.
+const DefaultMaxUploadSize = 64 << 20
+
+// Config tunes the imports run through the API.
+type Config struct {
+	// CSVFormat is the layout of the CSV files uploaded. Its account column
+	// is not used, as the account is named by the path.
+	CSVFormat service.CSVFormat
+	// Import holds the defaults of every import. Uploads can change OnError,
+	// DryRun, RequireBalanceMatch and Currency with query parameters.
+	Import service.ImportOptions
+	// MaxUploadSize is the largest statement accepted, in bytes. Zero means
+	// DefaultMaxUploadSize.
+	MaxUploadSize int64
+}
+
+type handlers struct {
+	log          *zap.SugaredLogger
+	transactions *service.TransactionService
+	accounts     *service.AccountService
+	cfg          Config
+}
+
+// NewHandler returns the handler of the API:
+//
+//	GET  /accounts
+//	GET  /accounts/{number}
+//	GET  /accounts/{number}/balance
+//	GET  /accounts/{number}/transactions
+//	POST /accounts/{number}/imports
+func NewHandler(log *zap.SugaredLogger, transactions *service.TransactionService, accounts *service.AccountService, cfg Config) http.Handler {
+	if cfg.MaxUploadSize == 0 {
+		cfg.MaxUploadSize = DefaultMaxUploadSize
+	}
+	cfg.CSVFormat.AccountColumn = ""
+
+	h := &handlers{
+		log:          log,
+		transactions: transactions,
+		accounts:     accounts,
+		cfg:          cfg,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /accounts", h.listAccounts)
+	mux.HandleFunc("GET /accounts/{number}", h.getAccount)
+	mux.HandleFunc("GET /accounts/{number}/balance", h.getBalance)
+	mux.HandleFunc("GET /accounts/{number}/transactions", h.listTransactions)
+	mux.HandleFunc("POST /accounts/{number}/imports", h.importStatement)
+
+	return logRequests(log, mux)
+}
+
+// listAccounts pages through the customer accounts, taking the number of the
+// last account of the previous page as after.
+func (h *handlers) listAccounts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := intParam(q.Get("limit"))
+	if err != nil {
+		h.fail(w, r, badRequest("limit", err))
+		return
+	}
+
+	accounts, err := h.accounts.List(r.Context(), q.Get("after"), limit)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	resp := accountsResponse{Accounts: make([]accountResponse, 0, len(accounts))}
+	for _, a := range accounts {
+		resp.Accounts = append(resp.Accounts, toAccountResponse(a))
+	}
+	if limit == 0 {
+		limit = domain.DefaultPageSize
+	}
+	if len(accounts) == limit {
+		resp.Next = accounts[len(accounts)-1].AccountNumber
+	}
+	h.respond(w, r, http.StatusOK, resp)
+}
+
+func (h *handlers) getAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accounts.Get(r.Context(), r.PathValue("number"))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, toAccountResponse(account))
+}
+
+func (h *handlers) getBalance(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accounts.Get(r.Context(), r.PathValue("number"))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, balanceResponse{
+		AccountNumber: account.AccountNumber,
+		Currency:      account.Currency,
+		Balance:       account.Balance,
+	})
+}
+
+// listTransactions returns a page of the history of the account. The next
+// page is asked for with the cursor of the response as after.
+func (h *handlers) listTransactions(w http.ResponseWriter, r *http.Request) {
+	query, err := transactionQuery(r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	page, err := h.transactions.ListTransactions(r.Context(), r.PathValue("number"), query)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	resp := transactionsResponse{Transactions: make([]transactionResponse, 0, len(page.Transactions))}
+	for _, txn := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, toTransactionResponse(txn))
+	}
+	if page.Next != nil {
+		resp.Next = page.Next.String()
+	}
+	h.respond(w, r, http.StatusOK, resp)
+}
+
+// importStatement imports the statement sent as the body of the request, or
+// as the "file" part of a multipart form, into the account.
+func (h *handlers) importStatement(w http.ResponseWriter, r *http.Request) {
+	opts, format, err := h.importOptions(r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize)
+	body, err := uploadedFile(r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	reader, err := service.NewStatementReader(body, format, h.cfg.CSVFormat)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	stats, err := h.transactions.ProcessTransactionsStream(r.Context(), r.PathValue("number"), reader, opts)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if stats.DryRun {
+		status = http.StatusOK
+	}
+	h.respond(w, r, status, toStatsResponse(stats))
+}
+
+// importOptions reads the options of an upload from its query parameters.
+func (h *handlers) importOptions(r *http.Request) (service.ImportOptions, string, error) {
+	q := r.URL.Query()
+	opts := h.cfg.Import
+
+	if v := q.Get("on_error"); v != "" {
+		if v != service.OnErrorAbort && v != service.OnErrorSkip {
+			return opts, "", badRequest("on_error", fmt.Errorf("expected %s or %s, got %q", service.OnErrorAbort, service.OnErrorSkip, v))
+		}
+		opts.OnError = v
+	}
+	if v := q.Get("currency"); v != "" {
+		opts.Currency = strings.ToUpper(v)
+	}
+	for name, dest := range map[string]*bool{
+		"dry_run":               &opts.DryRun,
+		"require_balance_match": &opts.RequireBalanceMatch,
+	} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, "", badRequest(name, err)
+			}
+			*dest = b
+		}
+	}
+
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = service.FormatAuto
+	case service.FormatAuto, service.FormatCSV, service.FormatOFX, service.FormatCAMT053, service.FormatMT940:
+	default:
+		return opts, "", badRequest("format", fmt.Errorf("unknown format %q", format))
+	}
+
+	return opts, format, nil
+}
+
+// uploadedFile returns the "file" part of a multipart request, or the whole
+// body of any other request. Parts are streamed, not kept in memory.
+func uploadedFile(r *http.Request) (io.Reader, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, badRequest("body", err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, badRequest("file", errors.New("missing file part"))
+		}
+		if err != nil {
+			return nil, badRequest("body", err)
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// transactionQuery reads the filters of a transaction listing from the query
+// parameters of the request.
+func transactionQuery(r *http.Request) (domain.TransactionQuery, error) {
+	q := r.URL.Query()
+	var query domain.TransactionQuery
+	var err error
+
+	for name, dest := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		if v := q.Get(name); v != "" {
+			d, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return query, badRequest(name, err)
+			}
+			*dest = &d
+		}
+	}
+	for name, dest := range map[string]**domain.Amount{"min_amount": &query.MinAmount, "max_amount": &query.MaxAmount} {
+		if v := q.Get(name); v != "" {
+			a, err := domain.ParseAmount(v)
+			if err != nil {
+				return query, badRequest(name, err)
+			}
+			*dest = &a
+		}
+	}
+	if v := q.Get("batch_id"); v != "" {
+		if query.BatchID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return query, badRequest("batch_id", err)
+		}
+	}
+	if query.Limit, err = intParam(q.Get("limit")); err != nil {
+		return query, badRequest("limit", err)
+	}
+	if v := q.Get("after"); v != "" {
+		if query.After, err = domain.ParseTransactionCursor(v); err != nil {
+			return query, badRequest("after", err)
+		}
+	}
+	switch v := q.Get("order"); v {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, badRequest("order", fmt.Errorf("expected asc or desc, got %q", v))
+	}
+	query.Sign = domain.TransactionSign(q.Get("sign"))
+	query.SortBy = domain.TransactionSort(q.Get("sort"))
+
+	return query, nil
+}
+
+// intParam reads an optional integer parameter, zero when it is empty.
+func intParam(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,279 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/rest"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/fedepezzola/transactions/foundation/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testHelper struct {
+	handler http.Handler
+	account *domain.Account
+
+	accountRepository     *service.MockAccountRepository
+	transactionRepository *service.MockTransactionRepository
+	batchRepository       *service.MockIngestionBatchRepository
+}
+
+func testSetup(t *testing.T) *testHelper {
+	t.Helper()
+
+	log, _ := logger.New("TRANSACTIONS-TEST")
+
+	h := &testHelper{
+		account: &domain.Account{
+			ID:            1,
+			AccountNumber: "123456",
+			Currency:      "USD",
+			Balance:       domain.MustParseAmount("10"),
+			Status:        domain.AccountActive,
+		},
+		accountRepository:     &service.MockAccountRepository{},
+		transactionRepository: &service.MockTransactionRepository{},
+		batchRepository:       &service.MockIngestionBatchRepository{},
+	}
+	transactor := &service.MockTransactor{}
+	ledgerRepository := &service.MockLedgerRepository{}
+	notificationsRepository := &service.MockNotificationsRepository{}
+
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "CLEARING-USD").Return(&domain.Account{ID: 100, AccountNumber: "CLEARING-USD", Kind: domain.AccountClearing, Currency: "USD"}, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, mock.Anything).Return(nil, database.ErrDBNotFound)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(h.account, nil)
+
+	h.batchRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		b.ID = 7
+		return b, nil
+	})
+	h.batchRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		return b, nil
+	})
+	h.transactionRepository.EXPECT().ImportedFileIDs(mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("[]string")).Return(nil, nil)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	ledgerRepository.EXPECT().Post(mock.Anything, mock.AnythingOfType("[]*domain.JournalEntry")).Return(nil)
+	notificationsRepository.EXPECT().Notify(mock.Anything).Return(nil)
+
+	transactor.EXPECT().WithinTran(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		return fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			Ledger:      ledgerRepository,
+		})
+	})
+
+	h.handler = rest.NewHandler(log,
+		service.NewTransactionService(log, transactor, h.accountRepository, h.transactionRepository, notificationsRepository),
+		service.NewAccountService(log, transactor),
+		rest.Config{CSVFormat: service.DefaultCSVFormat()},
+	)
+
+	return h
+}
+
+func (h *testHelper) do(t *testing.T, r *http.Request, wantStatus int, resp any) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.handler.ServeHTTP(w, r)
+
+	assert.Equal(t, wantStatus, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+}
+
+const statement = `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,-10.3
+`
+
+func Test_importStatement_returns_the_stats(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	assert.NoError(t, mw.WriteField("comment", "july"))
+	part, err := mw.CreateFormFile("file", "txns.csv")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(statement))
+	assert.NoError(t, err)
+	assert.NoError(t, mw.Close())
+
+	form := httptest.NewRequest(http.MethodPost, "/accounts/123456/imports", &body)
+	form.Header.Set("Content-Type", mw.FormDataContentType())
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/accounts/123456/imports", strings.NewReader(statement)),
+		form,
+	} {
+		var resp map[string]any
+		h.do(t, r, http.StatusCreated, &resp)
+		assert.Equal(t, "123456", resp["account_number"])
+		assert.Equal(t, "50.20", resp["file_balance"])
+		assert.Equal(t, float64(2), resp["transaction_count"])
+		assert.Equal(t, float64(7), resp["batch_id"])
+		assert.Len(t, resp["recent_transactions"], 2)
+	}
+}
+
+func Test_importStatement_status_codes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path   string
+		body   string
+		setup  func(h *testHelper)
+		status int
+		line   int
+	}{
+		"bad row": {
+			path:   "/accounts/123456/imports",
+			body:   "Id,Date,Transaction\n0,7/15,+60.5\n1,7/28,ten\n",
+			status: http.StatusUnprocessableEntity,
+			line:   3,
+		},
+		"missing column": {
+			path:   "/accounts/123456/imports?format=csv",
+			body:   "Id,Date\n0,7/15\n",
+			status: http.StatusUnprocessableEntity,
+		},
+		"already imported": {
+			path: "/accounts/123456/imports",
+			body: statement,
+			setup: func(h *testHelper) {
+				h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(&domain.IngestionBatch{ID: 3}, nil)
+			},
+			status: http.StatusConflict,
+		},
+		"inactive account": {
+			path: "/accounts/123456/imports",
+			body: statement,
+			setup: func(h *testHelper) {
+				h.account.Status = domain.AccountFrozen
+			},
+			status: http.StatusConflict,
+		},
+		"bad option": {
+			path:   "/accounts/123456/imports?on_error=retry",
+			body:   statement,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			h := testSetup(t)
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+
+			var resp struct {
+				Error string `json:"error"`
+				Line  int    `json:"line"`
+			}
+			h.do(t, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)), tt.status, &resp)
+			assert.NotEmpty(t, resp.Error)
+			assert.Equal(t, tt.line, resp.Line)
+		})
+	}
+}
+
+func Test_getAccount(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	limit := domain.MustParseAmount("500")
+	h.account.OverdraftLimit = &limit
+	h.account.OverdraftPolicy = domain.OverdraftFlag
+
+	var account map[string]any
+	h.do(t, httptest.NewRequest(http.MethodGet, "/accounts/123456", nil), http.StatusOK, &account)
+	assert.Equal(t, "customer", account["kind"])
+	assert.Equal(t, "active", account["status"])
+	assert.Equal(t, "500.00", account["overdraft_limit"])
+	assert.Equal(t, "flag", account["overdraft_policy"])
+
+	var balance map[string]any
+	h.do(t, httptest.NewRequest(http.MethodGet, "/accounts/123456/balance", nil), http.StatusOK, &balance)
+	assert.Equal(t, map[string]any{"account_number": "123456", "currency": "USD", "balance": "10.00"}, balance)
+
+	var missing map[string]any
+	h.do(t, httptest.NewRequest(http.MethodGet, "/accounts/555/balance", nil), http.StatusNotFound, &missing)
+	assert.Contains(t, missing["error"], "account not found")
+}
+
+func Test_listAccounts(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().List(mock.Anything, "100", 1).Return([]*domain.Account{h.account}, nil)
+
+	var resp struct {
+		Accounts []map[string]any `json:"accounts"`
+		Next     string           `json:"next"`
+	}
+	h.do(t, httptest.NewRequest(http.MethodGet, "/accounts?after=100&limit=1", nil), http.StatusOK, &resp)
+	assert.Len(t, resp.Accounts, 1)
+	assert.Equal(t, "123456", resp.Next)
+}
+
+func Test_listTransactions(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	min := domain.MustParseAmount("-100")
+	after := &domain.TransactionCursor{Date: from, Amount: domain.MustParseAmount("-5"), ID: 9}
+	txn := &domain.Transaction{
+		ID:                3,
+		BatchID:           7,
+		FileTransactionID: "1",
+		Date:              time.Date(2024, 7, 28, 0, 0, 0, 0, time.UTC),
+		Amount:            domain.MustParseAmount("-10.3"),
+		ReversedByID:      4,
+	}
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, domain.TransactionQuery{
+		AccountID:  1,
+		From:       &from,
+		MinAmount:  &min,
+		Sign:       domain.SignNegative,
+		BatchID:    7,
+		SortBy:     domain.SortByAmount,
+		Descending: true,
+		Limit:      1,
+		After:      after,
+	}).Return(&domain.TransactionPage{Transactions: []*domain.Transaction{txn}, Next: domain.CursorOf(txn)}, nil)
+
+	var resp struct {
+		Transactions []map[string]any `json:"transactions"`
+		Next         string           `json:"next"`
+	}
+	h.do(t, httptest.NewRequest(http.MethodGet, "/accounts/123456/transactions?from=2024-07-01&min_amount=-100&sign=negative&batch_id=7&sort=amount&order=desc&limit=1&after="+after.String(), nil), http.StatusOK, &resp)
+	if assert.Len(t, resp.Transactions, 1) {
+		assert.Equal(t, "2024-07-28", resp.Transactions[0]["date"])
+		assert.Equal(t, "-10.30", resp.Transactions[0]["amount"])
+		assert.Equal(t, float64(4), resp.Transactions[0]["reversed_by_id"])
+	}
+	assert.Equal(t, domain.CursorOf(txn).String(), resp.Next)
+
+	for _, query := range []string{"from=july", "sign=zero", "after=bad", "limit=2000"} {
+		var bad map[string]any
+		h.do(t, httptest.NewRequest(http.MethodGet, "/accounts/123456/transactions?"+query, nil), http.StatusBadRequest, &bad)
+	}
+}
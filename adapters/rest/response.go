@@ -0,0 +1,313 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+)
+
+type (
+	errorResponse struct {
+		Error string `json:"error"`
+		// Line is the line of the statement that could not be imported.
+		Line int `json:"line,omitempty"`
+	}
+
+	accountResponse struct {
+		AccountNumber   string         `json:"account_number"`
+		Kind            string         `json:"kind"`
+		Currency        string         `json:"currency"`
+		Balance         domain.Amount  `json:"balance"`
+		Status          string         `json:"status"`
+		OpenedAt        time.Time      `json:"opened_at"`
+		FrozenAt        *time.Time     `json:"frozen_at,omitempty"`
+		ClosedAt        *time.Time     `json:"closed_at,omitempty"`
+		OverdraftLimit  *domain.Amount `json:"overdraft_limit,omitempty"`
+		OverdraftPolicy string         `json:"overdraft_policy,omitempty"`
+	}
+
+	// accountsResponse is a page of accounts. Next is the after of the
+	// following page, empty on the last one.
+	accountsResponse struct {
+		Accounts []accountResponse `json:"accounts"`
+		Next     string            `json:"next,omitempty"`
+	}
+
+	balanceResponse struct {
+		AccountNumber string        `json:"account_number"`
+		Currency      string        `json:"currency"`
+		Balance       domain.Amount `json:"balance"`
+	}
+
+	transactionResponse struct {
+		ID                    int64         `json:"id"`
+		BatchID               int64         `json:"batch_id,omitempty"`
+		FileTransactionID     string        `json:"file_transaction_id"`
+		Date                  string        `json:"date"`
+		ValueDate             string        `json:"value_date,omitempty"`
+		ProcessedAt           time.Time     `json:"processed_at"`
+		Amount                domain.Amount `json:"amount"`
+		Currency              string        `json:"currency,omitempty"`
+		OriginalAmount        domain.Amount `json:"original_amount"`
+		FXRate                string        `json:"fx_rate,omitempty"`
+		Description           string        `json:"description,omitempty"`
+		Counterparty          string        `json:"counterparty,omitempty"`
+		Reference             string        `json:"reference,omitempty"`
+		Type                  string        `json:"type,omitempty"`
+		ReversesTransactionID int64         `json:"reverses_transaction_id,omitempty"`
+		ReversedByID          int64         `json:"reversed_by_id,omitempty"`
+	}
+
+	// transactionsResponse is a page of transactions. Next is the after of
+	// the following page, empty on the last one.
+	transactionsResponse struct {
+		Transactions []transactionResponse `json:"transactions"`
+		Next         string                `json:"next,omitempty"`
+	}
+
+	rejectedRowResponse struct {
+		Line   int    `json:"line"`
+		Raw    string `json:"raw"`
+		Reason string `json:"reason"`
+	}
+
+	overdraftBreachResponse struct {
+		Line              int           `json:"line"`
+		FileTransactionID string        `json:"file_transaction_id"`
+		Amount            domain.Amount `json:"amount"`
+		Balance           domain.Amount `json:"balance"`
+		Limit             domain.Amount `json:"limit"`
+		Policy            string        `json:"policy"`
+		Rejected          bool          `json:"rejected"`
+	}
+
+	currencyTotalResponse struct {
+		Count     int           `json:"count"`
+		Total     domain.Amount `json:"total"`
+		Converted domain.Amount `json:"converted"`
+	}
+
+	statsResponse struct {
+		AccountNumber           string                           `json:"account_number"`
+		Currency                string                           `json:"currency"`
+		BatchID                 int64                            `json:"batch_id,omitempty"`
+		DryRun                  bool                             `json:"dry_run"`
+		Balance                 domain.Amount                    `json:"balance"`
+		FileBalance             domain.Amount                    `json:"file_balance"`
+		TransactionCount        int                              `json:"transaction_count"`
+		TransactionsPerMonth    [12]int                          `json:"transactions_per_month"`
+		DebitCount              int                              `json:"debit_count"`
+		DebitTotal              domain.Amount                    `json:"debit_total"`
+		DebitAvg                domain.Amount                    `json:"debit_avg"`
+		CreditCount             int                              `json:"credit_count"`
+		CreditTotal             domain.Amount                    `json:"credit_total"`
+		CreditAvg               domain.Amount                    `json:"credit_avg"`
+		DuplicatesSkipped       int                              `json:"duplicates_skipped"`
+		DuplicateIDs            []string                         `json:"duplicate_ids,omitempty"`
+		RejectedCount           int                              `json:"rejected_count"`
+		Rejected                []rejectedRowResponse            `json:"rejected,omitempty"`
+		StatementOpeningBalance *domain.Amount                   `json:"statement_opening_balance,omitempty"`
+		StatementBalance        *domain.Amount                   `json:"statement_balance,omitempty"`
+		Reconciled              bool                             `json:"reconciled"`
+		BalanceDifference       domain.Amount                    `json:"balance_difference"`
+		CurrencyTotals          map[string]currencyTotalResponse `json:"currency_totals,omitempty"`
+		OverdraftBreaches       []overdraftBreachResponse        `json:"overdraft_breaches,omitempty"`
+		RecentTransactions      []transactionResponse            `json:"recent_transactions"`
+	}
+)
+
+func toAccountResponse(a *domain.Account) accountResponse {
+	kind := a.Kind
+	if kind == "" {
+		kind = domain.AccountCustomer
+	}
+	return accountResponse{
+		AccountNumber:   a.AccountNumber,
+		Kind:            string(kind),
+		Currency:        a.Currency,
+		Balance:         a.Balance,
+		Status:          string(a.Status),
+		OpenedAt:        a.OpenedAt,
+		FrozenAt:        a.FrozenAt,
+		ClosedAt:        a.ClosedAt,
+		OverdraftLimit:  a.OverdraftLimit,
+		OverdraftPolicy: string(a.OverdraftPolicy),
+	}
+}
+
+func toTransactionResponse(t *domain.Transaction) transactionResponse {
+	resp := transactionResponse{
+		ID:                    t.ID,
+		BatchID:               t.BatchID,
+		FileTransactionID:     t.FileTransactionID,
+		Date:                  t.Date.Format(time.DateOnly),
+		ProcessedAt:           t.ProcessingTimestamp,
+		Amount:                t.Amount,
+		Currency:              t.Currency,
+		OriginalAmount:        t.OriginalAmount,
+		Description:           t.Description,
+		Counterparty:          t.Counterparty,
+		Reference:             t.Reference,
+		Type:                  t.Type,
+		ReversesTransactionID: t.ReversesTransactionID,
+		ReversedByID:          t.ReversedByID,
+	}
+	if t.ValueDate != nil {
+		resp.ValueDate = t.ValueDate.Format(time.DateOnly)
+	}
+	if t.FXRate != 0 {
+		resp.FXRate = t.FXRate.String()
+	}
+	return resp
+}
+
+func toStatsResponse(s *service.AccountStats) statsResponse {
+	resp := statsResponse{
+		AccountNumber:           s.AccountNumber,
+		Currency:                s.Currency,
+		BatchID:                 s.BatchID,
+		DryRun:                  s.DryRun,
+		Balance:                 s.Balance,
+		FileBalance:             s.FileBalance,
+		TransactionCount:        s.TransactionCount,
+		TransactionsPerMonth:    s.TransactionsPerMonth,
+		DebitCount:              s.DebitCount,
+		DebitTotal:              s.DebitTotal,
+		DebitAvg:                s.DebitAvg,
+		CreditCount:             s.CreditCount,
+		CreditTotal:             s.CreditTotal,
+		CreditAvg:               s.CreditAvg,
+		DuplicatesSkipped:       s.DuplicatesSkipped,
+		DuplicateIDs:            s.DuplicateIDs,
+		RejectedCount:           s.RejectedCount,
+		StatementOpeningBalance: s.StatementOpeningBalance,
+		StatementBalance:        s.StatementBalance,
+		Reconciled:              s.Reconciled,
+		BalanceDifference:       s.BalanceDifference,
+		RecentTransactions:      make([]transactionResponse, 0, len(s.RecentTransactions)),
+	}
+	for _, r := range s.Rejected {
+		resp.Rejected = append(resp.Rejected, rejectedRowResponse(r))
+	}
+	if len(s.CurrencyTotals) > 0 {
+		resp.CurrencyTotals = make(map[string]currencyTotalResponse, len(s.CurrencyTotals))
+		for currency, total := range s.CurrencyTotals {
+			resp.CurrencyTotals[currency] = currencyTotalResponse(total)
+		}
+	}
+	for _, b := range s.OverdraftBreaches {
+		resp.OverdraftBreaches = append(resp.OverdraftBreaches, overdraftBreachResponse{
+			Line:              b.Line,
+			FileTransactionID: b.FileTransactionID,
+			Amount:            b.Amount,
+			Balance:           b.Balance,
+			Limit:             b.Limit,
+			Policy:            string(b.Policy),
+			Rejected:          b.Rejected,
+		})
+	}
+	for i := range s.RecentTransactions {
+		resp.RecentTransactions = append(resp.RecentTransactions, toTransactionResponse(&s.RecentTransactions[i]))
+	}
+	return resp
+}
+
+// paramError is a query parameter or body that can not be read.
+type paramError struct {
+	param string
+	err   error
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.param, e.err)
+}
+
+func (e *paramError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(param string, err error) error {
+	return &paramError{param: param, err: err}
+}
+
+// statusOf maps the errors of the services to the status of the response.
+func statusOf(err error) int {
+	var paramErr *paramError
+	var rowErr *service.StatementRowError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &paramErr), errors.Is(err, domain.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAccountNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrFileAlreadyImported),
+		errors.Is(err, service.ErrAccountInactive),
+		errors.Is(err, database.ErrDBDuplicatedEntry):
+		return http.StatusConflict
+	case errors.As(err, &rowErr),
+		errors.Is(err, service.ErrMissingColumn),
+		errors.Is(err, service.ErrInvalidOFX),
+		errors.Is(err, service.ErrInvalidCAMT053),
+		errors.Is(err, service.ErrInvalidMT940),
+		errors.Is(err, service.ErrFXRateNotFound),
+		errors.Is(err, service.ErrOverdraftLimitExceeded),
+		errors.Is(err, service.ErrStatementBalanceMismatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// fail writes the error as the response. Server errors are logged and their
+// details kept out of the response.
+func (h *handlers) fail(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err)
+	resp := errorResponse{Error: err.Error()}
+	if status == http.StatusInternalServerError {
+		h.log.Errorw("request failed", "method", r.Method, "path", r.URL.Path, "ERROR", err)
+		resp.Error = http.StatusText(status)
+	}
+	var rowErr *service.StatementRowError
+	if errors.As(err, &rowErr) {
+		resp.Line = rowErr.Line
+	}
+	h.respond(w, r, status, resp)
+}
+
+func (h *handlers) respond(w http.ResponseWriter, r *http.Request, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.log.Warnw("writing response", "method", r.Method, "path", r.URL.Path, "ERROR", err)
+	}
+}
+
+// statusRecorder keeps the status written to a response for the logs.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request once it is served.
+func logRequests(log *zap.SugaredLogger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Infow("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/rpc/transactionspb"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toAccountProto(a *domain.Account) *transactionspb.Account {
+	kind := a.Kind
+	if kind == "" {
+		kind = domain.AccountCustomer
+	}
+	return &transactionspb.Account{
+		AccountNumber:   a.AccountNumber,
+		Kind:            string(kind),
+		Currency:        a.Currency,
+		Balance:         a.Balance.String(),
+		Status:          string(a.Status),
+		OpenedAt:        timestamp(&a.OpenedAt),
+		FrozenAt:        timestamp(a.FrozenAt),
+		ClosedAt:        timestamp(a.ClosedAt),
+		OverdraftLimit:  amountString(a.OverdraftLimit),
+		OverdraftPolicy: string(a.OverdraftPolicy),
+	}
+}
+
+func toTransactionProto(t *domain.Transaction) *transactionspb.Transaction {
+	txn := &transactionspb.Transaction{
+		Id:                    t.ID,
+		BatchId:               t.BatchID,
+		FileTransactionId:     t.FileTransactionID,
+		Date:                  t.Date.Format(time.DateOnly),
+		ProcessedAt:           timestamp(&t.ProcessingTimestamp),
+		Amount:                t.Amount.String(),
+		Currency:              t.Currency,
+		OriginalAmount:        t.OriginalAmount.String(),
+		Description:           t.Description,
+		Counterparty:          t.Counterparty,
+		Reference:             t.Reference,
+		Type:                  t.Type,
+		ReversesTransactionId: t.ReversesTransactionID,
+		ReversedById:          t.ReversedByID,
+	}
+	if t.ValueDate != nil {
+		txn.ValueDate = t.ValueDate.Format(time.DateOnly)
+	}
+	if t.FXRate != 0 {
+		txn.FxRate = t.FXRate.String()
+	}
+	return txn
+}
+
+func toStatsProto(s *service.AccountStats) *transactionspb.AccountStats {
+	stats := &transactionspb.AccountStats{
+		AccountNumber:           s.AccountNumber,
+		Currency:                s.Currency,
+		BatchId:                 s.BatchID,
+		DryRun:                  s.DryRun,
+		Balance:                 s.Balance.String(),
+		FileBalance:             s.FileBalance.String(),
+		TransactionCount:        int32(s.TransactionCount),
+		TransactionsPerMonth:    make([]int32, len(s.TransactionsPerMonth)),
+		DebitCount:              int32(s.DebitCount),
+		DebitTotal:              s.DebitTotal.String(),
+		DebitAvg:                s.DebitAvg.String(),
+		CreditCount:             int32(s.CreditCount),
+		CreditTotal:             s.CreditTotal.String(),
+		CreditAvg:               s.CreditAvg.String(),
+		DuplicatesSkipped:       int32(s.DuplicatesSkipped),
+		DuplicateIds:            s.DuplicateIDs,
+		RejectedCount:           int32(s.RejectedCount),
+		StatementOpeningBalance: amountString(s.StatementOpeningBalance),
+		StatementBalance:        amountString(s.StatementBalance),
+		Reconciled:              s.Reconciled,
+		BalanceDifference:       s.BalanceDifference.String(),
+	}
+	for i, n := range s.TransactionsPerMonth {
+		stats.TransactionsPerMonth[i] = int32(n)
+	}
+	for _, r := range s.Rejected {
+		stats.Rejected = append(stats.Rejected, &transactionspb.RejectedRow{
+			Line:   int32(r.Line),
+			Raw:    r.Raw,
+			Reason: r.Reason,
+		})
+	}
+	if len(s.CurrencyTotals) > 0 {
+		stats.CurrencyTotals = make(map[string]*transactionspb.CurrencyTotal, len(s.CurrencyTotals))
+		for currency, total := range s.CurrencyTotals {
+			stats.CurrencyTotals[currency] = &transactionspb.CurrencyTotal{
+				Count:     int32(total.Count),
+				Total:     total.Total.String(),
+				Converted: total.Converted.String(),
+			}
+		}
+	}
+	for _, b := range s.OverdraftBreaches {
+		stats.OverdraftBreaches = append(stats.OverdraftBreaches, &transactionspb.OverdraftBreach{
+			Line:              int32(b.Line),
+			FileTransactionId: b.FileTransactionID,
+			Amount:            b.Amount.String(),
+			Balance:           b.Balance.String(),
+			Limit:             b.Limit.String(),
+			Policy:            string(b.Policy),
+			Rejected:          b.Rejected,
+		})
+	}
+	for i := range s.RecentTransactions {
+		stats.RecentTransactions = append(stats.RecentTransactions, toTransactionProto(&s.RecentTransactions[i]))
+	}
+	return stats
+}
+
+// timestamp converts an optional time, leaving zero and nil times unset.
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func amountString(a *domain.Amount) *string {
+	if a == nil {
+		return nil
+	}
+	s := a.String()
+	return &s
+}
@@ -0,0 +1,286 @@
+// Package rpc serves the accounts and imports of the module over gRPC, with
+// the service defined in transactionspb.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/rpc/transactionspb"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errUnexpectedMessage is returned for import streams whose messages are not
+// the options followed by the rows.
+var errUnexpectedMessage = errors.New("the options of an import go in the first message only")
+
+// Config tunes the imports run through the service.
+type Config struct {
+	// Import holds the defaults of every import. Clients can change
+	// OnError, DryRun, RequireBalanceMatch and Currency in the options of
+	// the stream.
+	Import service.ImportOptions
+}
+
+type server struct {
+	transactionspb.UnimplementedTransactionServiceServer
+
+	log          *zap.SugaredLogger
+	transactions *service.TransactionService
+	accounts     *service.AccountService
+	cfg          Config
+}
+
+// NewServer returns a gRPC server with the transaction service registered.
+func NewServer(log *zap.SugaredLogger, transactions *service.TransactionService, accounts *service.AccountService, cfg Config) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary(log)),
+		grpc.ChainStreamInterceptor(logStream(log)),
+	)
+	transactionspb.RegisterTransactionServiceServer(srv, &server{
+		log:          log,
+		transactions: transactions,
+		accounts:     accounts,
+		cfg:          cfg,
+	})
+	return srv
+}
+
+// ImportTransactions imports the rows of the stream into the account named
+// by its first message.
+func (s *server) ImportTransactions(stream transactionspb.TransactionService_ImportTransactionsServer) error {
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "missing import options")
+	}
+	if err != nil {
+		return err
+	}
+	options := first.GetOptions()
+	if options == nil {
+		return status.Error(codes.InvalidArgument, "the first message must hold the options of the import")
+	}
+	opts, err := s.importOptions(options)
+	if err != nil {
+		return err
+	}
+
+	reader := service.NewEntryStatementReader(func() (service.StatementEntry, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			if ctxErr := stream.Context().Err(); ctxErr != nil {
+				return service.StatementEntry{}, ctxErr
+			}
+			return service.StatementEntry{}, err
+		}
+		row := req.GetRow()
+		if row == nil {
+			return service.StatementEntry{}, errUnexpectedMessage
+		}
+		return service.StatementEntry{
+			ID:           row.GetId(),
+			BookingDate:  row.GetDate(),
+			ValueDate:    row.GetValueDate(),
+			Amount:       row.GetAmount(),
+			Currency:     row.GetCurrency(),
+			Description:  row.GetDescription(),
+			Counterparty: row.GetCounterparty(),
+			Reference:    row.GetReference(),
+			Type:         row.GetType(),
+		}, nil
+	})
+
+	stats, err := s.transactions.ProcessTransactionsStream(stream.Context(), options.GetAccountNumber(), reader, opts)
+	if err != nil {
+		return s.status(stream.Context(), err)
+	}
+
+	return stream.SendAndClose(toStatsProto(stats))
+}
+
+func (s *server) GetAccount(ctx context.Context, req *transactionspb.GetAccountRequest) (*transactionspb.Account, error) {
+	account, err := s.accounts.Get(ctx, req.GetAccountNumber())
+	if err != nil {
+		return nil, s.status(ctx, err)
+	}
+	return toAccountProto(account), nil
+}
+
+func (s *server) ListTransactions(ctx context.Context, req *transactionspb.ListTransactionsRequest) (*transactionspb.ListTransactionsResponse, error) {
+	query, err := transactionQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.transactions.ListTransactions(ctx, req.GetAccountNumber(), query)
+	if err != nil {
+		return nil, s.status(ctx, err)
+	}
+
+	resp := &transactionspb.ListTransactionsResponse{
+		Transactions: make([]*transactionspb.Transaction, 0, len(page.Transactions)),
+	}
+	for _, txn := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, toTransactionProto(txn))
+	}
+	if page.Next != nil {
+		resp.Next = page.Next.String()
+	}
+	return resp, nil
+}
+
+func (s *server) GetStats(ctx context.Context, req *transactionspb.GetStatsRequest) (*transactionspb.AccountStats, error) {
+	var query domain.TransactionQuery
+	var err error
+	if query.From, err = dateParam("from", req.GetFrom()); err != nil {
+		return nil, err
+	}
+	if query.To, err = dateParam("to", req.GetTo()); err != nil {
+		return nil, err
+	}
+	query.BatchID = req.GetBatchId()
+
+	stats, err := s.transactions.Stats(ctx, req.GetAccountNumber(), query)
+	if err != nil {
+		return nil, s.status(ctx, err)
+	}
+	return toStatsProto(stats), nil
+}
+
+// importOptions applies the options sent by the client to the defaults.
+func (s *server) importOptions(options *transactionspb.ImportOptions) (service.ImportOptions, error) {
+	opts := s.cfg.Import
+	if options.GetAccountNumber() == "" {
+		return opts, status.Error(codes.InvalidArgument, "missing account number")
+	}
+	switch v := options.GetOnError(); v {
+	case "":
+	case service.OnErrorAbort, service.OnErrorSkip:
+		opts.OnError = v
+	default:
+		return opts, status.Errorf(codes.InvalidArgument, "invalid on_error: expected %s or %s, got %q", service.OnErrorAbort, service.OnErrorSkip, v)
+	}
+	if v := options.GetCurrency(); v != "" {
+		opts.Currency = strings.ToUpper(v)
+	}
+	opts.DryRun = opts.DryRun || options.GetDryRun()
+	opts.RequireBalanceMatch = opts.RequireBalanceMatch || options.GetRequireBalanceMatch()
+	return opts, nil
+}
+
+// transactionQuery reads the filters of a transaction listing.
+func transactionQuery(req *transactionspb.ListTransactionsRequest) (domain.TransactionQuery, error) {
+	query := domain.TransactionQuery{
+		Sign:       domain.TransactionSign(req.GetSign()),
+		BatchID:    req.GetBatchId(),
+		SortBy:     domain.TransactionSort(req.GetSort()),
+		Descending: req.GetDescending(),
+		Limit:      int(req.GetLimit()),
+	}
+	var err error
+	if query.From, err = dateParam("from", req.GetFrom()); err != nil {
+		return query, err
+	}
+	if query.To, err = dateParam("to", req.GetTo()); err != nil {
+		return query, err
+	}
+	if req.MinAmount != nil {
+		if query.MinAmount, err = amountParam("min_amount", req.GetMinAmount()); err != nil {
+			return query, err
+		}
+	}
+	if req.MaxAmount != nil {
+		if query.MaxAmount, err = amountParam("max_amount", req.GetMaxAmount()); err != nil {
+			return query, err
+		}
+	}
+	if v := req.GetAfter(); v != "" {
+		if query.After, err = domain.ParseTransactionCursor(v); err != nil {
+			return query, status.Errorf(codes.InvalidArgument, "invalid after: %s", err)
+		}
+	}
+	return query, nil
+}
+
+// dateParam reads an optional YYYY-MM-DD date, nil when it is empty.
+func dateParam(name string, v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	d, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %s", name, err)
+	}
+	return &d, nil
+}
+
+func amountParam(name string, v string) (*domain.Amount, error) {
+	a, err := domain.ParseAmount(v)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %s", name, err)
+	}
+	return &a, nil
+}
+
+// status maps the errors of the services to gRPC statuses. Internal errors
+// are logged and their details kept from the client.
+func (s *server) status(ctx context.Context, err error) error {
+	var rowErr *service.StatementRowError
+	code := codes.Internal
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.As(err, &rowErr),
+		errors.Is(err, errUnexpectedMessage),
+		errors.Is(err, domain.ErrInvalidQuery):
+		code = codes.InvalidArgument
+	case errors.Is(err, service.ErrAccountNotFound):
+		code = codes.NotFound
+	case errors.Is(err, service.ErrFileAlreadyImported),
+		errors.Is(err, database.ErrDBDuplicatedEntry):
+		code = codes.AlreadyExists
+	case errors.Is(err, service.ErrAccountInactive),
+		errors.Is(err, service.ErrFXRateNotFound),
+		errors.Is(err, service.ErrOverdraftLimitExceeded),
+		errors.Is(err, service.ErrStatementBalanceMismatch):
+		code = codes.FailedPrecondition
+	}
+
+	if code == codes.Internal {
+		method, _ := grpc.Method(ctx)
+		s.log.Errorw("call failed", "method", method, "ERROR", err)
+		return status.Error(code, "internal error")
+	}
+	return status.Error(code, err.Error())
+}
+
+// logUnary logs every unary call once it is served.
+func logUnary(log *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		log.Infow("call", "method", info.FullMethod, "code", status.Code(err), "duration", time.Since(start))
+		return resp, err
+	}
+}
+
+// logStream logs every streaming call once it is served.
+func logStream(log *zap.SugaredLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		log.Infow("call", "method", info.FullMethod, "code", status.Code(err), "duration", time.Since(start))
+		return err
+	}
+}
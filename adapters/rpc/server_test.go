@@ -0,0 +1,257 @@
+package rpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/adapters/rpc"
+	"github.com/fedepezzola/transactions/adapters/rpc/transactionspb"
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/fedepezzola/transactions/foundation/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testHelper struct {
+	ctx     context.Context
+	client  transactionspb.TransactionServiceClient
+	account *domain.Account
+
+	accountRepository     *service.MockAccountRepository
+	transactionRepository *service.MockTransactionRepository
+	batchRepository       *service.MockIngestionBatchRepository
+}
+
+func testSetup(t *testing.T) *testHelper {
+	t.Helper()
+
+	log, _ := logger.New("TRANSACTIONS-TEST")
+
+	h := &testHelper{
+		ctx: context.Background(),
+		account: &domain.Account{
+			ID:            1,
+			AccountNumber: "123456",
+			Currency:      "USD",
+			Balance:       domain.MustParseAmount("10"),
+			Status:        domain.AccountActive,
+			OpenedAt:      time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC),
+		},
+		accountRepository:     &service.MockAccountRepository{},
+		transactionRepository: &service.MockTransactionRepository{},
+		batchRepository:       &service.MockIngestionBatchRepository{},
+	}
+	transactor := &service.MockTransactor{}
+	ledgerRepository := &service.MockLedgerRepository{}
+	notificationsRepository := &service.MockNotificationsRepository{}
+
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "CLEARING-USD").Return(&domain.Account{ID: 100, AccountNumber: "CLEARING-USD", Kind: domain.AccountClearing, Currency: "USD"}, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, mock.Anything).Return(nil, database.ErrDBNotFound)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(h.account, nil)
+
+	h.batchRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		b.ID = 7
+		return b, nil
+	})
+	h.batchRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		return b, nil
+	})
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.transactionRepository.EXPECT().ImportedFileIDs(mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("[]string")).Return(nil, nil)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	ledgerRepository.EXPECT().Post(mock.Anything, mock.AnythingOfType("[]*domain.JournalEntry")).Return(nil)
+	notificationsRepository.EXPECT().Notify(mock.Anything).Return(nil)
+
+	transactor.EXPECT().WithinTran(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		return fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			Ledger:      ledgerRepository,
+		})
+	})
+
+	server := rpc.NewServer(log,
+		service.NewTransactionService(log, transactor, h.accountRepository, h.transactionRepository, notificationsRepository),
+		service.NewAccountService(log, transactor),
+		rpc.Config{Import: service.ImportOptions{RejectUnknownAccounts: true}},
+	)
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	h.client = transactionspb.NewTransactionServiceClient(conn)
+
+	return h
+}
+
+// importRows streams the options and rows and returns the answer.
+func (h *testHelper) importRows(t *testing.T, options *transactionspb.ImportOptions, rows ...*transactionspb.TransactionRow) (*transactionspb.AccountStats, error) {
+	t.Helper()
+
+	stream, err := h.client.ImportTransactions(h.ctx)
+	require.NoError(t, err)
+	if options != nil {
+		require.NoError(t, stream.Send(&transactionspb.ImportTransactionsRequest{
+			Payload: &transactionspb.ImportTransactionsRequest_Options{Options: options},
+		}))
+	}
+	for _, row := range rows {
+		require.NoError(t, stream.Send(&transactionspb.ImportTransactionsRequest{
+			Payload: &transactionspb.ImportTransactionsRequest_Row{Row: row},
+		}))
+	}
+	return stream.CloseAndRecv()
+}
+
+func Test_ImportTransactions_returns_the_stats(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	stats, err := h.importRows(t, &transactionspb.ImportOptions{AccountNumber: "123456"},
+		&transactionspb.TransactionRow{Id: "0", Date: "2024-07-15", Amount: "60.5", Description: "salary"},
+		&transactionspb.TransactionRow{Id: "1", Date: "2024-07-28", Amount: "-10.3"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "123456", stats.AccountNumber)
+	assert.Equal(t, int64(7), stats.BatchId)
+	assert.Equal(t, int32(2), stats.TransactionCount)
+	assert.Equal(t, "50.20", stats.FileBalance)
+	assert.Equal(t, "60.50", stats.DebitTotal)
+	assert.Equal(t, int32(2), stats.TransactionsPerMonth[6])
+	if assert.Len(t, stats.RecentTransactions, 2) {
+		assert.Equal(t, "salary", stats.RecentTransactions[0].Description)
+	}
+}
+
+func Test_ImportTransactions_status_codes(t *testing.T) {
+	t.Parallel()
+
+	row := &transactionspb.TransactionRow{Id: "0", Date: "2024-07-15", Amount: "60.5"}
+	tests := map[string]struct {
+		options *transactionspb.ImportOptions
+		rows    []*transactionspb.TransactionRow
+		code    codes.Code
+	}{
+		"missing options": {
+			rows: []*transactionspb.TransactionRow{row},
+			code: codes.InvalidArgument,
+		},
+		"missing account": {
+			options: &transactionspb.ImportOptions{},
+			code:    codes.InvalidArgument,
+		},
+		"bad row": {
+			options: &transactionspb.ImportOptions{AccountNumber: "123456"},
+			rows:    []*transactionspb.TransactionRow{row, {Id: "1", Date: "7/28", Amount: "-10.3"}},
+			code:    codes.InvalidArgument,
+		},
+		"unknown account": {
+			options: &transactionspb.ImportOptions{AccountNumber: "555"},
+			rows:    []*transactionspb.TransactionRow{row},
+			code:    codes.NotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			h := testSetup(t)
+			h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "555").Return(nil, database.ErrDBNotFound)
+
+			_, err := h.importRows(t, tt.options, tt.rows...)
+			assert.Equal(t, tt.code, status.Code(err), err)
+		})
+	}
+}
+
+func Test_GetAccount(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	account, err := h.client.GetAccount(h.ctx, &transactionspb.GetAccountRequest{AccountNumber: "123456"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.00", account.Balance)
+	assert.Equal(t, "customer", account.Kind)
+	assert.Equal(t, h.account.OpenedAt, account.OpenedAt.AsTime())
+	assert.Nil(t, account.FrozenAt)
+	assert.Nil(t, account.OverdraftLimit)
+
+	_, err = h.client.GetAccount(h.ctx, &transactionspb.GetAccountRequest{AccountNumber: "555"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func Test_ListTransactions(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	to := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+	max := domain.MustParseAmount("0")
+	txn := &domain.Transaction{ID: 3, Date: time.Date(2024, 7, 28, 0, 0, 0, 0, time.UTC), Amount: domain.MustParseAmount("-10.3")}
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, domain.TransactionQuery{
+		AccountID: 1,
+		To:        &to,
+		MaxAmount: &max,
+		SortBy:    domain.SortByDate,
+		Limit:     1,
+	}).Return(&domain.TransactionPage{Transactions: []*domain.Transaction{txn}, Next: domain.CursorOf(txn)}, nil)
+
+	maxAmount := "0"
+	resp, err := h.client.ListTransactions(h.ctx, &transactionspb.ListTransactionsRequest{AccountNumber: "123456", To: "2024-07-31", MaxAmount: &maxAmount, Limit: 1})
+	require.NoError(t, err)
+	if assert.Len(t, resp.Transactions, 1) {
+		assert.Equal(t, "2024-07-28", resp.Transactions[0].Date)
+		assert.Equal(t, "-10.30", resp.Transactions[0].Amount)
+	}
+	assert.Equal(t, domain.CursorOf(txn).String(), resp.Next)
+
+	_, err = h.client.ListTransactions(h.ctx, &transactionspb.ListTransactionsRequest{AccountNumber: "123456", Sort: "id"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = h.client.ListTransactions(h.ctx, &transactionspb.ListTransactionsRequest{AccountNumber: "123456", From: "july"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_GetStats(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, domain.TransactionQuery{
+		AccountID: 1,
+		BatchID:   7,
+		SortBy:    domain.SortByDate,
+		Limit:     domain.MaxPageSize,
+	}).Return(&domain.TransactionPage{Transactions: []*domain.Transaction{
+		{ID: 1, Date: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), Amount: domain.MustParseAmount("60.5"), Currency: "USD"},
+		{ID: 2, Date: time.Date(2024, 8, 2, 0, 0, 0, 0, time.UTC), Amount: domain.MustParseAmount("-20.46"), Currency: "USD"},
+	}}, nil)
+
+	stats, err := h.client.GetStats(h.ctx, &transactionspb.GetStatsRequest{AccountNumber: "123456", BatchId: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "10.00", stats.Balance)
+	assert.Equal(t, "40.04", stats.FileBalance)
+	assert.Equal(t, int32(1), stats.CreditCount)
+	assert.Equal(t, []int32{0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0}, stats.TransactionsPerMonth)
+	assert.Equal(t, int32(2), stats.CurrencyTotals["USD"].Count)
+}
@@ -1,7 +1,154 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidStatusChange is returned when an account can not move to the
+// requested status.
+var ErrInvalidStatusChange = errors.New("invalid account status change")
+
+// AccountStatus is the lifecycle state of an account.
+type AccountStatus string
+
+const (
+	// AccountActive accounts accept postings.
+	AccountActive AccountStatus = "active"
+	// AccountFrozen accounts reject postings until they are unfrozen.
+	AccountFrozen AccountStatus = "frozen"
+	// AccountClosed accounts reject postings for good.
+	AccountClosed AccountStatus = "closed"
+)
+
+// AccountKind tells customer accounts apart from the accounts on the other
+// side of their ledger entries.
+type AccountKind string
+
+const (
+	// AccountCustomer accounts hold the transactions of the statements.
+	AccountCustomer AccountKind = "customer"
+	// AccountClearing accounts balance the ledger entries of the customer
+	// accounts in their currency. Their balance is only kept in the ledger.
+	AccountClearing AccountKind = "clearing"
+)
+
+// ClearingAccountNumber is the number of the clearing account of a currency.
+func ClearingAccountNumber(currency string) string {
+	return "CLEARING-" + currency
+}
+
+// ErrInvalidOverdraft is returned for negative overdraft limits and unknown
+// overdraft policies.
+var ErrInvalidOverdraft = errors.New("invalid overdraft")
+
+// OverdraftPolicy tells what an import does with a row that takes the balance
+// of the account below its overdraft limit.
+type OverdraftPolicy string
+
+const (
+	// OverdraftRejectRow leaves the row out of the import.
+	OverdraftRejectRow OverdraftPolicy = "reject-row"
+	// OverdraftRejectFile fails the whole import.
+	OverdraftRejectFile OverdraftPolicy = "reject-file"
+	// OverdraftFlag imports the row and reports the breach.
+	OverdraftFlag OverdraftPolicy = "flag"
+)
+
 type Account struct {
 	ID            int64
 	AccountNumber string
-	Balance       float32
+	// Kind is empty for accounts read before kinds existed, which are
+	// customer accounts.
+	Kind     AccountKind
+	Currency string
+	// Balance caches the sum of the ledger entries of a customer account.
+	Balance  Amount
+	Status   AccountStatus
+	OpenedAt time.Time
+	// FrozenAt is when the account was last frozen, and stays set after it
+	// is unfrozen.
+	FrozenAt *time.Time
+	ClosedAt *time.Time
+	// OverdraftLimit is how far below zero the balance can go. Nil means
+	// there is no limit.
+	OverdraftLimit *Amount
+	// OverdraftPolicy applies when a posting breaks OverdraftLimit. Empty
+	// means OverdraftRejectFile.
+	OverdraftPolicy OverdraftPolicy
+}
+
+// IsClearing tells whether the account is a clearing account.
+func (a *Account) IsClearing() bool {
+	return a.Kind == AccountClearing
+}
+
+// IsActive tells whether the account accepts postings. Accounts stored before
+// statuses existed have none and are active.
+func (a *Account) IsActive() bool {
+	return a.Status == AccountActive || a.Status == ""
+}
+
+// Freeze stops an active account from accepting postings.
+func (a *Account) Freeze(now time.Time) error {
+	if a.IsClearing() {
+		return fmt.Errorf("%w: account %s is a clearing account", ErrInvalidStatusChange, a.AccountNumber)
+	}
+	if !a.IsActive() {
+		return fmt.Errorf("%w: account %s is %s", ErrInvalidStatusChange, a.AccountNumber, a.Status)
+	}
+	a.Status = AccountFrozen
+	a.FrozenAt = &now
+	return nil
+}
+
+// Unfreeze makes a frozen account accept postings again.
+func (a *Account) Unfreeze() error {
+	if a.Status != AccountFrozen {
+		return fmt.Errorf("%w: account %s is not frozen", ErrInvalidStatusChange, a.AccountNumber)
+	}
+	a.Status = AccountActive
+	return nil
+}
+
+// Close closes an active or frozen account. Only accounts without balance
+// can be closed.
+func (a *Account) Close(now time.Time) error {
+	if a.IsClearing() {
+		return fmt.Errorf("%w: account %s is a clearing account", ErrInvalidStatusChange, a.AccountNumber)
+	}
+	if a.Status == AccountClosed {
+		return fmt.Errorf("%w: account %s is already closed", ErrInvalidStatusChange, a.AccountNumber)
+	}
+	if a.Balance != 0 {
+		return fmt.Errorf("%w: account %s has a balance of %s", ErrInvalidStatusChange, a.AccountNumber, a.Balance)
+	}
+	a.Status = AccountClosed
+	a.ClosedAt = &now
+	return nil
+}
+
+// SetOverdraft sets the overdraft limit of the account, or removes it when
+// limit is nil, and the policy applied when it is exceeded.
+func (a *Account) SetOverdraft(limit *Amount, policy OverdraftPolicy) error {
+	if limit != nil && limit.IsNegative() {
+		return fmt.Errorf("%w: negative limit %s", ErrInvalidOverdraft, *limit)
+	}
+	switch policy {
+	case "":
+		policy = OverdraftRejectFile
+	case OverdraftRejectRow, OverdraftRejectFile, OverdraftFlag:
+	default:
+		return fmt.Errorf("%w: unknown policy %q", ErrInvalidOverdraft, policy)
+	}
+	a.OverdraftLimit = limit
+	a.OverdraftPolicy = policy
+	return nil
+}
+
+// ExceedsOverdraft tells whether balance is below the overdraft limit of the
+// account.
+func (a *Account) ExceedsOverdraft(balance Amount) bool {
+	return a.OverdraftLimit != nil && balance < -*a.OverdraftLimit
 }
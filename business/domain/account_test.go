@@ -0,0 +1,67 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Account_status_changes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	a := &domain.Account{AccountNumber: "123456", Status: domain.AccountActive}
+
+	assert.ErrorIs(t, a.Unfreeze(), domain.ErrInvalidStatusChange)
+
+	assert.NoError(t, a.Freeze(now))
+	assert.Equal(t, domain.AccountFrozen, a.Status)
+	assert.Equal(t, now, *a.FrozenAt)
+	assert.False(t, a.IsActive())
+	assert.ErrorIs(t, a.Freeze(now), domain.ErrInvalidStatusChange)
+
+	assert.NoError(t, a.Unfreeze())
+	assert.True(t, a.IsActive())
+
+	a.Balance = domain.MustParseAmount("0.01")
+	assert.ErrorIs(t, a.Close(now), domain.ErrInvalidStatusChange)
+
+	a.Balance = 0
+	assert.NoError(t, a.Freeze(now))
+	assert.NoError(t, a.Close(now))
+	assert.Equal(t, domain.AccountClosed, a.Status)
+	assert.Equal(t, now, *a.ClosedAt)
+	assert.ErrorIs(t, a.Close(now), domain.ErrInvalidStatusChange)
+	assert.ErrorIs(t, a.Freeze(now), domain.ErrInvalidStatusChange)
+	assert.ErrorIs(t, a.Unfreeze(), domain.ErrInvalidStatusChange)
+}
+
+func Test_Account_without_status_is_active(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, (&domain.Account{}).IsActive())
+}
+
+func Test_Account_SetOverdraft(t *testing.T) {
+	t.Parallel()
+
+	a := &domain.Account{AccountNumber: "123456"}
+	assert.False(t, a.ExceedsOverdraft(domain.MustParseAmount("-1000000")), "no limit")
+
+	limit := domain.MustParseAmount("100")
+	assert.NoError(t, a.SetOverdraft(&limit, ""))
+	assert.Equal(t, domain.OverdraftRejectFile, a.OverdraftPolicy)
+	assert.False(t, a.ExceedsOverdraft(domain.MustParseAmount("-100")))
+	assert.True(t, a.ExceedsOverdraft(domain.MustParseAmount("-100.01")))
+
+	negative := domain.MustParseAmount("-1")
+	assert.ErrorIs(t, a.SetOverdraft(&negative, domain.OverdraftFlag), domain.ErrInvalidOverdraft)
+	assert.ErrorIs(t, a.SetOverdraft(&limit, "warn"), domain.ErrInvalidOverdraft)
+	assert.Equal(t, limit, *a.OverdraftLimit)
+
+	assert.NoError(t, a.SetOverdraft(nil, domain.OverdraftFlag))
+	assert.Nil(t, a.OverdraftLimit)
+	assert.Equal(t, domain.OverdraftFlag, a.OverdraftPolicy)
+}
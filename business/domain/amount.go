@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// AmountScale is the number of decimal places kept by an Amount.
+const AmountScale = 4
+
+// amountUnit is the number of Amount units that make up 1.
+const amountUnit = 10000
+
+// ErrInvalidAmount is returned when a value can not be read as an Amount.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Amount is a fixed-point decimal money value with AmountScale decimal places.
+// It is stored as an integer number of ten-thousandths so adding and
+// subtracting amounts never loses precision.
+type Amount int64
+
+// ParseAmount reads a decimal string like "+60.5", "-10.3" or "10" into an
+// Amount. More than AmountScale decimal places is an error instead of a
+// silent rounding.
+func ParseAmount(s string) (Amount, error) {
+	units, err := parseDecimal(s, AmountScale, ErrInvalidAmount)
+	return Amount(units), err
+}
+
+// parseDecimal reads a decimal string into an integer number of units of
+// 10^-scale, wrapping errors with sentinel.
+func parseDecimal(s string, scale int, sentinel error) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty value: %w", sentinel)
+	}
+
+	neg := false
+	switch s[0] {
+	case '+':
+		s = s[1:]
+	case '-':
+		neg = true
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("%q: %w", s, sentinel)
+	}
+	if len(fracPart) > scale {
+		return 0, fmt.Errorf("%q has more than %d decimal places: %w", s, scale, sentinel)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%q: %w", s, sentinel)
+	}
+
+	unit := int64(math.Pow10(scale))
+	var units int64
+	if intPart != "" {
+		i, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || i > math.MaxInt64/unit {
+			return 0, fmt.Errorf("%q out of range: %w", s, sentinel)
+		}
+		units = i * unit
+	}
+	if fracPart != "" {
+		f, _ := strconv.ParseInt(fracPart+strings.Repeat("0", scale-len(fracPart)), 10, 64)
+		units += f
+	}
+
+	if neg {
+		units = -units
+	}
+	return units, nil
+}
+
+// MustParseAmount is like ParseAmount but panics on error. It is meant for
+// constants and tests.
+func MustParseAmount(s string) Amount {
+	a, err := ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with at least two decimal places, keeping any
+// further non-zero decimals.
+func (a Amount) String() string {
+	sign := ""
+	u := int64(a)
+	if u < 0 {
+		sign = "-"
+		u = -u
+	}
+
+	frac := fmt.Sprintf("%0*d", AmountScale, u%amountUnit)
+	frac = strings.TrimRight(frac, "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	return fmt.Sprintf("%s%d.%s", sign, u/amountUnit, frac)
+}
+
+// DivRound divides the amount by n rounding half away from zero. It is used
+// to compute averages.
+func (a Amount) DivRound(n int64) Amount {
+	if n == 0 {
+		return 0
+	}
+	q := int64(a) / n
+	r := int64(a) % n
+	if 2*abs(r) >= abs(n) {
+		if (int64(a) < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Amount(q)
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// IsNegative reports whether the amount is below zero.
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Amount) UnmarshalText(text []byte) error {
+	v, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Scan reads a NUMERIC column into the amount.
+func (a *Amount) Scan(val interface{}) error {
+	switch v := val.(type) {
+	case []byte:
+		return a.UnmarshalText(v)
+	case string:
+		return a.UnmarshalText([]byte(v))
+	case int64:
+		*a = Amount(v * amountUnit)
+		return nil
+	case nil:
+		*a = 0
+		return nil
+	default:
+		return fmt.Errorf("can not scan %T into Amount: %w", val, ErrInvalidAmount)
+	}
+}
+
+// Value writes the amount as an exact decimal string for NUMERIC columns.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Convert returns the amount in another currency at rate, rounding half away
+// from zero to AmountScale decimals.
+func (a Amount) Convert(rate Rate) Amount {
+	p := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(rate)))
+	q, r := new(big.Int).QuoRem(p, big.NewInt(rateUnit), new(big.Int))
+	if new(big.Int).Abs(r).Cmp(big.NewInt(rateUnit/2)) >= 0 {
+		if p.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Amount(q.Int64())
+}
@@ -0,0 +1,83 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseAmount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want domain.Amount
+		str  string
+	}{
+		{in: "+60.5", want: 605000, str: "60.50"},
+		{in: "-10.3", want: -103000, str: "-10.30"},
+		{in: "-20.46", want: -204600, str: "-20.46"},
+		{in: "10", want: 100000, str: "10.00"},
+		{in: ".5", want: 5000, str: "0.50"},
+		{in: "0.0001", want: 1, str: "0.0001"},
+	}
+	for _, tt := range tests {
+		got, err := domain.ParseAmount(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+		assert.Equal(t, tt.str, got.String(), tt.in)
+	}
+
+	for _, in := range []string{"", "+", "1.2.3", "abc", "1.00001", "1e3"} {
+		_, err := domain.ParseAmount(in)
+		assert.ErrorIs(t, err, domain.ErrInvalidAmount, in)
+	}
+}
+
+func Test_Amount_sums_without_drift(t *testing.T) {
+	t.Parallel()
+
+	var total domain.Amount
+	for i := 0; i < 10000; i++ {
+		total += domain.MustParseAmount("0.1")
+	}
+	assert.Equal(t, "1000.00", total.String())
+	assert.Equal(t, domain.MustParseAmount("-15.38"), domain.MustParseAmount("-30.76").DivRound(2))
+	assert.Equal(t, domain.MustParseAmount("0.0003"), domain.MustParseAmount("0.001").DivRound(3))
+}
+
+func Test_Amount_Convert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount string
+		rate   string
+		want   string
+	}{
+		{amount: "100", rate: "0.9215", want: "92.15"},
+		{amount: "-10.30", rate: "1045.5", want: "-10768.65"},
+		{amount: "1", rate: "0.00095648", want: "0.001"},
+		{amount: "-1", rate: "0.00095648", want: "-0.001"},
+		{amount: "0.0001", rate: "0.5", want: "0.0001"},
+	}
+	for _, tt := range tests {
+		got := domain.MustParseAmount(tt.amount).Convert(domain.MustParseRate(tt.rate))
+		assert.Equal(t, domain.MustParseAmount(tt.want), got, "%s at %s", tt.amount, tt.rate)
+	}
+}
+
+func Test_Rate(t *testing.T) {
+	t.Parallel()
+
+	r, err := domain.ParseRate("1045.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "1045.5", r.String())
+	assert.Equal(t, "0.0009564802", r.Invert().String())
+	assert.Equal(t, domain.OneRate, domain.MustParseRate("1"))
+
+	for _, in := range []string{"", "0", "-1.2", "1.00000000001", "abc"} {
+		_, err := domain.ParseRate(in)
+		assert.ErrorIs(t, err, domain.ErrInvalidRate, in)
+	}
+}
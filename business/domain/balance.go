@@ -0,0 +1,23 @@
+package domain
+
+// AccountBalance compares the balance stored in an account with the one
+// recomputed from what was posted to it: the sum of its transactions and of
+// the journal entries posted to it by hand, which have no transaction.
+type AccountBalance struct {
+	AccountID     int64
+	AccountNumber string
+	Currency      string
+	Stored        Amount
+	Transactions  Amount
+	Adjustments   Amount
+}
+
+// Expected is the balance the account should have.
+func (b AccountBalance) Expected() Amount {
+	return b.Transactions + b.Adjustments
+}
+
+// Difference is what the stored balance has over the expected one.
+func (b AccountBalance) Difference() Amount {
+	return b.Stored - b.Expected()
+}
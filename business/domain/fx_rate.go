@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// FXRate is the value of one unit of BaseCurrency in QuoteCurrency on a date.
+type FXRate struct {
+	ID            int64
+	Date          time.Time
+	BaseCurrency  string
+	QuoteCurrency string
+	Rate          Rate
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidImportJob is returned for import jobs that do not name an account
+// or have nothing to import.
+var ErrInvalidImportJob = errors.New("invalid import job")
+
+// ImportJobStatus is the state of an import job.
+type ImportJobStatus string
+
+const (
+	// ImportJobQueued jobs wait for a worker.
+	ImportJobQueued ImportJobStatus = "queued"
+	// ImportJobRunning jobs are being imported by the worker holding their
+	// lease.
+	ImportJobRunning ImportJobStatus = "running"
+	// ImportJobSucceeded jobs were imported, or checked when they are a dry
+	// run.
+	ImportJobSucceeded ImportJobStatus = "succeeded"
+	// ImportJobFailed jobs could not be imported and keep the reason.
+	ImportJobFailed ImportJobStatus = "failed"
+)
+
+// ImportJob is a statement imported in the background by a worker. Attempts
+// counts the workers that claimed it, so a worker whose lease expired can
+// tell the job was handed to another one.
+type ImportJob struct {
+	ID                  int64
+	AccountNumber       string
+	Format              string
+	OnError             string
+	DryRun              bool
+	RequireBalanceMatch bool
+	Currency            string
+	// Content is the statement to import. It is dropped once the job
+	// finishes, and is not read back with the status of the job.
+	Content       []byte
+	Status        ImportJobStatus
+	Attempts      int
+	RowsProcessed int
+	RowsRejected  int
+	// Stats holds the stats of a succeeded import as JSON.
+	Stats      json.RawMessage
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// Validate checks that the job names an account and has a statement.
+func (j *ImportJob) Validate() error {
+	if j.AccountNumber == "" {
+		return fmt.Errorf("%w: missing account number", ErrInvalidImportJob)
+	}
+	if len(j.Content) == 0 {
+		return fmt.Errorf("%w: empty statement", ErrInvalidImportJob)
+	}
+	return nil
+}
+
+// Finished tells whether the job succeeded or failed.
+func (j *ImportJob) Finished() bool {
+	return j.Status == ImportJobSucceeded || j.Status == ImportJobFailed
+}
@@ -0,0 +1,36 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ImportJob_Validate(t *testing.T) {
+	t.Parallel()
+
+	j := &domain.ImportJob{AccountNumber: "123456", Content: []byte("Id,Date,Transaction\n")}
+	assert.NoError(t, j.Validate())
+
+	j.Content = nil
+	assert.ErrorIs(t, j.Validate(), domain.ErrInvalidImportJob)
+
+	j.Content = []byte("Id,Date,Transaction\n")
+	j.AccountNumber = ""
+	assert.ErrorIs(t, j.Validate(), domain.ErrInvalidImportJob)
+}
+
+func Test_ImportJob_Finished(t *testing.T) {
+	t.Parallel()
+
+	for status, finished := range map[domain.ImportJobStatus]bool{
+		domain.ImportJobQueued:    false,
+		domain.ImportJobRunning:   false,
+		domain.ImportJobSucceeded: true,
+		domain.ImportJobFailed:    true,
+	} {
+		j := &domain.ImportJob{Status: status}
+		assert.Equal(t, finished, j.Finished(), status)
+	}
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// IngestionBatch records one imported file so the same content is never
+// applied twice to an account.
+type IngestionBatch struct {
+	ID          int64
+	AccountID   int64
+	ContentHash string
+	RowCount    int
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+}
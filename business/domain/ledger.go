@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrUnbalancedEntry is returned for journal entries whose lines do not add
+// up to zero in every currency.
+var ErrUnbalancedEntry = errors.New("unbalanced journal entry")
+
+// JournalEntry is a balanced set of ledger entries posted together. Entries
+// posted by an import point to the batch and the transaction they record,
+// while BatchID and TransactionID are zero for entries posted by hand.
+type JournalEntry struct {
+	ID            int64
+	BatchID       int64
+	TransactionID int64
+	Description   string
+	PostedAt      time.Time
+	Lines         []LedgerEntry
+}
+
+// LedgerEntry moves Amount into the account, or out of it when negative, in
+// the currency of the account.
+type LedgerEntry struct {
+	ID             int64
+	JournalEntryID int64
+	AccountID      int64
+	Amount         Amount
+	Currency       string
+}
+
+// LedgerTotal adds up the ledger entries of one currency, which is zero when
+// the ledger is balanced.
+type LedgerTotal struct {
+	Currency string
+	Entries  int
+	Total    Amount
+}
+
+// Validate checks that the entry has at least two lines and that they add up
+// to zero in every currency.
+func (e *JournalEntry) Validate() error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: %d lines", ErrUnbalancedEntry, len(e.Lines))
+	}
+
+	totals := make(map[string]Amount)
+	for _, l := range e.Lines {
+		if l.Currency == "" {
+			return fmt.Errorf("%w: line of account %d without currency", ErrUnbalancedEntry, l.AccountID)
+		}
+		totals[l.Currency] += l.Amount
+	}
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	for _, currency := range currencies {
+		if totals[currency] != 0 {
+			return fmt.Errorf("%w: %s lines add up to %s", ErrUnbalancedEntry, currency, totals[currency])
+		}
+	}
+
+	return nil
+}
+
+// NewTransactionEntry posts txn, already converted to the currency of its
+// account, against the clearing account of that currency.
+func NewTransactionEntry(txn *Transaction, account *Account, clearing *Account, postedAt time.Time) *JournalEntry {
+	return &JournalEntry{
+		BatchID:       txn.BatchID,
+		TransactionID: txn.ID,
+		Description:   "import",
+		PostedAt:      postedAt,
+		Lines: []LedgerEntry{
+			{AccountID: account.ID, Amount: txn.Amount, Currency: account.Currency},
+			{AccountID: clearing.ID, Amount: -txn.Amount, Currency: account.Currency},
+		},
+	}
+}
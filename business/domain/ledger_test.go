@@ -0,0 +1,34 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JournalEntry_Validate(t *testing.T) {
+	t.Parallel()
+
+	account := &domain.Account{ID: 1, Currency: "EUR"}
+	clearing := &domain.Account{ID: 2, Currency: "EUR", Kind: domain.AccountClearing}
+	txn := &domain.Transaction{ID: 10, BatchID: 3, Amount: domain.MustParseAmount("-12.5")}
+
+	entry := domain.NewTransactionEntry(txn, account, clearing, time.Now())
+	assert.NoError(t, entry.Validate())
+	assert.Equal(t, []domain.LedgerEntry{
+		{AccountID: 1, Amount: domain.MustParseAmount("-12.5"), Currency: "EUR"},
+		{AccountID: 2, Amount: domain.MustParseAmount("12.5"), Currency: "EUR"},
+	}, entry.Lines)
+
+	entry.Lines[1].Amount = domain.MustParseAmount("12")
+	assert.ErrorIs(t, entry.Validate(), domain.ErrUnbalancedEntry)
+
+	entry.Lines[1].Amount = domain.MustParseAmount("12.5")
+	entry.Lines[1].Currency = "USD"
+	assert.ErrorIs(t, entry.Validate(), domain.ErrUnbalancedEntry, "currencies balance on their own")
+
+	entry.Lines = entry.Lines[:1]
+	assert.ErrorIs(t, entry.Validate(), domain.ErrUnbalancedEntry)
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RateScale is the number of decimal places kept by a Rate.
+const RateScale = 10
+
+// rateUnit is the number of Rate units that make up 1.
+const rateUnit = 10000000000
+
+// ErrInvalidRate is returned when a value can not be read as a Rate.
+var ErrInvalidRate = errors.New("invalid rate")
+
+// Rate is a fixed-point exchange rate with RateScale decimal places, enough
+// for the rate of a weak currency against a strong one.
+type Rate int64
+
+// ParseRate reads a positive decimal string like "0.9215" into a Rate.
+func ParseRate(s string) (Rate, error) {
+	units, err := parseDecimal(s, RateScale, ErrInvalidRate)
+	if err != nil {
+		return 0, err
+	}
+	if units <= 0 {
+		return 0, fmt.Errorf("%q is not positive: %w", s, ErrInvalidRate)
+	}
+	return Rate(units), nil
+}
+
+// MustParseRate is like ParseRate but panics on error. It is meant for
+// constants and tests.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// OneRate is the rate between a currency and itself.
+const OneRate Rate = rateUnit
+
+// Invert returns the rate of the opposite conversion, rounded half up to
+// RateScale decimals.
+func (r Rate) Invert() Rate {
+	if r <= 0 {
+		return 0
+	}
+	one := new(big.Int).Mul(big.NewInt(rateUnit), big.NewInt(rateUnit))
+	q, rem := new(big.Int).QuoRem(one, big.NewInt(int64(r)), new(big.Int))
+	if 2*rem.Int64() >= int64(r) {
+		q.Add(q, big.NewInt(1))
+	}
+	return Rate(q.Int64())
+}
+
+// String formats the rate without trailing zeros.
+func (r Rate) String() string {
+	u := int64(r)
+	sign := ""
+	if u < 0 {
+		sign = "-"
+		u = -u
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%0*d", RateScale, u%rateUnit), "0")
+	if frac == "" {
+		return fmt.Sprintf("%s%d", sign, u/rateUnit)
+	}
+	return fmt.Sprintf("%s%d.%s", sign, u/rateUnit, frac)
+}
+
+// Scan reads a NUMERIC column into the rate.
+func (r *Rate) Scan(val interface{}) error {
+	var s string
+	switch v := val.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case nil:
+		*r = 0
+		return nil
+	default:
+		return fmt.Errorf("can not scan %T into Rate: %w", val, ErrInvalidRate)
+	}
+	units, err := parseDecimal(s, RateScale, ErrInvalidRate)
+	if err != nil {
+		return err
+	}
+	*r = Rate(units)
+	return nil
+}
+
+// Value writes the rate as an exact decimal string for NUMERIC columns.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidReversal is returned for reversals that do not say who asked for
+// them and why.
+var ErrInvalidReversal = errors.New("invalid reversal")
+
+// Reversal records who reversed a transaction or a whole ingestion batch, and
+// why. Exactly one of BatchID and TransactionID is set. Transactions holds
+// the offsetting transactions it posted.
+type Reversal struct {
+	ID            int64
+	BatchID       int64
+	TransactionID int64
+	Reason        string
+	ReversedBy    string
+	ReversedAt    time.Time
+	Transactions  []*Transaction
+}
+
+// Validate checks that the reversal has a target, a reason and an author.
+func (r *Reversal) Validate() error {
+	if (r.BatchID == 0) == (r.TransactionID == 0) {
+		return fmt.Errorf("%w: reverse either a transaction or a batch", ErrInvalidReversal)
+	}
+	if r.Reason == "" {
+		return fmt.Errorf("%w: missing reason", ErrInvalidReversal)
+	}
+	if r.ReversedBy == "" {
+		return fmt.Errorf("%w: missing author", ErrInvalidReversal)
+	}
+	return nil
+}
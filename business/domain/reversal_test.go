@@ -0,0 +1,62 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Reversal_Validate(t *testing.T) {
+	t.Parallel()
+
+	r := &domain.Reversal{TransactionID: 3, Reason: "wrong file", ReversedBy: "ops"}
+	assert.NoError(t, r.Validate())
+
+	r.BatchID = 1
+	assert.ErrorIs(t, r.Validate(), domain.ErrInvalidReversal)
+
+	r.TransactionID = 0
+	r.Reason = ""
+	assert.ErrorIs(t, r.Validate(), domain.ErrInvalidReversal)
+
+	r.Reason = "wrong file"
+	r.ReversedBy = ""
+	assert.ErrorIs(t, r.Validate(), domain.ErrInvalidReversal)
+}
+
+func Test_Transaction_Offset(t *testing.T) {
+	t.Parallel()
+
+	txn := &domain.Transaction{
+		ID:                3,
+		AccountID:         1,
+		BatchID:           7,
+		FileTransactionID: "12",
+		Date:              time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+		Amount:            domain.MustParseAmount("-10.3"),
+		Currency:          "EUR",
+		OriginalAmount:    domain.MustParseAmount("-9.5"),
+		FXRate:            domain.OneRate,
+		Description:       "coffee",
+	}
+	reversal := &domain.Reversal{ID: 2, ReversedAt: time.Date(2024, 8, 2, 15, 4, 5, 0, time.UTC)}
+
+	offset := txn.Offset(reversal)
+	assert.Equal(t, &domain.Transaction{
+		AccountID:             1,
+		ProcessingTimestamp:   reversal.ReversedAt,
+		FileTransactionID:     "12",
+		Date:                  time.Date(2024, 8, 2, 0, 0, 0, 0, time.UTC),
+		Amount:                domain.MustParseAmount("10.3"),
+		Currency:              "EUR",
+		OriginalAmount:        domain.MustParseAmount("9.5"),
+		FXRate:                domain.OneRate,
+		Description:           "coffee",
+		ReversalID:            2,
+		ReversesTransactionID: 3,
+	}, offset)
+	assert.True(t, offset.IsReversal())
+	assert.False(t, txn.Reversed())
+}
@@ -2,12 +2,65 @@ package domain
 
 import "time"
 
+// Transaction is one movement of an account. Amount is in the currency of the
+// account. Currency and OriginalAmount are the ones of the statement, and
+// FXRate is the rate used to convert between them.
 type Transaction struct {
 	ID                  int64
 	AccountID           int64
+	BatchID             int64
 	ProcessingTimestamp time.Time
-	FileTransactionID   int
-	Month               int
-	Day                 int
-	Amount              float32
+	FileTransactionID   string
+	Date                time.Time
+	ValueDate           *time.Time
+	Amount              Amount
+	Currency            string
+	OriginalAmount      Amount
+	FXRate              Rate
+	Description         string
+	Counterparty        string
+	Reference           string
+	Type                string
+	// ReversalID and ReversesTransactionID are set on the transactions
+	// posted by a reversal, pointing to the reversal and to the transaction
+	// they offset.
+	ReversalID            int64
+	ReversesTransactionID int64
+	// ReversedByID is the transaction that offsets this one once it is
+	// reversed. It is only read, never written.
+	ReversedByID int64
+}
+
+// IsReversal tells whether the transaction offsets another one.
+func (t *Transaction) IsReversal() bool {
+	return t.ReversesTransactionID != 0
+}
+
+// Reversed tells whether the transaction was offset by a reversal.
+func (t *Transaction) Reversed() bool {
+	return t.ReversedByID != 0
+}
+
+// Offset returns the transaction that reverses t on behalf of reversal. It
+// keeps the details of t with the opposite amounts, booked on the date of the
+// reversal.
+func (t *Transaction) Offset(reversal *Reversal) *Transaction {
+	year, month, day := reversal.ReversedAt.Date()
+	return &Transaction{
+		AccountID:             t.AccountID,
+		ProcessingTimestamp:   reversal.ReversedAt,
+		FileTransactionID:     t.FileTransactionID,
+		Date:                  time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		ValueDate:             t.ValueDate,
+		Amount:                -t.Amount,
+		Currency:              t.Currency,
+		OriginalAmount:        -t.OriginalAmount,
+		FXRate:                t.FXRate,
+		Description:           t.Description,
+		Counterparty:          t.Counterparty,
+		Reference:             t.Reference,
+		Type:                  t.Type,
+		ReversalID:            reversal.ID,
+		ReversesTransactionID: t.ID,
+	}
 }
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for transaction queries that can not be run.
+var ErrInvalidQuery = errors.New("invalid transaction query")
+
+// DefaultPageSize and MaxPageSize bound the transactions returned by a page.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// TransactionSign selects transactions by the sign of their amount.
+type TransactionSign string
+
+const (
+	// SignAny selects every transaction.
+	SignAny TransactionSign = ""
+	// SignPositive selects the transactions that add to the balance,
+	// including zero amounts, like the debits of AccountStats.
+	SignPositive TransactionSign = "positive"
+	// SignNegative selects the transactions that take from the balance.
+	SignNegative TransactionSign = "negative"
+)
+
+// TransactionSort is the key transactions are listed by. Ties are broken by
+// id, so the order is stable across pages.
+type TransactionSort string
+
+const (
+	// SortByDate lists transactions by their booking date.
+	SortByDate TransactionSort = "date"
+	// SortByAmount lists transactions by amount in the account currency.
+	SortByAmount TransactionSort = "amount"
+)
+
+// TransactionQuery selects a page of the transactions of an account. Nil and
+// zero filters select everything. Date and amount ranges are inclusive.
+type TransactionQuery struct {
+	AccountID  int64
+	From       *time.Time
+	To         *time.Time
+	MinAmount  *Amount
+	MaxAmount  *Amount
+	Sign       TransactionSign
+	BatchID    int64
+	SortBy     TransactionSort
+	Descending bool
+	// Limit is the size of the page. Zero means DefaultPageSize.
+	Limit int
+	// After is the cursor of the previous page, nil for the first one.
+	After *TransactionCursor
+}
+
+// TransactionCursor is the position of the last transaction of a page, in
+// the order of the query.
+type TransactionCursor struct {
+	Date   time.Time
+	Amount Amount
+	ID     int64
+}
+
+// TransactionPage is a page of transactions. Next is nil on the last page.
+type TransactionPage struct {
+	Transactions []*Transaction
+	Next         *TransactionCursor
+}
+
+// Validate fills in the defaults of the query and checks its filters.
+func (q *TransactionQuery) Validate() error {
+	if q.SortBy == "" {
+		q.SortBy = SortByDate
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPageSize
+	}
+
+	switch {
+	case q.Limit < 0 || q.Limit > MaxPageSize:
+		return fmt.Errorf("%w: limit %d out of 1..%d", ErrInvalidQuery, q.Limit, MaxPageSize)
+	case q.SortBy != SortByDate && q.SortBy != SortByAmount:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, q.SortBy)
+	case q.Sign != SignAny && q.Sign != SignPositive && q.Sign != SignNegative:
+		return fmt.Errorf("%w: unknown sign %q", ErrInvalidQuery, q.Sign)
+	case q.From != nil && q.To != nil && q.From.After(*q.To):
+		return fmt.Errorf("%w: from %s is after to %s", ErrInvalidQuery, q.From.Format(time.DateOnly), q.To.Format(time.DateOnly))
+	case q.MinAmount != nil && q.MaxAmount != nil && *q.MinAmount > *q.MaxAmount:
+		return fmt.Errorf("%w: min amount %s is over max amount %s", ErrInvalidQuery, *q.MinAmount, *q.MaxAmount)
+	}
+	return nil
+}
+
+// CursorOf returns the cursor that starts the page after txn.
+func CursorOf(txn *Transaction) *TransactionCursor {
+	return &TransactionCursor{Date: txn.Date, Amount: txn.Amount, ID: txn.ID}
+}
+
+// String encodes the cursor as an opaque token for clients to send back.
+func (c TransactionCursor) String() string {
+	raw := c.Date.Format(time.DateOnly) + "|" + c.Amount.String() + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseTransactionCursor decodes a cursor encoded by String.
+func ParseTransactionCursor(s string) (*TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	date, err := time.Parse(time.DateOnly, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	amount, err := ParseAmount(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	return &TransactionCursor{Date: date, Amount: amount, ID: id}, nil
+}
@@ -0,0 +1,52 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TransactionQuery_Validate(t *testing.T) {
+	t.Parallel()
+
+	q := domain.TransactionQuery{}
+	assert.NoError(t, q.Validate())
+	assert.Equal(t, domain.SortByDate, q.SortBy)
+	assert.Equal(t, domain.DefaultPageSize, q.Limit)
+
+	from := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	min, max := domain.MustParseAmount("10"), domain.MustParseAmount("-10")
+
+	for _, bad := range []domain.TransactionQuery{
+		{Limit: domain.MaxPageSize + 1},
+		{Limit: -1},
+		{SortBy: "id"},
+		{Sign: "zero"},
+		{From: &from, To: &to},
+		{MinAmount: &min, MaxAmount: &max},
+	} {
+		assert.ErrorIs(t, bad.Validate(), domain.ErrInvalidQuery, "%+v", bad)
+	}
+}
+
+func Test_TransactionCursor_round_trip(t *testing.T) {
+	t.Parallel()
+
+	c := domain.CursorOf(&domain.Transaction{
+		ID:     42,
+		Date:   time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+		Amount: domain.MustParseAmount("-10.3"),
+	})
+
+	parsed, err := domain.ParseTransactionCursor(c.String())
+	assert.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	for _, bad := range []string{"", "not base64!", "MjAyNC0wNy0xNXwxMA"} {
+		_, err := domain.ParseTransactionCursor(bad)
+		assert.ErrorIs(t, err, domain.ErrInvalidQuery, bad)
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+)
+
+// ErrAccountExists is returned when opening an account number that is taken.
+var ErrAccountExists = errors.New("account already exists")
+
+// ErrBalanceMismatch is returned by AccountService.VerifyBalances when stored
+// balances differ from the recomputed ones and are not repaired.
+var ErrBalanceMismatch = errors.New("account balances do not match their transactions")
+
+// ErrAccountInactive is returned for postings to frozen or closed accounts.
+var ErrAccountInactive = errors.New("account is not active")
+
+type (
+	// AccountService opens accounts and moves them through their lifecycle.
+	AccountService struct {
+		log        *zap.SugaredLogger
+		Transactor Transactor
+	}
+
+	// BalanceReport is the result of verifying the stored balances.
+	// Mismatches holds the accounts whose stored balance differed from the
+	// recomputed one, as they were before being repaired.
+	BalanceReport struct {
+		Checked    int
+		Mismatches []domain.AccountBalance
+		Repaired   bool
+	}
+)
+
+func NewAccountService(log *zap.SugaredLogger, transactor Transactor) *AccountService {
+	return &AccountService{
+		log:        log,
+		Transactor: transactor,
+	}
+}
+
+// Open creates an active account with no balance. An empty currency means
+// DefaultCurrency.
+func (s *AccountService) Open(ctx context.Context, accountNumber string, currency string) (*domain.Account, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	account := &domain.Account{
+		AccountNumber: accountNumber,
+		Kind:          domain.AccountCustomer,
+		Currency:      currency,
+		Status:        domain.AccountActive,
+		OpenedAt:      time.Now(),
+	}
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		var err error
+		account, err = repos.Account.Insert(ctx, account)
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("%w: %s", ErrAccountExists, accountNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("error opening account: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("account opened", "account", accountNumber, "currency", currency)
+
+	return account, nil
+}
+
+// Freeze stops the account from accepting postings.
+func (s *AccountService) Freeze(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	return s.change(ctx, accountNumber, func(a *domain.Account) error {
+		return a.Freeze(time.Now())
+	})
+}
+
+// Unfreeze makes a frozen account accept postings again.
+func (s *AccountService) Unfreeze(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	return s.change(ctx, accountNumber, func(a *domain.Account) error {
+		return a.Unfreeze()
+	})
+}
+
+// Close closes an account without balance for good.
+func (s *AccountService) Close(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	return s.change(ctx, accountNumber, func(a *domain.Account) error {
+		return a.Close(time.Now())
+	})
+}
+
+// SetOverdraft sets the overdraft limit of the account, or removes it when
+// limit is nil, and the policy imports apply when it is exceeded.
+func (s *AccountService) SetOverdraft(ctx context.Context, accountNumber string, limit *domain.Amount, policy domain.OverdraftPolicy) (*domain.Account, error) {
+	return s.change(ctx, accountNumber, func(a *domain.Account) error {
+		return a.SetOverdraft(limit, policy)
+	})
+}
+
+// change applies fn to the locked account and stores the result, so a change
+// waits for the imports running on the account.
+func (s *AccountService) change(ctx context.Context, accountNumber string, fn func(*domain.Account) error) (*domain.Account, error) {
+	var account *domain.Account
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		var err error
+		account, err = repos.Account.GetByAccountNumberForUpdate(ctx, accountNumber)
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("%w: %s", ErrAccountNotFound, accountNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("error retrieving account: %w", err)
+		}
+
+		if err := fn(account); err != nil {
+			return err
+		}
+
+		if _, err := repos.Account.Update(ctx, account); err != nil {
+			return fmt.Errorf("error updating account: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("account changed", "account", accountNumber, "status", account.Status, "overdraft_limit", account.OverdraftLimit, "overdraft_policy", account.OverdraftPolicy)
+
+	return account, nil
+}
+
+// Get returns the account with the number, or ErrAccountNotFound.
+func (s *AccountService) Get(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	var account *domain.Account
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		var err error
+		account, err = repos.Account.GetByAccountNumber(ctx, accountNumber)
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("%w: %s", ErrAccountNotFound, accountNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("error retrieving account: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// List returns up to limit customer accounts in account number order,
+// starting after the given number. Zero means domain.DefaultPageSize.
+func (s *AccountService) List(ctx context.Context, after string, limit int) ([]*domain.Account, error) {
+	if limit == 0 {
+		limit = domain.DefaultPageSize
+	}
+	if limit < 0 || limit > domain.MaxPageSize {
+		return nil, fmt.Errorf("%w: limit %d out of 1..%d", domain.ErrInvalidQuery, limit, domain.MaxPageSize)
+	}
+
+	var accounts []*domain.Account
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		var err error
+		accounts, err = repos.Account.List(ctx, after, limit)
+		if err != nil {
+			return fmt.Errorf("error listing accounts: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// VerifyBalances recomputes the balance of every customer account from its
+// transactions and manual journal entries and compares it with the stored one.
+// With repair, accounts that do not match are locked, recomputed again, as an
+// import may have changed them, and updated, all in one transaction. Without
+// it, mismatches are returned with ErrBalanceMismatch next to the report.
+func (s *AccountService) VerifyBalances(ctx context.Context, repair bool) (*BalanceReport, error) {
+	report := &BalanceReport{Repaired: repair}
+
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		balances, err := repos.Account.ComputeBalances(ctx)
+		if err != nil {
+			return fmt.Errorf("error computing balances: %w", err)
+		}
+		report.Checked = len(balances)
+
+		for _, b := range balances {
+			if b.Difference() != 0 {
+				report.Mismatches = append(report.Mismatches, b)
+			}
+		}
+		if !repair {
+			return nil
+		}
+
+		for _, b := range report.Mismatches {
+			if err := repairBalance(ctx, repos, b.AccountNumber); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range report.Mismatches {
+		s.log.Warnw("balance does not match transactions", "account", b.AccountNumber, "stored", b.Stored, "expected", b.Expected(), "repaired", repair)
+	}
+	if len(report.Mismatches) > 0 && !repair {
+		return report, fmt.Errorf("%w: %d of %d accounts", ErrBalanceMismatch, len(report.Mismatches), report.Checked)
+	}
+
+	s.log.Infow("balances verified", "checked", report.Checked, "repaired", len(report.Mismatches))
+
+	return report, nil
+}
+
+// repairBalance locks the account and stores the balance recomputed once it
+// is locked.
+func repairBalance(ctx context.Context, repos Repositories, accountNumber string) error {
+	account, err := repos.Account.GetByAccountNumberForUpdate(ctx, accountNumber)
+	if err != nil {
+		return fmt.Errorf("error retrieving account: %w", err)
+	}
+
+	b, err := repos.Account.ComputeBalance(ctx, account.ID)
+	if err != nil {
+		return fmt.Errorf("error computing balance: %w", err)
+	}
+	if b.Expected() == account.Balance {
+		return nil
+	}
+
+	account.Balance = b.Expected()
+	if _, err := repos.Account.Update(ctx, account); err != nil {
+		return fmt.Errorf("error updating account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_AccountService_Open(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	h.accountRepository.ExpectedCalls = nil
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.MatchedBy(func(a *domain.Account) bool {
+		return a.AccountNumber == "777"
+	})).Return(&domain.Account{ID: 9, AccountNumber: "777", Currency: "EUR", Status: domain.AccountActive}, nil).Once()
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(nil, database.ErrDBDuplicatedEntry).Once()
+
+	account, err := service.NewAccountService(h.log, h.transactor).Open(h.ctx, "777", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), account.ID)
+
+	_, err = service.NewAccountService(h.log, h.transactor).Open(h.ctx, "123456", "")
+	assert.ErrorIs(t, err, service.ErrAccountExists)
+}
+
+func Test_AccountService_changes_status(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "555").Return(nil, database.ErrDBNotFound)
+
+	s := service.NewAccountService(h.log, h.transactor)
+
+	account, err := s.Freeze(h.ctx, "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.AccountFrozen, account.Status)
+	h.accountRepository.AssertCalled(t, "Update", mock.Anything, h.account)
+
+	_, err = s.Freeze(h.ctx, "123456")
+	assert.ErrorIs(t, err, domain.ErrInvalidStatusChange)
+
+	account, err = s.Unfreeze(h.ctx, "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.AccountActive, account.Status)
+
+	_, err = s.Close(h.ctx, "123456")
+	assert.ErrorIs(t, err, domain.ErrInvalidStatusChange, "the account has a balance")
+
+	_, err = s.Close(h.ctx, "555")
+	assert.ErrorIs(t, err, service.ErrAccountNotFound)
+}
+
+func Test_ProcessStatement_refuses_inactive_accounts(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "777").Return(&domain.Account{
+		ID:            2,
+		AccountNumber: "777",
+		Status:        domain.AccountClosed,
+	}, nil)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+
+	data := `Id,Date,Transaction,Account
+0,7/15,+60.5,123456
+1,7/28,-10.3,777
+`
+	format := service.DefaultCSVFormat()
+	format.AccountColumn = "Account"
+
+	_, err := h.service.ProcessStatement(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{})
+	assert.ErrorIs(t, err, service.ErrAccountInactive)
+	assert.ErrorContains(t, err, "account 777 is closed")
+
+	summary, err := h.service.ProcessStatement(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{OnError: service.OnErrorSkip})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.TransactionCount)
+	assert.Equal(t, 1, summary.RejectedCount)
+	assert.Contains(t, summary.Rejected[0].Reason, "account 777 is closed")
+
+	h.account.Status = domain.AccountFrozen
+	_, err = h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{OnError: service.OnErrorSkip})
+	assert.ErrorIs(t, err, service.ErrAccountInactive)
+}
+
+func Test_AccountService_SetOverdraft(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	s := service.NewAccountService(h.log, h.transactor)
+	limit := domain.MustParseAmount("250")
+
+	account, err := s.SetOverdraft(h.ctx, "123456", &limit, domain.OverdraftRejectRow)
+	assert.NoError(t, err)
+	assert.Equal(t, limit, *account.OverdraftLimit)
+	assert.Equal(t, domain.OverdraftRejectRow, account.OverdraftPolicy)
+
+	_, err = s.SetOverdraft(h.ctx, "123456", &limit, "ignore")
+	assert.ErrorIs(t, err, domain.ErrInvalidOverdraft)
+	h.accountRepository.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func Test_AccountService_VerifyBalances(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	mismatch := domain.AccountBalance{
+		AccountID:     1,
+		AccountNumber: "123456",
+		Currency:      "USD",
+		Stored:        domain.MustParseAmount("10"),
+		Transactions:  domain.MustParseAmount("8"),
+		Adjustments:   domain.MustParseAmount("1"),
+	}
+	h.accountRepository.EXPECT().ComputeBalances(mock.Anything).Return([]domain.AccountBalance{
+		mismatch,
+		{AccountID: 2, AccountNumber: "777", Currency: "USD", Stored: domain.MustParseAmount("5"), Transactions: domain.MustParseAmount("5")},
+	}, nil)
+	h.accountRepository.EXPECT().ComputeBalance(mock.Anything, int64(1)).Return(&mismatch, nil)
+
+	s := service.NewAccountService(h.log, h.transactor)
+
+	report, err := s.VerifyBalances(h.ctx, false)
+	assert.ErrorIs(t, err, service.ErrBalanceMismatch)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, []domain.AccountBalance{mismatch}, report.Mismatches)
+	assert.Equal(t, domain.MustParseAmount("1"), report.Mismatches[0].Difference())
+	h.accountRepository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	report, err = s.VerifyBalances(h.ctx, true)
+	assert.NoError(t, err)
+	assert.True(t, report.Repaired)
+	assert.Len(t, report.Mismatches, 1)
+	assert.Equal(t, domain.MustParseAmount("9"), h.account.Balance)
+	h.accountRepository.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func Test_AccountService_List(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().List(mock.Anything, "123", domain.DefaultPageSize).Return([]*domain.Account{h.account}, nil)
+
+	s := service.NewAccountService(h.log, h.transactor)
+
+	accounts, err := s.List(h.ctx, "123", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.Account{h.account}, accounts)
+
+	_, err = s.List(h.ctx, "", domain.MaxPageSize+1)
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+}
+
+func Test_AccountService_Get(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "555").Return(nil, database.ErrDBNotFound)
+
+	s := service.NewAccountService(h.log, h.transactor)
+
+	account, err := s.Get(h.ctx, "123456")
+	assert.NoError(t, err)
+	assert.Same(t, h.account, account)
+
+	_, err = s.Get(h.ctx, "555")
+	assert.ErrorIs(t, err, service.ErrAccountNotFound)
+}
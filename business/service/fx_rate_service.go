@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidFXRate is returned when a line of a rates file can not be read.
+var ErrInvalidFXRate = errors.New("invalid fx rate")
+
+// fxRateColumns are the columns expected in the header of a rates file.
+var fxRateColumns = []string{"date", "base", "quote", "rate"}
+
+// FXRateService loads the exchange rates used to convert foreign currency
+// transactions.
+type FXRateService struct {
+	log        *zap.SugaredLogger
+	Transactor Transactor
+}
+
+func NewFXRateService(log *zap.SugaredLogger, transactor Transactor) *FXRateService {
+	return &FXRateService{
+		log:        log,
+		Transactor: transactor,
+	}
+}
+
+// LoadRates stores the rates of a CSV file with date, base, quote and rate
+// columns, where rate is the value of one unit of base in quote on the
+// YYYY-MM-DD date. Rates already loaded for a date are replaced. The file is
+// loaded in one database transaction, so a bad line loads nothing.
+func (s *FXRateService) LoadRates(ctx context.Context, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("error reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	idx := make([]int, len(fxRateColumns))
+	for i, name := range fxRateColumns {
+		c, ok := columns[name]
+		if !ok {
+			return 0, fmt.Errorf("%w %q in header %v", ErrMissingColumn, name, header)
+		}
+		idx[i] = c
+	}
+
+	count := 0
+	err = s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		for {
+			fields, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading from file: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+
+			rate, err := parseFXRate(fields, idx)
+			if err != nil {
+				return fmt.Errorf("error reading from file: line %d: %w", line, err)
+			}
+
+			if _, err := repos.FXRate.Upsert(ctx, rate); err != nil {
+				return fmt.Errorf("error storing fx rate: %w", err)
+			}
+			count++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Infow("fx rates loaded", "count", count)
+
+	return count, nil
+}
+
+// parseFXRate reads the date, base, quote and rate columns at idx.
+func parseFXRate(fields []string, idx []int) (*domain.FXRate, error) {
+	values := make([]string, len(idx))
+	for i, c := range idx {
+		if c >= len(fields) {
+			return nil, fmt.Errorf("%w: expected at least %d fields, received %d", ErrInvalidFXRate, c+1, len(fields))
+		}
+		values[i] = strings.TrimSpace(fields[c])
+	}
+
+	date, err := time.Parse(time.DateOnly, values[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: unsupported date %q", ErrInvalidFXRate, values[0])
+	}
+
+	base, quote := strings.ToUpper(values[1]), strings.ToUpper(values[2])
+	for _, code := range []string{base, quote} {
+		if !isCurrencyCode(code) {
+			return nil, fmt.Errorf("%w: unsupported currency %q", ErrInvalidFXRate, code)
+		}
+	}
+	if base == quote {
+		return nil, fmt.Errorf("%w: %s to itself", ErrInvalidFXRate, base)
+	}
+
+	rate, err := domain.ParseRate(values[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFXRate, err)
+	}
+
+	return &domain.FXRate{
+		Date:          date,
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          rate,
+	}, nil
+}
+
+// isCurrencyCode tells whether s looks like an ISO 4217 code.
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
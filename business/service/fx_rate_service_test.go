@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_LoadRates_stores_every_line(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	var rates []domain.FXRate
+	h.fxRateRepository.EXPECT().Upsert(mock.Anything, mock.AnythingOfType("*domain.FXRate")).RunAndReturn(func(_ context.Context, r *domain.FXRate) (*domain.FXRate, error) {
+		rates = append(rates, *r)
+		return r, nil
+	})
+
+	data := `Date,Base,Quote,Rate
+2024-07-15,USD,EUR,0.9215
+2024-07-15,usd,ars,915.75
+`
+	count, err := service.NewFXRateService(h.log, h.transactor).LoadRates(h.ctx, strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []domain.FXRate{
+		{Date: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: domain.MustParseRate("0.9215")},
+		{Date: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), BaseCurrency: "USD", QuoteCurrency: "ARS", Rate: domain.MustParseRate("915.75")},
+	}, rates)
+}
+
+func Test_LoadRates_fails_on_bad_line(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.fxRateRepository.EXPECT().Upsert(mock.Anything, mock.AnythingOfType("*domain.FXRate")).RunAndReturn(func(_ context.Context, r *domain.FXRate) (*domain.FXRate, error) {
+		return r, nil
+	})
+
+	for _, data := range []string{
+		"date,base,quote,rate\n2024-07-15,USD,EUR,0.92\n2024-07-16,USD,EURO,0.92\n",
+		"date,base,quote,rate\n2024-07-15,USD,USD,1\n",
+		"date,base,quote,rate\n7/15/2024,USD,EUR,0.92\n",
+		"date,base,quote,rate\n2024-07-15,USD,EUR,-0.92\n",
+	} {
+		_, err := service.NewFXRateService(h.log, h.transactor).LoadRates(h.ctx, strings.NewReader(data))
+		assert.ErrorIs(t, err, service.ErrInvalidFXRate, data)
+	}
+
+	_, err := service.NewFXRateService(h.log, h.transactor).LoadRates(h.ctx, strings.NewReader("day,base,quote,rate\n"))
+	assert.ErrorIs(t, err, service.ErrMissingColumn)
+}
@@ -0,0 +1,766 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"runtime"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+)
+
+type (
+	// statementImport holds the state of a statement being imported.
+	statementImport struct {
+		s              *TransactionService
+		repos          Repositories
+		opts           ImportOptions
+		route          bool
+		defaultAccount string
+		processedAt    time.Time
+		accounts       map[string]*accountImport
+		order          []*accountImport
+		rates          map[rateKey]domain.Rate
+		clearing       map[string]*domain.Account
+		pending        []pendingTransaction
+		processed      int
+		stored         chan []pendingTransaction
+		summary        ImportSummary
+	}
+
+	// pendingTransaction is a parsed row waiting to be stored.
+	pendingTransaction struct {
+		ai  *accountImport
+		rec StatementRecord
+		txn *domain.Transaction
+	}
+
+	// rateKey identifies a conversion looked up during an import.
+	rateKey struct {
+		from string
+		to   string
+		date time.Time
+	}
+
+	// accountImport holds the state of the rows of a statement that go to
+	// one account.
+	accountImport struct {
+		account *domain.Account
+		// clearing is the account on the other side of the ledger entries.
+		clearing *domain.Account
+		// batch is started when the first row of the account is stored.
+		batch *domain.IngestionBatch
+		stats AccountStats
+		// balance is the running balance of the rows processed so far, as
+		// stats only get the rows once they are stored.
+		balance     domain.Amount
+		breaches    []OverdraftBreach
+		seen        map[string]struct{}
+		contentHash hash.Hash
+		// rows is the number of rows in the content hash.
+		rows int
+	}
+)
+
+// importStatement runs a whole import inside one database transaction and
+// notifies the stats of every account once it is committed. When route is
+// false every row goes to defaultAccount.
+func (s *TransactionService) importStatement(ctx context.Context, defaultAccount string, reader StatementReader, opts ImportOptions, route bool) (*ImportSummary, error) {
+	switch opts.OnError {
+	case "":
+		opts.OnError = OnErrorAbort
+	case OnErrorAbort, OnErrorSkip:
+	default:
+		return nil, fmt.Errorf("unknown on error mode %q", opts.OnError)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	var summary ImportSummary
+
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		imp := &statementImport{
+			s:              s,
+			repos:          repos,
+			opts:           opts,
+			route:          route,
+			defaultAccount: defaultAccount,
+			processedAt:    time.Now(),
+			accounts:       make(map[string]*accountImport),
+			rates:          make(map[rateKey]domain.Rate),
+			clearing:       make(map[string]*domain.Account),
+			pending:        make([]pendingTransaction, 0, opts.BatchSize),
+		}
+		if err := imp.run(ctx, reader); err != nil {
+			return err
+		}
+		summary = imp.summary
+		if opts.DryRun {
+			summary.DryRun = true
+			for _, stats := range summary.Accounts {
+				stats.DryRun = true
+			}
+			return errDryRun
+		}
+		if opts.OnCommit != nil {
+			return opts.OnCommit(repos, &summary)
+		}
+		return nil
+	})
+	switch {
+	case opts.DryRun && errors.Is(err, errDryRun):
+		return &summary, nil
+	case err != nil:
+		return nil, err
+	}
+
+	for _, stats := range summary.Accounts {
+		if err := s.NotificationsRepository.Notify(*stats); err != nil {
+			return nil, fmt.Errorf("error notifying: %w", err)
+		}
+		if len(stats.OverdraftBreaches) == 0 {
+			continue
+		}
+		err := s.NotificationsRepository.Alert(OverdraftAlert{
+			AccountNumber: stats.AccountNumber,
+			Currency:      stats.Currency,
+			Balance:       stats.Balance,
+			Breaches:      stats.OverdraftBreaches,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error sending overdraft alert: %w", err)
+		}
+	}
+
+	return &summary, nil
+}
+
+func (imp *statementImport) run(ctx context.Context, reader StatementReader) error {
+	if !imp.route {
+		// A single account import always touches the account, even when the
+		// file has no rows.
+		if _, err := imp.accountFor(ctx, imp.defaultAccount); err != nil {
+			return err
+		}
+	}
+
+	if err := imp.pipeline(ctx, reader); err != nil {
+		return err
+	}
+
+	if br, ok := reader.(BalanceReporter); ok {
+		if err := imp.setStatementBalances(br.Balances()); err != nil {
+			return err
+		}
+	}
+
+	for _, ai := range imp.order {
+		if err := imp.finishAccount(ctx, ai); err != nil {
+			return err
+		}
+	}
+
+	if !imp.route {
+		imp.order[0].stats.RejectedCount = imp.summary.RejectedCount
+		imp.order[0].stats.Rejected = imp.summary.Rejected
+	}
+
+	return nil
+}
+
+// process applies one parsed record to its account and queues it to be
+// stored. Records come in file order.
+func (imp *statementImport) process(ctx context.Context, p parsedRecord) error {
+	var rowErr *StatementRowError
+	if errors.As(p.readErr, &rowErr) && imp.opts.OnError == OnErrorSkip {
+		if !imp.route {
+			imp.order[0].hashRecord(rowErr.Raw)
+		}
+		imp.reject(rowErr.Line, rowErr.Raw, rowErr.Err)
+		return nil
+	}
+	if p.readErr != nil {
+		return fmt.Errorf("error reading from file: %w", p.readErr)
+	}
+
+	rec, txn := p.rec, p.txn
+	accountNumber := imp.defaultAccount
+	if imp.route && rec.Account != "" {
+		accountNumber = rec.Account
+	}
+
+	ai, err := imp.accountFor(ctx, accountNumber)
+	if (errors.Is(err, ErrAccountNotFound) || errors.Is(err, ErrAccountInactive)) && imp.opts.OnError == OnErrorSkip {
+		imp.reject(rec.Line, rec.Raw, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("line %d: %w", rec.Line, err)
+	}
+	ai.hashRecord(rec.Raw)
+
+	txn.AccountID = ai.account.ID
+	err = p.parseErr
+	if err == nil {
+		err = imp.convert(ctx, ai, txn)
+	}
+	if err != nil {
+		if imp.opts.OnError == OnErrorSkip && !isFatal(err) {
+			imp.reject(rec.Line, rec.Raw, err)
+			return nil
+		}
+		if isFatal(err) {
+			return fmt.Errorf("error reading from file: line %d: %w", rec.Line, err)
+		}
+		return fmt.Errorf("error reading from file: %w", &StatementRowError{Line: rec.Line, Raw: rec.Raw, Err: err})
+	}
+
+	if _, ok := ai.seen[txn.FileTransactionID]; ok {
+		imp.s.log.Warnw("skipping duplicated transaction", "account", ai.account.AccountNumber, "file_transaction_id", txn.FileTransactionID)
+		ai.skipDuplicate(txn)
+		return nil
+	}
+	ai.seen[txn.FileTransactionID] = struct{}{}
+
+	imp.pending = append(imp.pending, pendingTransaction{ai: ai, rec: rec, txn: txn})
+	if len(imp.pending) < imp.opts.BatchSize {
+		return nil
+	}
+
+	return imp.flush(ctx)
+}
+
+// checkOverdraft moves the running balance of the account by txn and applies
+// the overdraft policy of the account when it goes below the limit. Only
+// debits are checked, so rows paying back an account already over its limit
+// go through. It tells whether the row was rejected, and fails when the policy
+// rejects the file.
+func (imp *statementImport) checkOverdraft(ai *accountImport, rec StatementRecord, txn *domain.Transaction) (bool, error) {
+	balance := ai.balance + txn.Amount
+	if !txn.Amount.IsNegative() || !ai.account.ExceedsOverdraft(balance) {
+		ai.balance = balance
+		return false, nil
+	}
+
+	breach := OverdraftBreach{
+		Line:              rec.Line,
+		FileTransactionID: txn.FileTransactionID,
+		Amount:            txn.Amount,
+		Balance:           balance,
+		Limit:             *ai.account.OverdraftLimit,
+		Policy:            ai.account.OverdraftPolicy,
+	}
+	err := fmt.Errorf("%w: account %s balance would be %s, limit is %s", ErrOverdraftLimitExceeded, ai.account.AccountNumber, balance, breach.Limit)
+	imp.s.log.Warnw("overdraft limit exceeded", "account", ai.account.AccountNumber, "line", rec.Line, "balance", balance, "limit", breach.Limit, "policy", breach.Policy)
+
+	switch breach.Policy {
+	case domain.OverdraftFlag:
+		ai.balance = balance
+	case domain.OverdraftRejectRow:
+		breach.Rejected = true
+		imp.reject(rec.Line, rec.Raw, err)
+	default:
+		return false, err
+	}
+	ai.breaches = append(ai.breaches, breach)
+
+	return breach.Rejected, nil
+}
+
+// flush stores the queued transactions and hands them to the stats
+// aggregator. Rows already imported by an earlier batch are skipped before
+// the overdraft of the rest is checked, so they do not move the balance.
+func (imp *statementImport) flush(ctx context.Context) error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+
+	imported, err := imp.importedRows(ctx)
+	if err != nil {
+		return err
+	}
+
+	stored := make([]pendingTransaction, 0, len(imp.pending))
+	for _, p := range imp.pending {
+		if _, ok := imported[p.ai][p.txn.FileTransactionID]; ok {
+			imp.s.log.Warnw("skipping already imported transaction", "account", p.ai.account.AccountNumber, "file_transaction_id", p.txn.FileTransactionID)
+			p.ai.skipDuplicate(p.txn)
+			continue
+		}
+
+		rejected, err := imp.checkOverdraft(p.ai, p.rec, p.txn)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", p.rec.Line, err)
+		}
+		if rejected {
+			continue
+		}
+
+		if err := imp.startBatch(ctx, p.ai); err != nil {
+			return err
+		}
+		p.txn.BatchID = p.ai.batch.ID
+		stored = append(stored, p)
+	}
+	imp.pending = make([]pendingTransaction, 0, imp.opts.BatchSize)
+
+	if len(stored) > 0 {
+		if err := imp.store(ctx, stored); err != nil {
+			return err
+		}
+	}
+	imp.progress()
+
+	return nil
+}
+
+// store inserts the transactions with their ledger entries.
+func (imp *statementImport) store(ctx context.Context, stored []pendingTransaction) error {
+	txns := make([]*domain.Transaction, len(stored))
+	for i, p := range stored {
+		txns[i] = p.txn
+	}
+	if err := imp.repos.Transaction.BulkInsert(ctx, txns); err != nil {
+		return fmt.Errorf("error storing transactions: %w", err)
+	}
+
+	entries := make([]*domain.JournalEntry, len(stored))
+	for i, p := range stored {
+		entries[i] = domain.NewTransactionEntry(p.txn, p.ai.account, p.ai.clearing, imp.processedAt)
+	}
+	if err := imp.repos.Ledger.Post(ctx, entries); err != nil {
+		return fmt.Errorf("error posting ledger entries: %w", err)
+	}
+
+	select {
+	case imp.stored <- stored:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+
+	return nil
+}
+
+// importedRows looks up which of the queued rows were already imported into
+// their account by an earlier batch.
+func (imp *statementImport) importedRows(ctx context.Context) (map[*accountImport]map[string]struct{}, error) {
+	ids := make(map[*accountImport][]string)
+	for _, p := range imp.pending {
+		ids[p.ai] = append(ids[p.ai], p.txn.FileTransactionID)
+	}
+
+	imported := make(map[*accountImport]map[string]struct{}, len(ids))
+	for ai, fileIDs := range ids {
+		found, err := imp.repos.Transaction.ImportedFileIDs(ctx, ai.account.ID, fileIDs)
+		if err != nil {
+			return nil, fmt.Errorf("error checking imported transactions: %w", err)
+		}
+		imported[ai] = make(map[string]struct{}, len(found))
+		for _, id := range found {
+			imported[ai][id] = struct{}{}
+		}
+	}
+
+	return imported, nil
+}
+
+// progress reports the rows read so far to ImportOptions.OnProgress.
+func (imp *statementImport) progress() {
+	if imp.opts.OnProgress != nil {
+		imp.opts.OnProgress(imp.processed, imp.summary.RejectedCount)
+	}
+}
+
+// accountFor returns the import state of the account, looking the account up
+// or creating it the first time it is seen.
+func (imp *statementImport) accountFor(ctx context.Context, accountNumber string) (*accountImport, error) {
+	if ai, ok := imp.accounts[accountNumber]; ok {
+		return ai, nil
+	}
+
+	// The account stays locked until the import ends, so the balance stored
+	// by finishAccount is computed from the latest committed one.
+	account, err := imp.repos.Account.GetByAccountNumberForUpdate(ctx, accountNumber)
+	switch {
+	case errors.Is(err, database.ErrDBNotFound):
+		account, err = imp.createAccount(ctx, accountNumber)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("error retrieving account: %w", err)
+	}
+	if !account.IsActive() {
+		return nil, fmt.Errorf("%w: account %s is %s", ErrAccountInactive, accountNumber, account.Status)
+	}
+	if account.IsClearing() {
+		return nil, fmt.Errorf("%w: account %s is a clearing account", ErrAccountInactive, accountNumber)
+	}
+	if account.Currency == "" {
+		account.Currency = DefaultCurrency
+	}
+	clearing, err := imp.clearingFor(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if account.OverdraftPolicy == "" {
+		account.OverdraftPolicy = domain.OverdraftRejectFile
+	}
+
+	ai := &accountImport{
+		account:  account,
+		clearing: clearing,
+		stats: AccountStats{
+			AccountNumber:        account.AccountNumber,
+			Currency:             account.Currency,
+			Balance:              account.Balance,
+			FileBalance:          0,
+			TransactionCount:     0,
+			TransactionsPerMonth: [12]int{0},
+			DebitCount:           0,
+			DebitTotal:           0,
+			DebitAvg:             0,
+			CreditCount:          0,
+			CreditTotal:          0,
+			CreditAvg:            0,
+			CurrencyTotals:       make(map[string]CurrencyTotal),
+		},
+		balance:     account.Balance,
+		seen:        make(map[string]struct{}),
+		contentHash: sha256.New(),
+	}
+
+	imp.accounts[accountNumber] = ai
+	imp.order = append(imp.order, ai)
+	imp.summary.Accounts = append(imp.summary.Accounts, &ai.stats)
+
+	return ai, nil
+}
+
+// createAccount creates an account named by the statement, unless unknown
+// accounts are rejected.
+func (imp *statementImport) createAccount(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	if imp.opts.RejectUnknownAccounts {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, accountNumber)
+	}
+
+	currency := imp.opts.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	account, err := imp.repos.Account.Insert(ctx, &domain.Account{
+		AccountNumber: accountNumber,
+		Kind:          domain.AccountCustomer,
+		Currency:      currency,
+		Balance:       0,
+		Status:        domain.AccountActive,
+		OpenedAt:      imp.processedAt,
+	})
+	if errors.Is(err, database.ErrDBDuplicatedEntry) {
+		// Another import created it in the meantime.
+		account, err = imp.repos.Account.GetByAccountNumberForUpdate(ctx, accountNumber)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating account: %w", err)
+	}
+
+	imp.s.log.Infow("account created", "account", accountNumber, "currency", currency)
+
+	return account, nil
+}
+
+// clearingFor returns the clearing account of the currency, reading it once
+// per import.
+func (imp *statementImport) clearingFor(ctx context.Context, currency string) (*domain.Account, error) {
+	if clearing, ok := imp.clearing[currency]; ok {
+		return clearing, nil
+	}
+
+	clearing, err := clearingAccount(ctx, imp.repos, currency, imp.processedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	imp.clearing[currency] = clearing
+	return clearing, nil
+}
+
+// clearingAccount returns the clearing account of the currency, creating it
+// the first time the currency is used. It is not locked, as its balance is
+// only kept in the ledger and postings in the same currency would wait for
+// each other otherwise.
+func clearingAccount(ctx context.Context, repos Repositories, currency string, now time.Time) (*domain.Account, error) {
+	accountNumber := domain.ClearingAccountNumber(currency)
+	clearing, err := repos.Account.GetByAccountNumber(ctx, accountNumber)
+	if errors.Is(err, database.ErrDBNotFound) {
+		clearing, err = repos.Account.Insert(ctx, &domain.Account{
+			AccountNumber: accountNumber,
+			Kind:          domain.AccountClearing,
+			Currency:      currency,
+			Status:        domain.AccountActive,
+			OpenedAt:      now,
+		})
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			clearing, err = repos.Account.GetByAccountNumber(ctx, accountNumber)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving clearing account: %w", err)
+	}
+
+	return clearing, nil
+}
+
+// startBatch starts the ingestion batch of the account, the first time one
+// of its rows is stored.
+func (imp *statementImport) startBatch(ctx context.Context, ai *accountImport) error {
+	if ai.batch != nil {
+		return nil
+	}
+
+	batch, err := imp.repos.Batch.Insert(ctx, &domain.IngestionBatch{
+		AccountID: ai.account.ID,
+		StartedAt: imp.processedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error starting ingestion batch: %w", err)
+	}
+	ai.batch = batch
+	ai.stats.BatchID = batch.ID
+
+	return nil
+}
+
+// setStatementBalances keeps the balances the bank reported for each account
+// and checks the computed balance against the closing one. A single account
+// import takes the only balances in the file, as the file may name the account
+// differently.
+func (imp *statementImport) setStatementBalances(balances map[string]StatementBalances) error {
+	for _, ai := range imp.order {
+		b, ok := balances[ai.account.AccountNumber]
+		if !ok && !imp.route && len(balances) == 1 {
+			for _, only := range balances {
+				b, ok = only, true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		ai.stats.StatementOpeningBalance = b.Opening
+		ai.stats.StatementBalance = b.Closing
+		if b.Closing == nil {
+			continue
+		}
+		if b.Currency != "" && b.Currency != ai.account.Currency {
+			imp.s.log.Warnw("not reconciling balance in another currency", "account", ai.account.AccountNumber, "currency", ai.account.Currency, "statement_currency", b.Currency)
+			continue
+		}
+
+		ai.stats.BalanceDifference = *b.Closing - ai.stats.Balance
+		ai.stats.Reconciled = ai.stats.BalanceDifference == 0
+		if ai.stats.Reconciled {
+			continue
+		}
+
+		imp.s.log.Warnw("balance does not match the statement", "account", ai.account.AccountNumber, "balance", ai.stats.Balance, "statement_balance", *b.Closing)
+		if imp.opts.RequireBalanceMatch {
+			return fmt.Errorf("%w: account %s balance %s, statement %s", ErrStatementBalanceMismatch, ai.account.AccountNumber, ai.stats.Balance, *b.Closing)
+		}
+	}
+
+	return nil
+}
+
+// convert moves the amount of txn, in the currency of the statement, to the
+// currency of the account at the rate of the transaction date. Rows that do not
+// name a currency are in the one of the account.
+func (imp *statementImport) convert(ctx context.Context, ai *accountImport, txn *domain.Transaction) error {
+	if txn.Currency == "" {
+		txn.Currency = ai.account.Currency
+	}
+
+	rate, err := imp.rate(ctx, txn.Currency, ai.account.Currency, txn.Date)
+	if err != nil {
+		return err
+	}
+
+	txn.OriginalAmount = txn.Amount
+	txn.FXRate = rate
+	txn.Amount = txn.Amount.Convert(rate)
+
+	return nil
+}
+
+// rate returns the rate from one currency to another on date, using the
+// inverse of the opposite rate when only that one was loaded.
+func (imp *statementImport) rate(ctx context.Context, from string, to string, date time.Time) (domain.Rate, error) {
+	if from == to {
+		return domain.OneRate, nil
+	}
+
+	key := rateKey{from: from, to: to, date: date}
+	if rate, ok := imp.rates[key]; ok {
+		return rate, nil
+	}
+
+	fx, err := imp.repos.FXRate.GetRate(ctx, from, to, date)
+	var rate domain.Rate
+	switch {
+	case err == nil:
+		rate = fx.Rate
+	case errors.Is(err, database.ErrDBNotFound):
+		fx, err = imp.repos.FXRate.GetRate(ctx, to, from, date)
+		switch {
+		case err == nil:
+			rate = fx.Rate.Invert()
+		case errors.Is(err, database.ErrDBNotFound):
+			return 0, fmt.Errorf("%w: %s to %s on %s", ErrFXRateNotFound, from, to, date.Format(time.DateOnly))
+		default:
+			return 0, &fatalError{fmt.Errorf("error reading fx rate: %w", err)}
+		}
+	default:
+		return 0, &fatalError{fmt.Errorf("error reading fx rate: %w", err)}
+	}
+
+	imp.rates[key] = rate
+	return rate, nil
+}
+
+// finishAccount closes the ingestion batch of the account and stores its new
+// balance.
+func (imp *statementImport) finishAccount(ctx context.Context, ai *accountImport) error {
+	ai.account.Balance = ai.stats.Balance
+	ai.stats.OverdraftBreaches = ai.breaches
+
+	if err := imp.finishBatch(ctx, ai); err != nil {
+		return err
+	}
+
+	if _, err := imp.repos.Account.Update(ctx, ai.account); err != nil {
+		return fmt.Errorf("error updating account: %w", err)
+	}
+
+	return nil
+}
+
+// reject records a row left out of the import.
+func (imp *statementImport) reject(line int, raw string, reason error) {
+	imp.s.log.Warnw("rejecting row", "line", line, "raw", raw, "reason", reason)
+	imp.summary.RejectedCount++
+	imp.summary.Rejected = append(imp.summary.Rejected, RejectedRow{
+		Line:   line,
+		Raw:    raw,
+		Reason: reason.Error(),
+	})
+}
+
+// fatalError marks errors that fail the import even when bad rows are
+// skipped, as they do not come from the row itself.
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+func isFatal(err error) bool {
+	var fatal *fatalError
+	return errors.As(err, &fatal)
+}
+
+func (ai *accountImport) hashRecord(raw string) {
+	ai.contentHash.Write([]byte(raw))
+	ai.contentHash.Write([]byte("\n"))
+	ai.rows++
+}
+
+// skipDuplicate records a row whose id was already imported into the account.
+func (ai *accountImport) skipDuplicate(txn *domain.Transaction) {
+	ai.stats.DuplicatesSkipped++
+	ai.stats.DuplicateIDs = append(ai.stats.DuplicateIDs, txn.FileTransactionID)
+}
+
+// apply adds a stored transaction to the stats.
+func (stats *AccountStats) apply(txn *domain.Transaction) {
+	stats.Balance += txn.Amount
+	stats.FileBalance += txn.Amount
+
+	if stats.CurrencyTotals == nil {
+		stats.CurrencyTotals = make(map[string]CurrencyTotal)
+	}
+	total := stats.CurrencyTotals[txn.Currency]
+	total.Count++
+	total.Total += txn.OriginalAmount
+	total.Converted += txn.Amount
+	stats.CurrencyTotals[txn.Currency] = total
+
+	stats.TransactionCount++
+	stats.TransactionsPerMonth[txn.Date.Month()-1]++
+	if txn.Amount.IsNegative() {
+		stats.CreditCount++
+		stats.CreditTotal += txn.Amount
+		stats.CreditAvg = stats.CreditTotal.DivRound(int64(stats.CreditCount))
+	} else {
+		stats.DebitCount++
+		stats.DebitTotal += txn.Amount
+		stats.DebitAvg = stats.DebitTotal.DivRound(int64(stats.DebitCount))
+	}
+
+	if len(stats.RecentTransactions) == RecentTransactionsLimit {
+		stats.RecentTransactions = stats.RecentTransactions[1:]
+	}
+	stats.RecentTransactions = append(stats.RecentTransactions, *txn)
+}
+
+// finishBatch rejects the content of the account when it was already imported
+// into it, and then stores the batch with the hash of the content. Rows found
+// in earlier batches are skipped before they are stored, so nothing of a file
+// sent again is stored before it is rejected. Files without rows keep no hash,
+// as they would all match each other.
+func (imp *statementImport) finishBatch(ctx context.Context, ai *accountImport) error {
+	var contentHash string
+	if ai.rows > 0 {
+		contentHash = hex.EncodeToString(ai.contentHash.Sum(nil))
+		previous, err := imp.repos.Batch.GetByContentHash(ctx, ai.account.ID, contentHash)
+		switch {
+		case err == nil:
+			return fmt.Errorf("%w: batch %d", ErrFileAlreadyImported, previous.ID)
+		case !errors.Is(err, database.ErrDBNotFound):
+			return fmt.Errorf("error checking ingestion batch: %w", err)
+		}
+	}
+
+	if err := imp.startBatch(ctx, ai); err != nil {
+		return err
+	}
+
+	finishedAt := time.Now()
+	ai.batch.ContentHash = contentHash
+	ai.batch.RowCount = ai.stats.TransactionCount
+	ai.batch.FinishedAt = &finishedAt
+
+	if _, err := imp.repos.Batch.Update(ctx, ai.batch); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("%w: %w", ErrFileAlreadyImported, err)
+		}
+		return fmt.Errorf("error finishing ingestion batch: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+)
+
+// ErrImportJobNotFound is returned for import jobs that do not exist.
+var ErrImportJobNotFound = errors.New("import job not found")
+
+// errLeaseLost stops the import of a job that was handed to another worker
+// once its lease expired.
+var errLeaseLost = errors.New("import job lease lost")
+
+const (
+	// DefaultJobLease is how long a worker holds a job between heartbeats
+	// when ImportJobConfig.Lease is not set.
+	DefaultJobLease = time.Minute
+	// DefaultJobMaxAttempts is the number of times a job is claimed before
+	// it fails, when ImportJobConfig.MaxAttempts is not set.
+	DefaultJobMaxAttempts = 3
+	// DefaultJobPollInterval is how often idle workers look for jobs when
+	// ImportJobConfig.PollInterval is not set.
+	DefaultJobPollInterval = time.Second
+)
+
+type (
+	// ImportJobService queues statements to be imported in the background
+	// and runs the workers importing them.
+	ImportJobService struct {
+		log                 *zap.SugaredLogger
+		ImportJobRepository ImportJobRepository
+		transactions        *TransactionService
+		cfg                 ImportJobConfig
+	}
+
+	// ImportJobConfig tunes the workers.
+	ImportJobConfig struct {
+		// CSVFormat is the layout of the CSV statements. Its account column
+		// is not used, as the account is named by the job.
+		CSVFormat CSVFormat
+		// Import holds the options of every import. OnError, DryRun,
+		// RequireBalanceMatch and Currency are taken from the job.
+		Import ImportOptions
+		// Lease is how long a job stays with its worker without a
+		// heartbeat. Workers send one every third of it, so a job is
+		// claimed again once its worker stopped for that long. Zero means
+		// DefaultJobLease.
+		Lease time.Duration
+		// MaxAttempts is the number of times a job is claimed before it
+		// fails, so a statement that stops its worker every time does not
+		// block the queue. Zero means DefaultJobMaxAttempts.
+		MaxAttempts int
+		// PollInterval is how often idle workers look for jobs. Zero means
+		// DefaultJobPollInterval.
+		PollInterval time.Duration
+	}
+)
+
+func NewImportJobService(log *zap.SugaredLogger, importJobRepository ImportJobRepository, transactions *TransactionService, cfg ImportJobConfig) *ImportJobService {
+	if cfg.Lease <= 0 {
+		cfg.Lease = DefaultJobLease
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultJobMaxAttempts
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultJobPollInterval
+	}
+	cfg.CSVFormat.AccountColumn = ""
+
+	return &ImportJobService{
+		log:                 log,
+		ImportJobRepository: importJobRepository,
+		transactions:        transactions,
+		cfg:                 cfg,
+	}
+}
+
+// Submit queues the statement of the job to be imported by a worker. An empty
+// format is detected from the content, and an empty OnError means
+// OnErrorAbort.
+func (s *ImportJobService) Submit(ctx context.Context, job *domain.ImportJob) (*domain.ImportJob, error) {
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
+	switch job.OnError {
+	case "":
+		job.OnError = OnErrorAbort
+	case OnErrorAbort, OnErrorSkip:
+	default:
+		return nil, fmt.Errorf("%w: unknown on error mode %q", domain.ErrInvalidImportJob, job.OnError)
+	}
+	switch job.Format {
+	case "":
+		job.Format = FormatAuto
+	case FormatAuto, FormatCSV, FormatOFX, FormatCAMT053, FormatMT940:
+	default:
+		return nil, fmt.Errorf("%w: unknown statement format %q", domain.ErrInvalidImportJob, job.Format)
+	}
+	job.Status = domain.ImportJobQueued
+	job.CreatedAt = time.Now()
+
+	job, err := s.ImportJobRepository.Insert(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("error queueing import job: %w", err)
+	}
+
+	s.log.Infow("import job queued", "job", job.ID, "account", job.AccountNumber, "size", len(job.Content))
+
+	return job, nil
+}
+
+// Get returns the status of the job, without its content.
+func (s *ImportJobService) Get(ctx context.Context, id int64) (*domain.ImportJob, error) {
+	job, err := s.ImportJobRepository.GetByID(ctx, id)
+	if errors.Is(err, database.ErrDBNotFound) {
+		return nil, fmt.Errorf("%w: id %d", ErrImportJobNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving import job: %w", err)
+	}
+	return job, nil
+}
+
+// ImportJobStats returns the stats stored by a succeeded job, or nil when it
+// has none.
+func ImportJobStats(job *domain.ImportJob) (*AccountStats, error) {
+	if len(job.Stats) == 0 {
+		return nil, nil
+	}
+	var stats AccountStats
+	if err := json.Unmarshal(job.Stats, &stats); err != nil {
+		return nil, fmt.Errorf("error reading stats of import job %d: %w", job.ID, err)
+	}
+	return &stats, nil
+}
+
+// Run starts the workers, which import the queued jobs one at a time until ctx
+// is done, and waits for them to stop. Jobs being imported when ctx is done
+// are rolled back and queued again.
+func (s *ImportJobService) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, i)
+		}()
+	}
+	wg.Wait()
+}
+
+// work runs the jobs of one worker, waiting PollInterval whenever the queue is
+// empty or a job could not be run.
+func (s *ImportJobService) work(ctx context.Context, worker int) {
+	s.log.Infow("import worker started", "worker", worker)
+	defer s.log.Infow("import worker stopped", "worker", worker)
+
+	for {
+		ran, err := s.RunNext(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.log.Errorw("import worker failed", "worker", worker, "ERROR", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// RunNext claims the next job waiting and imports it. It tells whether there
+// was a job. A job that fails to import is marked as failed, so the error
+// returned is only about running it.
+//
+// The job is marked as succeeded in the database transaction of the import,
+// so a worker stopping at any point leaves either a saved import and a
+// succeeded job, or nothing saved and a running job, which is claimed again
+// once its lease expires.
+func (s *ImportJobService) RunNext(ctx context.Context) (bool, error) {
+	job, err := s.ImportJobRepository.Claim(ctx, s.cfg.Lease)
+	if errors.Is(err, database.ErrDBNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming import job: %w", err)
+	}
+
+	s.log.Infow("import job started", "job", job.ID, "account", job.AccountNumber, "attempt", job.Attempts)
+
+	if job.Attempts > s.cfg.MaxAttempts {
+		job.Status = domain.ImportJobFailed
+		job.Error = fmt.Sprintf("gave up after %d attempts", s.cfg.MaxAttempts)
+		return true, s.finish(ctx, job)
+	}
+
+	return true, s.run(ctx, job)
+}
+
+// run imports the statement of a claimed job, sending heartbeats with its
+// progress until the import ends.
+func (s *ImportJobService) run(parent context.Context, job *domain.ImportJob) error {
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	var processed, rejected atomic.Int64
+	opts := s.options(job)
+	opts.OnProgress = func(p int, r int) {
+		processed.Store(int64(p))
+		rejected.Store(int64(r))
+	}
+	opts.OnCommit = func(repos Repositories, summary *ImportSummary) error {
+		s.setResult(job, summary.Accounts[0], int(processed.Load()))
+		err := repos.ImportJob.Finish(ctx, job)
+		if errors.Is(err, database.ErrDBNotFound) {
+			return errLeaseLost
+		}
+		if err != nil {
+			return fmt.Errorf("error finishing import job: %w", err)
+		}
+		return nil
+	}
+
+	stopHeartbeat := s.heartbeat(ctx, cancel, job, func(progress *domain.ImportJob) {
+		progress.RowsProcessed = int(processed.Load())
+		progress.RowsRejected = int(rejected.Load())
+	})
+
+	reader, err := NewStatementReader(bytes.NewReader(job.Content), job.Format, s.cfg.CSVFormat)
+	var stats *AccountStats
+	if err == nil {
+		stats, err = s.transactions.ProcessTransactionsStream(ctx, job.AccountNumber, reader, opts)
+	}
+	stopHeartbeat()
+
+	switch {
+	case err == nil && stats.DryRun:
+		// Nothing was committed, so the job is finished apart.
+		s.setResult(job, stats, int(processed.Load()))
+		return s.finish(ctx, job)
+	case err == nil:
+		s.log.Infow("import job succeeded", "job", job.ID, "batch", stats.BatchID, "rows", job.RowsProcessed)
+		return nil
+	case errors.Is(context.Cause(ctx), errLeaseLost), errors.Is(err, errLeaseLost):
+		s.log.Warnw("import job taken over by another worker", "job", job.ID, "attempt", job.Attempts)
+		return nil
+	case parent.Err() != nil:
+		s.log.Infow("import job interrupted", "job", job.ID)
+		err := s.ImportJobRepository.Release(context.WithoutCancel(parent), job)
+		if err != nil && !errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("error releasing import job: %w", err)
+		}
+		return nil
+	}
+
+	s.log.Warnw("import job failed", "job", job.ID, "ERROR", err)
+	job.Status = domain.ImportJobFailed
+	job.Error = err.Error()
+	job.RowsProcessed = int(processed.Load())
+	job.RowsRejected = int(rejected.Load())
+	return s.finish(ctx, job)
+}
+
+// options returns the options of the import of the job.
+func (s *ImportJobService) options(job *domain.ImportJob) ImportOptions {
+	opts := s.cfg.Import
+	opts.OnError = job.OnError
+	opts.DryRun = job.DryRun
+	opts.RequireBalanceMatch = job.RequireBalanceMatch
+	if job.Currency != "" {
+		opts.Currency = job.Currency
+	}
+	return opts
+}
+
+// setResult marks the job as succeeded with the stats of its import.
+func (s *ImportJobService) setResult(job *domain.ImportJob, stats *AccountStats, processed int) {
+	job.Status = domain.ImportJobSucceeded
+	job.RowsProcessed = processed
+	job.RowsRejected = stats.RejectedCount
+	job.Error = ""
+
+	var err error
+	job.Stats, err = json.Marshal(stats)
+	if err != nil {
+		// The stats are plain values, this is not expected to fail.
+		s.log.Errorw("error encoding stats of import job", "job", job.ID, "ERROR", err)
+	}
+}
+
+// heartbeat extends the lease of the job every third of it and stores the
+// progress set by the update function, until the returned function is called.
+// The import is cancelled with errLeaseLost if the job was handed to another
+// worker in the meantime.
+func (s *ImportJobService) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, job *domain.ImportJob, update func(*domain.ImportJob)) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.cfg.Lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			progress := &domain.ImportJob{ID: job.ID, Attempts: job.Attempts}
+			update(progress)
+			err := s.ImportJobRepository.Heartbeat(ctx, progress, s.cfg.Lease)
+			switch {
+			case errors.Is(err, database.ErrDBNotFound):
+				cancel(errLeaseLost)
+				return
+			case err != nil && ctx.Err() == nil:
+				// The lease is still good until it expires, the next
+				// heartbeat may get through.
+				s.log.Warnw("import job heartbeat failed", "job", job.ID, "ERROR", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// finish stores the final state of the job. A job no longer held by this
+// attempt was finished or taken over by another worker, and is left as is.
+func (s *ImportJobService) finish(ctx context.Context, job *domain.ImportJob) error {
+	err := s.ImportJobRepository.Finish(context.WithoutCancel(ctx), job)
+	if errors.Is(err, database.ErrDBNotFound) {
+		s.log.Warnw("import job no longer held by this worker", "job", job.ID, "attempt", job.Attempts, "status", job.Status)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error finishing import job: %w", err)
+	}
+	s.log.Infow("import job finished", "job", job.ID, "status", job.Status)
+	return nil
+}
@@ -0,0 +1,282 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const jobData = `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,-10.3
+2,8/2,-20.46
+3,8/13,+10
+`
+
+type jobTestHelper struct {
+	*testHelper
+	jobs *service.ImportJobService
+
+	// jobRepository is used outside of the import, and tranJobRepository
+	// inside its database transaction.
+	jobRepository     *service.MockImportJobRepository
+	tranJobRepository *service.MockImportJobRepository
+}
+
+func jobTestSetup(t *testing.T, cfg service.ImportJobConfig) *jobTestHelper {
+	t.Helper()
+
+	h := &jobTestHelper{
+		testHelper:        testSetup(t),
+		jobRepository:     &service.MockImportJobRepository{},
+		tranJobRepository: &service.MockImportJobRepository{},
+	}
+
+	// Jobs are imported with a context of their own.
+	h.transactor.EXPECT().WithinTran(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		return fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			FXRate:      h.fxRateRepository,
+			Ledger:      h.ledgerRepository,
+			Reversal:    h.reversalRepository,
+			ImportJob:   h.tranJobRepository,
+		})
+	})
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	cfg.CSVFormat = service.DefaultCSVFormat()
+	h.jobs = service.NewImportJobService(h.log, h.jobRepository, h.service, cfg)
+
+	return h
+}
+
+// claims makes the next claim return the job.
+func (h *jobTestHelper) claims(job *domain.ImportJob) {
+	h.jobRepository.EXPECT().Claim(mock.Anything, service.DefaultJobLease).Return(job, nil).Once()
+}
+
+func Test_ImportJobService_Submit_queues_the_job(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+
+	h.jobRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.ImportJob")).RunAndReturn(func(_ context.Context, j *domain.ImportJob) (*domain.ImportJob, error) {
+		assert.Equal(t, domain.ImportJobQueued, j.Status)
+		assert.Equal(t, service.FormatAuto, j.Format)
+		assert.Equal(t, service.OnErrorAbort, j.OnError)
+		assert.False(t, j.CreatedAt.IsZero())
+		j.ID = 12
+		return j, nil
+	})
+
+	job, err := h.jobs.Submit(h.ctx, &domain.ImportJob{AccountNumber: "123456", Content: []byte(jobData)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), job.ID)
+}
+
+func Test_ImportJobService_Submit_rejects_invalid_jobs(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+
+	for name, job := range map[string]*domain.ImportJob{
+		"missing account":  {Content: []byte(jobData)},
+		"empty statement":  {AccountNumber: "123456"},
+		"unknown format":   {AccountNumber: "123456", Content: []byte(jobData), Format: "xls"},
+		"unknown on error": {AccountNumber: "123456", Content: []byte(jobData), OnError: "retry"},
+	} {
+		_, err := h.jobs.Submit(h.ctx, job)
+		assert.ErrorIs(t, err, domain.ErrInvalidImportJob, name)
+	}
+	h.jobRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func Test_ImportJobService_Get_unknown_job(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.jobRepository.EXPECT().GetByID(h.ctx, int64(5)).Return(nil, database.ErrDBNotFound)
+
+	_, err := h.jobs.Get(h.ctx, 5)
+	assert.ErrorIs(t, err, service.ErrImportJobNotFound)
+}
+
+func Test_ImportJobService_RunNext_without_jobs(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.jobRepository.EXPECT().Claim(h.ctx, service.DefaultJobLease).Return(nil, database.ErrDBNotFound)
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	assert.NoError(t, err)
+	assert.False(t, ran)
+}
+
+func Test_ImportJobService_RunNext_finishes_the_job_with_the_import(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.claims(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatAuto, OnError: service.OnErrorAbort, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 1})
+
+	var finished *domain.ImportJob
+	h.tranJobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).RunAndReturn(func(_ context.Context, j *domain.ImportJob) error {
+		finished = j
+		return nil
+	})
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	require.NotNil(t, finished)
+	assert.Equal(t, domain.ImportJobSucceeded, finished.Status)
+	assert.Equal(t, 1, finished.Attempts)
+	assert.Equal(t, 4, finished.RowsProcessed)
+	assert.Equal(t, 0, finished.RowsRejected)
+
+	stats, err := service.ImportJobStats(finished)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), stats.BatchID)
+	assert.Equal(t, 4, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("39.74"), stats.FileBalance)
+	h.jobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+}
+
+func Test_ImportJobService_RunNext_marks_failed_imports(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.claims(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, Content: []byte(jobData + "4,8/40,+1\n"), Status: domain.ImportJobRunning, Attempts: 1})
+	h.jobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).Return(nil)
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	finished := h.jobRepository.Calls[len(h.jobRepository.Calls)-1].Arguments.Get(1).(*domain.ImportJob)
+	assert.Equal(t, domain.ImportJobFailed, finished.Status)
+	assert.Contains(t, finished.Error, "line 6")
+	assert.Equal(t, 5, finished.RowsProcessed)
+	assert.Nil(t, finished.Stats)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ImportJobService_RunNext_dry_run(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.claims(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, DryRun: true, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 1})
+	h.jobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).Return(nil)
+
+	_, err := h.jobs.RunNext(h.ctx)
+	require.NoError(t, err)
+
+	// The job is finished after the import is rolled back.
+	h.tranJobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+	finished := h.jobRepository.Calls[len(h.jobRepository.Calls)-1].Arguments.Get(1).(*domain.ImportJob)
+	assert.Equal(t, domain.ImportJobSucceeded, finished.Status)
+	stats, err := service.ImportJobStats(finished)
+	require.NoError(t, err)
+	assert.True(t, stats.DryRun)
+}
+
+func Test_ImportJobService_RunNext_rolls_back_when_the_job_was_taken_over(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.claims(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 1})
+	h.tranJobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).Return(database.ErrDBNotFound)
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	h.jobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ImportJobService_RunNext_stops_when_the_lease_is_lost(t *testing.T) {
+	t.Parallel()
+	lease := 30 * time.Millisecond
+	h := jobTestSetup(t, service.ImportJobConfig{Lease: lease})
+	h.jobRepository.EXPECT().Claim(mock.Anything, lease).Return(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 2}, nil)
+	h.jobRepository.EXPECT().Heartbeat(mock.Anything, mock.AnythingOfType("*domain.ImportJob"), lease).RunAndReturn(func(_ context.Context, j *domain.ImportJob, _ time.Duration) error {
+		assert.Equal(t, int64(12), j.ID)
+		assert.Equal(t, 2, j.Attempts)
+		return database.ErrDBNotFound
+	})
+	// The rows are stored until the import is cancelled.
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(ctx context.Context, _ []*domain.Transaction) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	h.jobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+	h.jobRepository.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+	h.tranJobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+}
+
+func Test_ImportJobService_RunNext_queues_interrupted_jobs_again(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{})
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+
+	job := &domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 1}
+	h.jobRepository.EXPECT().Claim(ctx, service.DefaultJobLease).RunAndReturn(func(context.Context, time.Duration) (*domain.ImportJob, error) {
+		cancel()
+		return job, nil
+	})
+	h.jobRepository.EXPECT().Release(mock.Anything, job).Return(nil)
+
+	ran, err := h.jobs.RunNext(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	h.jobRepository.AssertCalled(t, "Release", mock.Anything, job)
+	h.jobRepository.AssertNotCalled(t, "Finish", mock.Anything, mock.Anything)
+	h.transactionRepository.AssertNotCalled(t, "BulkInsert", mock.Anything, mock.Anything)
+}
+
+func Test_ImportJobService_RunNext_gives_up_after_max_attempts(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{MaxAttempts: 2})
+	h.claims(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 3})
+	h.jobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).Return(nil)
+
+	ran, err := h.jobs.RunNext(h.ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	finished := h.jobRepository.Calls[len(h.jobRepository.Calls)-1].Arguments.Get(1).(*domain.ImportJob)
+	assert.Equal(t, domain.ImportJobFailed, finished.Status)
+	assert.Equal(t, "gave up after 2 attempts", finished.Error)
+	h.transactor.AssertNotCalled(t, "WithinTran", mock.Anything, mock.Anything)
+}
+
+func Test_ImportJobService_Run_stops_with_the_context(t *testing.T) {
+	t.Parallel()
+	h := jobTestSetup(t, service.ImportJobConfig{PollInterval: time.Millisecond})
+	ctx, cancel := context.WithCancel(h.ctx)
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.jobRepository.EXPECT().Claim(ctx, service.DefaultJobLease).Return(&domain.ImportJob{ID: 12, AccountNumber: "123456", Format: service.FormatCSV, OnError: service.OnErrorAbort, Content: []byte(jobData), Status: domain.ImportJobRunning, Attempts: 1}, nil).Once()
+	h.jobRepository.EXPECT().Claim(ctx, service.DefaultJobLease).RunAndReturn(func(context.Context, time.Duration) (*domain.ImportJob, error) {
+		cancel()
+		return nil, database.ErrDBNotFound
+	})
+	h.tranJobRepository.EXPECT().Finish(mock.Anything, mock.AnythingOfType("*domain.ImportJob")).Return(nil)
+
+	h.jobs.Run(ctx, 1)
+
+	h.tranJobRepository.AssertNumberOfCalls(t, "Finish", 1)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+type (
+	// readRecord is a record read from the statement, or the error reading
+	// it, numbered in file order.
+	readRecord struct {
+		seq     int
+		rec     StatementRecord
+		readErr error
+	}
+
+	// parsedRecord is a read record with the transaction parsed from it.
+	parsedRecord struct {
+		readRecord
+		txn      *domain.Transaction
+		parseErr error
+	}
+)
+
+// pipeline reads, parses, stores and aggregates the records of the statement
+// in four stages connected by channels:
+//
+//   - the reader numbers the records in file order,
+//   - a pool of opts.Workers goroutines parses them,
+//   - the writer puts them back in file order, converts and deduplicates
+//     them and stores them in batches,
+//   - the aggregator adds the stored batches to the stats.
+//
+// The writer runs on the calling goroutine, as every repository shares the
+// database transaction of the import, and it is the only stage deciding what
+// to do with bad rows, so the outcome of an import does not depend on
+// scheduling. At most inFlight records are read ahead of the writer.
+func (imp *statementImport) pipeline(ctx context.Context, reader StatementReader) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	workers := imp.opts.Workers
+	inFlight := 4 * workers
+
+	window := make(chan struct{}, inFlight)
+	records := make(chan readRecord, workers)
+	parsed := make(chan parsedRecord, workers)
+	imp.stored = make(chan []pendingTransaction, 1)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(records)
+		readRecords(ctx, reader, window, records)
+	}()
+
+	var parsers sync.WaitGroup
+	for range workers {
+		parsers.Add(1)
+		go func() {
+			defer parsers.Done()
+			imp.parseRecords(ctx, reader, records, parsed)
+		}()
+	}
+	go func() {
+		parsers.Wait()
+		close(parsed)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for batch := range imp.stored {
+			imp.aggregate(batch)
+		}
+	}()
+
+	err := imp.write(ctx, parsed, window)
+	close(imp.stored)
+	if err != nil {
+		cancel(err)
+	}
+	// Let the stages still running see the cancellation and finish.
+	for range parsed {
+	}
+	wg.Wait()
+
+	return err
+}
+
+// readRecords sends the records of the statement in file order, waiting for a
+// slot in window before reading each one. It stops after the first error
+// that is not a StatementRowError, which is sent on as well.
+func readRecords(ctx context.Context, reader StatementReader, window chan<- struct{}, records chan<- readRecord) {
+	for seq := 0; ; seq++ {
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		select {
+		case records <- readRecord{seq: seq, rec: rec, readErr: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		var rowErr *StatementRowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return
+		}
+	}
+}
+
+// parseRecords parses records until there are no more.
+func (imp *statementImport) parseRecords(ctx context.Context, reader StatementReader, records <-chan readRecord, parsed chan<- parsedRecord) {
+	for r := range records {
+		p := parsedRecord{readRecord: r}
+		if r.readErr == nil {
+			p.txn = &domain.Transaction{ProcessingTimestamp: imp.processedAt}
+			p.parseErr = reader.Parse(r.rec, p.txn)
+		}
+
+		select {
+		case parsed <- p:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// write processes the parsed records in file order and stores what is left
+// queued at the end. Records that arrive early wait in a buffer, which the
+// window keeps bounded.
+func (imp *statementImport) write(ctx context.Context, parsed <-chan parsedRecord, window <-chan struct{}) error {
+	// The progress is reported once more when the import stops, whatever
+	// the reason.
+	defer imp.progress()
+
+	early := make(map[int]parsedRecord)
+	next := 0
+
+	for p := range parsed {
+		early[p.seq] = p
+		for {
+			p, ok := early[next]
+			if !ok {
+				break
+			}
+			delete(early, next)
+			next++
+			imp.processed++
+
+			if err := imp.process(ctx, p); err != nil {
+				return err
+			}
+			<-window
+		}
+	}
+
+	// The stages stop early only when the import is cancelled.
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	return imp.flush(ctx)
+}
+
+// aggregate adds a stored batch to the stats of the accounts.
+func (imp *statementImport) aggregate(batch []pendingTransaction) {
+	for _, p := range batch {
+		p.ai.stats.apply(p.txn)
+		imp.summary.TransactionCount++
+		imp.summary.FileBalance += p.txn.Amount
+	}
+	imp.s.log.Infow("transactions stored", "count", len(batch), "total", imp.summary.TransactionCount)
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+)
+
+// ErrLedgerUnbalanced is returned by LedgerService.Check when the ledger does
+// not add up to zero.
+var ErrLedgerUnbalanced = errors.New("ledger is not balanced")
+
+// maxDeadlockRetries is how many times LedgerService.Post runs a journal entry
+// again after Postgres aborted it to break a deadlock.
+const maxDeadlockRetries = 3
+
+type (
+	// LedgerService posts journal entries and checks the ledger.
+	LedgerService struct {
+		log        *zap.SugaredLogger
+		Transactor Transactor
+	}
+
+	// Posting moves Amount into an account, or out of it when negative, in
+	// the currency of the account.
+	Posting struct {
+		AccountNumber string
+		Amount        domain.Amount
+	}
+
+	// LedgerReport is the result of checking the ledger. It is balanced when
+	// every currency adds up to zero and so does every journal entry.
+	LedgerReport struct {
+		Totals            []domain.LedgerTotal
+		UnbalancedEntries []int64
+		Balanced          bool
+	}
+)
+
+func NewLedgerService(log *zap.SugaredLogger, transactor Transactor) *LedgerService {
+	return &LedgerService{
+		log:        log,
+		Transactor: transactor,
+	}
+}
+
+// Post stores a journal entry with the postings, which must add up to zero in
+// every currency, and moves the balance of the customer accounts they touch.
+// The accounts are locked in account number order, but imports lock theirs in
+// the order they appear in the file and reversals by id, so Postgres may abort
+// the post to break a deadlock. It is then run again, up to
+// maxDeadlockRetries times.
+func (s *LedgerService) Post(ctx context.Context, description string, postings []Posting) (*domain.JournalEntry, error) {
+	var entry *domain.JournalEntry
+	for attempt := 0; ; attempt++ {
+		entry = &domain.JournalEntry{
+			Description: description,
+			PostedAt:    time.Now(),
+		}
+		err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+			return post(ctx, repos, entry, postings)
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, database.ErrDBDeadlock) || attempt == maxDeadlockRetries {
+			return nil, err
+		}
+		s.log.Warnw("journal entry deadlocked, posting again", "description", description, "attempt", attempt+1)
+	}
+
+	s.log.Infow("journal entry posted", "journal_entry", entry.ID, "description", description, "lines", len(entry.Lines))
+
+	return entry, nil
+}
+
+// post adds the lines of the postings to entry and stores it.
+func post(ctx context.Context, repos Repositories, entry *domain.JournalEntry, postings []Posting) error {
+	accounts, err := lockAccounts(ctx, repos, postings)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		account := accounts[p.AccountNumber]
+		entry.Lines = append(entry.Lines, domain.LedgerEntry{
+			AccountID: account.ID,
+			Amount:    p.Amount,
+			Currency:  account.Currency,
+		})
+		if !account.IsClearing() {
+			account.Balance += p.Amount
+		}
+	}
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	if err := repos.Ledger.Post(ctx, []*domain.JournalEntry{entry}); err != nil {
+		return fmt.Errorf("error posting ledger entries: %w", err)
+	}
+
+	for _, account := range accounts {
+		if account.IsClearing() {
+			continue
+		}
+		if _, err := repos.Account.Update(ctx, account); err != nil {
+			return fmt.Errorf("error updating account: %w", err)
+		}
+	}
+	return nil
+}
+
+// lockAccounts reads the accounts of the postings, locking the customer ones
+// in account number order. Clearing accounts are read without a lock, as
+// their balance is never updated and imports in their currency must not wait
+// for the post. Customer accounts must accept postings.
+func lockAccounts(ctx context.Context, repos Repositories, postings []Posting) (map[string]*domain.Account, error) {
+	numbers := make([]string, 0, len(postings))
+	for _, p := range postings {
+		numbers = append(numbers, p.AccountNumber)
+	}
+	slices.Sort(numbers)
+	numbers = slices.Compact(numbers)
+
+	accounts := make(map[string]*domain.Account, len(numbers))
+	for _, number := range numbers {
+		account, err := repos.Account.GetByAccountNumber(ctx, number)
+		if err == nil && !account.IsClearing() {
+			account, err = repos.Account.GetByAccountNumberForUpdate(ctx, number)
+		}
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, number)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving account: %w", err)
+		}
+		if !account.IsActive() {
+			return nil, fmt.Errorf("%w: account %s is %s", ErrAccountInactive, number, account.Status)
+		}
+		if account.Currency == "" {
+			account.Currency = DefaultCurrency
+		}
+		accounts[number] = account
+	}
+
+	return accounts, nil
+}
+
+// Check adds up the ledger of every currency and looks for journal entries
+// that do not balance. When the ledger is not balanced the report comes with
+// ErrLedgerUnbalanced.
+func (s *LedgerService) Check(ctx context.Context) (*LedgerReport, error) {
+	report := &LedgerReport{}
+
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		var err error
+		report.Totals, err = repos.Ledger.Totals(ctx)
+		if err != nil {
+			return fmt.Errorf("error adding up ledger: %w", err)
+		}
+		report.UnbalancedEntries, err = repos.Ledger.UnbalancedEntries(ctx)
+		if err != nil {
+			return fmt.Errorf("error checking journal entries: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var unbalanced []string
+	for _, total := range report.Totals {
+		if total.Total != 0 {
+			unbalanced = append(unbalanced, fmt.Sprintf("%s adds up to %s", total.Currency, total.Total))
+		}
+	}
+	if len(report.UnbalancedEntries) > 0 {
+		unbalanced = append(unbalanced, fmt.Sprintf("%d journal entries do not balance", len(report.UnbalancedEntries)))
+	}
+	report.Balanced = len(unbalanced) == 0
+	if !report.Balanced {
+		s.log.Warnw("ledger is not balanced", "totals", report.Totals, "unbalanced_entries", report.UnbalancedEntries)
+		return report, fmt.Errorf("%w: %s", ErrLedgerUnbalanced, strings.Join(unbalanced, ", "))
+	}
+
+	s.log.Infow("ledger is balanced", "currencies", len(report.Totals))
+
+	return report, nil
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_LedgerService_Post(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+
+	s := service.NewLedgerService(h.log, h.transactor)
+
+	entry, err := s.Post(h.ctx, "fee", []service.Posting{
+		{AccountNumber: "123456", Amount: domain.MustParseAmount("-2.5")},
+		{AccountNumber: "CLEARING-USD", Amount: domain.MustParseAmount("2.5")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.LedgerEntry{
+		{AccountID: 1, Amount: domain.MustParseAmount("-2.5"), Currency: "USD"},
+		{AccountID: 100, Amount: domain.MustParseAmount("2.5"), Currency: "USD"},
+	}, entry.Lines)
+	assert.Equal(t, domain.MustParseAmount("7.5"), h.account.Balance)
+	h.accountRepository.AssertNumberOfCalls(t, "Update", 1)
+	h.accountRepository.AssertNotCalled(t, "GetByAccountNumberForUpdate", mock.Anything, "CLEARING-USD")
+	h.ledgerRepository.AssertNumberOfCalls(t, "Post", 1)
+
+	_, err = s.Post(h.ctx, "fee", []service.Posting{
+		{AccountNumber: "123456", Amount: domain.MustParseAmount("-2.5")},
+		{AccountNumber: "CLEARING-USD", Amount: domain.MustParseAmount("2")},
+	})
+	assert.ErrorIs(t, err, domain.ErrUnbalancedEntry)
+	h.ledgerRepository.AssertNumberOfCalls(t, "Post", 1)
+}
+
+func Test_LedgerService_Post_runs_deadlocked_entries_again(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+
+	// Postgres aborts the first attempt, the second one runs.
+	tran := h.transactor.ExpectedCalls
+	h.transactor.ExpectedCalls = nil
+	h.transactor.EXPECT().WithinTran(h.ctx, mock.Anything).Return(database.ErrDBDeadlock).Once()
+	h.transactor.ExpectedCalls = append(h.transactor.ExpectedCalls, tran...)
+
+	s := service.NewLedgerService(h.log, h.transactor)
+	postings := []service.Posting{
+		{AccountNumber: "123456", Amount: domain.MustParseAmount("-2.5")},
+		{AccountNumber: "CLEARING-USD", Amount: domain.MustParseAmount("2.5")},
+	}
+
+	entry, err := s.Post(h.ctx, "fee", postings)
+	assert.NoError(t, err)
+	assert.Len(t, entry.Lines, 2)
+	h.transactor.AssertNumberOfCalls(t, "WithinTran", 2)
+	h.ledgerRepository.AssertNumberOfCalls(t, "Post", 1)
+
+	h.transactor.ExpectedCalls = nil
+	h.transactor.EXPECT().WithinTran(h.ctx, mock.Anything).Return(database.ErrDBDeadlock)
+
+	_, err = s.Post(h.ctx, "fee", postings)
+	assert.ErrorIs(t, err, database.ErrDBDeadlock)
+	h.transactor.AssertNumberOfCalls(t, "WithinTran", 6)
+}
+
+func Test_LedgerService_Check(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.ledgerRepository.EXPECT().Totals(mock.Anything).Return([]domain.LedgerTotal{
+		{Currency: "EUR", Entries: 4, Total: 0},
+		{Currency: "USD", Entries: 10, Total: 0},
+	}, nil).Once()
+	h.ledgerRepository.EXPECT().UnbalancedEntries(mock.Anything).Return(nil, nil).Once()
+
+	s := service.NewLedgerService(h.log, h.transactor)
+
+	report, err := s.Check(h.ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.Len(t, report.Totals, 2)
+
+	h.ledgerRepository.EXPECT().Totals(mock.Anything).Return([]domain.LedgerTotal{
+		{Currency: "USD", Entries: 11, Total: domain.MustParseAmount("3")},
+	}, nil).Once()
+	h.ledgerRepository.EXPECT().UnbalancedEntries(mock.Anything).Return([]int64{42}, nil).Once()
+
+	report, err = s.Check(h.ctx)
+	assert.ErrorIs(t, err, service.ErrLedgerUnbalanced)
+	assert.ErrorContains(t, err, "USD adds up to 3.00")
+	assert.False(t, report.Balanced)
+	assert.Equal(t, []int64{42}, report.UnbalancedEntries)
+}
@@ -22,6 +22,123 @@ func (_m *MockAccountRepository) EXPECT() *MockAccountRepository_Expecter {
 	return &MockAccountRepository_Expecter{mock: &_m.Mock}
 }
 
+// ComputeBalance provides a mock function with given fields: ctx, accountID
+func (_m *MockAccountRepository) ComputeBalance(ctx context.Context, accountID int64) (*domain.AccountBalance, error) {
+	ret := _m.Called(ctx, accountID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ComputeBalance")
+	}
+
+	var r0 *domain.AccountBalance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*domain.AccountBalance, error)); ok {
+		return rf(ctx, accountID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *domain.AccountBalance); ok {
+		r0 = rf(ctx, accountID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.AccountBalance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, accountID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAccountRepository_ComputeBalance_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ComputeBalance'
+type MockAccountRepository_ComputeBalance_Call struct {
+	*mock.Call
+}
+
+// ComputeBalance is a helper method to define mock.On call
+//   - ctx context.Context
+//   - accountID int64
+func (_e *MockAccountRepository_Expecter) ComputeBalance(ctx interface{}, accountID interface{}) *MockAccountRepository_ComputeBalance_Call {
+	return &MockAccountRepository_ComputeBalance_Call{Call: _e.mock.On("ComputeBalance", ctx, accountID)}
+}
+
+func (_c *MockAccountRepository_ComputeBalance_Call) Run(run func(ctx context.Context, accountID int64)) *MockAccountRepository_ComputeBalance_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockAccountRepository_ComputeBalance_Call) Return(_a0 *domain.AccountBalance, _a1 error) *MockAccountRepository_ComputeBalance_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAccountRepository_ComputeBalance_Call) RunAndReturn(run func(context.Context, int64) (*domain.AccountBalance, error)) *MockAccountRepository_ComputeBalance_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ComputeBalances provides a mock function with given fields: ctx
+func (_m *MockAccountRepository) ComputeBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ComputeBalances")
+	}
+
+	var r0 []domain.AccountBalance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]domain.AccountBalance, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.AccountBalance); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.AccountBalance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAccountRepository_ComputeBalances_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ComputeBalances'
+type MockAccountRepository_ComputeBalances_Call struct {
+	*mock.Call
+}
+
+// ComputeBalances is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockAccountRepository_Expecter) ComputeBalances(ctx interface{}) *MockAccountRepository_ComputeBalances_Call {
+	return &MockAccountRepository_ComputeBalances_Call{Call: _e.mock.On("ComputeBalances", ctx)}
+}
+
+func (_c *MockAccountRepository_ComputeBalances_Call) Run(run func(ctx context.Context)) *MockAccountRepository_ComputeBalances_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockAccountRepository_ComputeBalances_Call) Return(_a0 []domain.AccountBalance, _a1 error) *MockAccountRepository_ComputeBalances_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAccountRepository_ComputeBalances_Call) RunAndReturn(run func(context.Context) ([]domain.AccountBalance, error)) *MockAccountRepository_ComputeBalances_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetByAccountNumber provides a mock function with given fields: _a0, accountNumber
 func (_m *MockAccountRepository) GetByAccountNumber(_a0 context.Context, accountNumber string) (*domain.Account, error) {
 	ret := _m.Called(_a0, accountNumber)
@@ -81,6 +198,124 @@ func (_c *MockAccountRepository_GetByAccountNumber_Call) RunAndReturn(run func(c
 	return _c
 }
 
+// GetByAccountNumberForUpdate provides a mock function with given fields: _a0, accountNumber
+func (_m *MockAccountRepository) GetByAccountNumberForUpdate(_a0 context.Context, accountNumber string) (*domain.Account, error) {
+	ret := _m.Called(_a0, accountNumber)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByAccountNumberForUpdate")
+	}
+
+	var r0 *domain.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Account, error)); ok {
+		return rf(_a0, accountNumber)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Account); ok {
+		r0 = rf(_a0, accountNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Account)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, accountNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAccountRepository_GetByAccountNumberForUpdate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByAccountNumberForUpdate'
+type MockAccountRepository_GetByAccountNumberForUpdate_Call struct {
+	*mock.Call
+}
+
+// GetByAccountNumberForUpdate is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - accountNumber string
+func (_e *MockAccountRepository_Expecter) GetByAccountNumberForUpdate(_a0 interface{}, accountNumber interface{}) *MockAccountRepository_GetByAccountNumberForUpdate_Call {
+	return &MockAccountRepository_GetByAccountNumberForUpdate_Call{Call: _e.mock.On("GetByAccountNumberForUpdate", _a0, accountNumber)}
+}
+
+func (_c *MockAccountRepository_GetByAccountNumberForUpdate_Call) Run(run func(_a0 context.Context, accountNumber string)) *MockAccountRepository_GetByAccountNumberForUpdate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAccountRepository_GetByAccountNumberForUpdate_Call) Return(_a0 *domain.Account, _a1 error) *MockAccountRepository_GetByAccountNumberForUpdate_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAccountRepository_GetByAccountNumberForUpdate_Call) RunAndReturn(run func(context.Context, string) (*domain.Account, error)) *MockAccountRepository_GetByAccountNumberForUpdate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByIDForUpdate provides a mock function with given fields: ctx, id
+func (_m *MockAccountRepository) GetByIDForUpdate(ctx context.Context, id int64) (*domain.Account, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByIDForUpdate")
+	}
+
+	var r0 *domain.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*domain.Account, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *domain.Account); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Account)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAccountRepository_GetByIDForUpdate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByIDForUpdate'
+type MockAccountRepository_GetByIDForUpdate_Call struct {
+	*mock.Call
+}
+
+// GetByIDForUpdate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+func (_e *MockAccountRepository_Expecter) GetByIDForUpdate(ctx interface{}, id interface{}) *MockAccountRepository_GetByIDForUpdate_Call {
+	return &MockAccountRepository_GetByIDForUpdate_Call{Call: _e.mock.On("GetByIDForUpdate", ctx, id)}
+}
+
+func (_c *MockAccountRepository_GetByIDForUpdate_Call) Run(run func(ctx context.Context, id int64)) *MockAccountRepository_GetByIDForUpdate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockAccountRepository_GetByIDForUpdate_Call) Return(_a0 *domain.Account, _a1 error) *MockAccountRepository_GetByIDForUpdate_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAccountRepository_GetByIDForUpdate_Call) RunAndReturn(run func(context.Context, int64) (*domain.Account, error)) *MockAccountRepository_GetByIDForUpdate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Insert provides a mock function with given fields: ctx, m
 func (_m *MockAccountRepository) Insert(ctx context.Context, m *domain.Account) (*domain.Account, error) {
 	ret := _m.Called(ctx, m)
@@ -140,6 +375,66 @@ func (_c *MockAccountRepository_Insert_Call) RunAndReturn(run func(context.Conte
 	return _c
 }
 
+// List provides a mock function with given fields: ctx, after, limit
+func (_m *MockAccountRepository) List(ctx context.Context, after string, limit int) ([]*domain.Account, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []*domain.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) ([]*domain.Account, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []*domain.Account); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Account)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAccountRepository_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockAccountRepository_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - after string
+//   - limit int
+func (_e *MockAccountRepository_Expecter) List(ctx interface{}, after interface{}, limit interface{}) *MockAccountRepository_List_Call {
+	return &MockAccountRepository_List_Call{Call: _e.mock.On("List", ctx, after, limit)}
+}
+
+func (_c *MockAccountRepository_List_Call) Run(run func(ctx context.Context, after string, limit int)) *MockAccountRepository_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockAccountRepository_List_Call) Return(_a0 []*domain.Account, _a1 error) *MockAccountRepository_List_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAccountRepository_List_Call) RunAndReturn(run func(context.Context, string, int) ([]*domain.Account, error)) *MockAccountRepository_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Update provides a mock function with given fields: ctx, m
 func (_m *MockAccountRepository) Update(ctx context.Context, m *domain.Account) (*domain.Account, error) {
 	ret := _m.Called(ctx, m)
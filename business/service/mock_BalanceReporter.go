@@ -0,0 +1,79 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockBalanceReporter is an autogenerated mock type for the BalanceReporter type
+type MockBalanceReporter struct {
+	mock.Mock
+}
+
+type MockBalanceReporter_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockBalanceReporter) EXPECT() *MockBalanceReporter_Expecter {
+	return &MockBalanceReporter_Expecter{mock: &_m.Mock}
+}
+
+// Balances provides a mock function with given fields:
+func (_m *MockBalanceReporter) Balances() map[string]StatementBalances {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Balances")
+	}
+
+	var r0 map[string]StatementBalances
+	if rf, ok := ret.Get(0).(func() map[string]StatementBalances); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]StatementBalances)
+		}
+	}
+
+	return r0
+}
+
+// MockBalanceReporter_Balances_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Balances'
+type MockBalanceReporter_Balances_Call struct {
+	*mock.Call
+}
+
+// Balances is a helper method to define mock.On call
+func (_e *MockBalanceReporter_Expecter) Balances() *MockBalanceReporter_Balances_Call {
+	return &MockBalanceReporter_Balances_Call{Call: _e.mock.On("Balances")}
+}
+
+func (_c *MockBalanceReporter_Balances_Call) Run(run func()) *MockBalanceReporter_Balances_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockBalanceReporter_Balances_Call) Return(_a0 map[string]StatementBalances) *MockBalanceReporter_Balances_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockBalanceReporter_Balances_Call) RunAndReturn(run func() map[string]StatementBalances) *MockBalanceReporter_Balances_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockBalanceReporter creates a new instance of MockBalanceReporter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockBalanceReporter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockBalanceReporter {
+	mock := &MockBalanceReporter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
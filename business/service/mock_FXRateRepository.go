@@ -0,0 +1,159 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// MockFXRateRepository is an autogenerated mock type for the FXRateRepository type
+type MockFXRateRepository struct {
+	mock.Mock
+}
+
+type MockFXRateRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockFXRateRepository) EXPECT() *MockFXRateRepository_Expecter {
+	return &MockFXRateRepository_Expecter{mock: &_m.Mock}
+}
+
+// GetRate provides a mock function with given fields: ctx, baseCurrency, quoteCurrency, date
+func (_m *MockFXRateRepository) GetRate(ctx context.Context, baseCurrency string, quoteCurrency string, date time.Time) (*domain.FXRate, error) {
+	ret := _m.Called(ctx, baseCurrency, quoteCurrency, date)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRate")
+	}
+
+	var r0 *domain.FXRate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) (*domain.FXRate, error)); ok {
+		return rf(ctx, baseCurrency, quoteCurrency, date)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) *domain.FXRate); ok {
+		r0 = rf(ctx, baseCurrency, quoteCurrency, date)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.FXRate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, baseCurrency, quoteCurrency, date)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockFXRateRepository_GetRate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRate'
+type MockFXRateRepository_GetRate_Call struct {
+	*mock.Call
+}
+
+// GetRate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - baseCurrency string
+//   - quoteCurrency string
+//   - date time.Time
+func (_e *MockFXRateRepository_Expecter) GetRate(ctx interface{}, baseCurrency interface{}, quoteCurrency interface{}, date interface{}) *MockFXRateRepository_GetRate_Call {
+	return &MockFXRateRepository_GetRate_Call{Call: _e.mock.On("GetRate", ctx, baseCurrency, quoteCurrency, date)}
+}
+
+func (_c *MockFXRateRepository_GetRate_Call) Run(run func(ctx context.Context, baseCurrency string, quoteCurrency string, date time.Time)) *MockFXRateRepository_GetRate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockFXRateRepository_GetRate_Call) Return(_a0 *domain.FXRate, _a1 error) *MockFXRateRepository_GetRate_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockFXRateRepository_GetRate_Call) RunAndReturn(run func(context.Context, string, string, time.Time) (*domain.FXRate, error)) *MockFXRateRepository_GetRate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Upsert provides a mock function with given fields: ctx, m
+func (_m *MockFXRateRepository) Upsert(ctx context.Context, m *domain.FXRate) (*domain.FXRate, error) {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upsert")
+	}
+
+	var r0 *domain.FXRate
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.FXRate) (*domain.FXRate, error)); ok {
+		return rf(ctx, m)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.FXRate) *domain.FXRate); ok {
+		r0 = rf(ctx, m)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.FXRate)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.FXRate) error); ok {
+		r1 = rf(ctx, m)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockFXRateRepository_Upsert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Upsert'
+type MockFXRateRepository_Upsert_Call struct {
+	*mock.Call
+}
+
+// Upsert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.FXRate
+func (_e *MockFXRateRepository_Expecter) Upsert(ctx interface{}, m interface{}) *MockFXRateRepository_Upsert_Call {
+	return &MockFXRateRepository_Upsert_Call{Call: _e.mock.On("Upsert", ctx, m)}
+}
+
+func (_c *MockFXRateRepository_Upsert_Call) Run(run func(ctx context.Context, m *domain.FXRate)) *MockFXRateRepository_Upsert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.FXRate))
+	})
+	return _c
+}
+
+func (_c *MockFXRateRepository_Upsert_Call) Return(_a0 *domain.FXRate, _a1 error) *MockFXRateRepository_Upsert_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockFXRateRepository_Upsert_Call) RunAndReturn(run func(context.Context, *domain.FXRate) (*domain.FXRate, error)) *MockFXRateRepository_Upsert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockFXRateRepository creates a new instance of MockFXRateRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockFXRateRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockFXRateRepository {
+	mock := &MockFXRateRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
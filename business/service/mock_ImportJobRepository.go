@@ -0,0 +1,358 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// MockImportJobRepository is an autogenerated mock type for the ImportJobRepository type
+type MockImportJobRepository struct {
+	mock.Mock
+}
+
+type MockImportJobRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockImportJobRepository) EXPECT() *MockImportJobRepository_Expecter {
+	return &MockImportJobRepository_Expecter{mock: &_m.Mock}
+}
+
+// Claim provides a mock function with given fields: ctx, lease
+func (_m *MockImportJobRepository) Claim(ctx context.Context, lease time.Duration) (*domain.ImportJob, error) {
+	ret := _m.Called(ctx, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Claim")
+	}
+
+	var r0 *domain.ImportJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) (*domain.ImportJob, error)); ok {
+		return rf(ctx, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) *domain.ImportJob); ok {
+		r0 = rf(ctx, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ImportJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
+		r1 = rf(ctx, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockImportJobRepository_Claim_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Claim'
+type MockImportJobRepository_Claim_Call struct {
+	*mock.Call
+}
+
+// Claim is a helper method to define mock.On call
+//   - ctx context.Context
+//   - lease time.Duration
+func (_e *MockImportJobRepository_Expecter) Claim(ctx interface{}, lease interface{}) *MockImportJobRepository_Claim_Call {
+	return &MockImportJobRepository_Claim_Call{Call: _e.mock.On("Claim", ctx, lease)}
+}
+
+func (_c *MockImportJobRepository_Claim_Call) Run(run func(ctx context.Context, lease time.Duration)) *MockImportJobRepository_Claim_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_Claim_Call) Return(_a0 *domain.ImportJob, _a1 error) *MockImportJobRepository_Claim_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockImportJobRepository_Claim_Call) RunAndReturn(run func(context.Context, time.Duration) (*domain.ImportJob, error)) *MockImportJobRepository_Claim_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Finish provides a mock function with given fields: ctx, m
+func (_m *MockImportJobRepository) Finish(ctx context.Context, m *domain.ImportJob) error {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Finish")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ImportJob) error); ok {
+		r0 = rf(ctx, m)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockImportJobRepository_Finish_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Finish'
+type MockImportJobRepository_Finish_Call struct {
+	*mock.Call
+}
+
+// Finish is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.ImportJob
+func (_e *MockImportJobRepository_Expecter) Finish(ctx interface{}, m interface{}) *MockImportJobRepository_Finish_Call {
+	return &MockImportJobRepository_Finish_Call{Call: _e.mock.On("Finish", ctx, m)}
+}
+
+func (_c *MockImportJobRepository_Finish_Call) Run(run func(ctx context.Context, m *domain.ImportJob)) *MockImportJobRepository_Finish_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.ImportJob))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_Finish_Call) Return(_a0 error) *MockImportJobRepository_Finish_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockImportJobRepository_Finish_Call) RunAndReturn(run func(context.Context, *domain.ImportJob) error) *MockImportJobRepository_Finish_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *MockImportJobRepository) GetByID(ctx context.Context, id int64) (*domain.ImportJob, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.ImportJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*domain.ImportJob, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *domain.ImportJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ImportJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockImportJobRepository_GetByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByID'
+type MockImportJobRepository_GetByID_Call struct {
+	*mock.Call
+}
+
+// GetByID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+func (_e *MockImportJobRepository_Expecter) GetByID(ctx interface{}, id interface{}) *MockImportJobRepository_GetByID_Call {
+	return &MockImportJobRepository_GetByID_Call{Call: _e.mock.On("GetByID", ctx, id)}
+}
+
+func (_c *MockImportJobRepository_GetByID_Call) Run(run func(ctx context.Context, id int64)) *MockImportJobRepository_GetByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_GetByID_Call) Return(_a0 *domain.ImportJob, _a1 error) *MockImportJobRepository_GetByID_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockImportJobRepository_GetByID_Call) RunAndReturn(run func(context.Context, int64) (*domain.ImportJob, error)) *MockImportJobRepository_GetByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Heartbeat provides a mock function with given fields: ctx, m, lease
+func (_m *MockImportJobRepository) Heartbeat(ctx context.Context, m *domain.ImportJob, lease time.Duration) error {
+	ret := _m.Called(ctx, m, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Heartbeat")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ImportJob, time.Duration) error); ok {
+		r0 = rf(ctx, m, lease)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockImportJobRepository_Heartbeat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Heartbeat'
+type MockImportJobRepository_Heartbeat_Call struct {
+	*mock.Call
+}
+
+// Heartbeat is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.ImportJob
+//   - lease time.Duration
+func (_e *MockImportJobRepository_Expecter) Heartbeat(ctx interface{}, m interface{}, lease interface{}) *MockImportJobRepository_Heartbeat_Call {
+	return &MockImportJobRepository_Heartbeat_Call{Call: _e.mock.On("Heartbeat", ctx, m, lease)}
+}
+
+func (_c *MockImportJobRepository_Heartbeat_Call) Run(run func(ctx context.Context, m *domain.ImportJob, lease time.Duration)) *MockImportJobRepository_Heartbeat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.ImportJob), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_Heartbeat_Call) Return(_a0 error) *MockImportJobRepository_Heartbeat_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockImportJobRepository_Heartbeat_Call) RunAndReturn(run func(context.Context, *domain.ImportJob, time.Duration) error) *MockImportJobRepository_Heartbeat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Insert provides a mock function with given fields: ctx, m
+func (_m *MockImportJobRepository) Insert(ctx context.Context, m *domain.ImportJob) (*domain.ImportJob, error) {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Insert")
+	}
+
+	var r0 *domain.ImportJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ImportJob) (*domain.ImportJob, error)); ok {
+		return rf(ctx, m)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ImportJob) *domain.ImportJob); ok {
+		r0 = rf(ctx, m)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.ImportJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.ImportJob) error); ok {
+		r1 = rf(ctx, m)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockImportJobRepository_Insert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Insert'
+type MockImportJobRepository_Insert_Call struct {
+	*mock.Call
+}
+
+// Insert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.ImportJob
+func (_e *MockImportJobRepository_Expecter) Insert(ctx interface{}, m interface{}) *MockImportJobRepository_Insert_Call {
+	return &MockImportJobRepository_Insert_Call{Call: _e.mock.On("Insert", ctx, m)}
+}
+
+func (_c *MockImportJobRepository_Insert_Call) Run(run func(ctx context.Context, m *domain.ImportJob)) *MockImportJobRepository_Insert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.ImportJob))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_Insert_Call) Return(_a0 *domain.ImportJob, _a1 error) *MockImportJobRepository_Insert_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockImportJobRepository_Insert_Call) RunAndReturn(run func(context.Context, *domain.ImportJob) (*domain.ImportJob, error)) *MockImportJobRepository_Insert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Release provides a mock function with given fields: ctx, m
+func (_m *MockImportJobRepository) Release(ctx context.Context, m *domain.ImportJob) error {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Release")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.ImportJob) error); ok {
+		r0 = rf(ctx, m)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockImportJobRepository_Release_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Release'
+type MockImportJobRepository_Release_Call struct {
+	*mock.Call
+}
+
+// Release is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.ImportJob
+func (_e *MockImportJobRepository_Expecter) Release(ctx interface{}, m interface{}) *MockImportJobRepository_Release_Call {
+	return &MockImportJobRepository_Release_Call{Call: _e.mock.On("Release", ctx, m)}
+}
+
+func (_c *MockImportJobRepository_Release_Call) Run(run func(ctx context.Context, m *domain.ImportJob)) *MockImportJobRepository_Release_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.ImportJob))
+	})
+	return _c
+}
+
+func (_c *MockImportJobRepository_Release_Call) Return(_a0 error) *MockImportJobRepository_Release_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockImportJobRepository_Release_Call) RunAndReturn(run func(context.Context, *domain.ImportJob) error) *MockImportJobRepository_Release_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockImportJobRepository creates a new instance of MockImportJobRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockImportJobRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockImportJobRepository {
+	mock := &MockImportJobRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,262 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockIngestionBatchRepository is an autogenerated mock type for the IngestionBatchRepository type
+type MockIngestionBatchRepository struct {
+	mock.Mock
+}
+
+type MockIngestionBatchRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockIngestionBatchRepository) EXPECT() *MockIngestionBatchRepository_Expecter {
+	return &MockIngestionBatchRepository_Expecter{mock: &_m.Mock}
+}
+
+// ClearReversedContentHashes provides a mock function with given fields: ctx, batchIDs
+func (_m *MockIngestionBatchRepository) ClearReversedContentHashes(ctx context.Context, batchIDs []int64) error {
+	ret := _m.Called(ctx, batchIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClearReversedContentHashes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) error); ok {
+		r0 = rf(ctx, batchIDs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockIngestionBatchRepository_ClearReversedContentHashes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ClearReversedContentHashes'
+type MockIngestionBatchRepository_ClearReversedContentHashes_Call struct {
+	*mock.Call
+}
+
+// ClearReversedContentHashes is a helper method to define mock.On call
+//   - ctx context.Context
+//   - batchIDs []int64
+func (_e *MockIngestionBatchRepository_Expecter) ClearReversedContentHashes(ctx interface{}, batchIDs interface{}) *MockIngestionBatchRepository_ClearReversedContentHashes_Call {
+	return &MockIngestionBatchRepository_ClearReversedContentHashes_Call{Call: _e.mock.On("ClearReversedContentHashes", ctx, batchIDs)}
+}
+
+func (_c *MockIngestionBatchRepository_ClearReversedContentHashes_Call) Run(run func(ctx context.Context, batchIDs []int64)) *MockIngestionBatchRepository_ClearReversedContentHashes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]int64))
+	})
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_ClearReversedContentHashes_Call) Return(_a0 error) *MockIngestionBatchRepository_ClearReversedContentHashes_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_ClearReversedContentHashes_Call) RunAndReturn(run func(context.Context, []int64) error) *MockIngestionBatchRepository_ClearReversedContentHashes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByContentHash provides a mock function with given fields: ctx, accountID, contentHash
+func (_m *MockIngestionBatchRepository) GetByContentHash(ctx context.Context, accountID int64, contentHash string) (*domain.IngestionBatch, error) {
+	ret := _m.Called(ctx, accountID, contentHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByContentHash")
+	}
+
+	var r0 *domain.IngestionBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (*domain.IngestionBatch, error)); ok {
+		return rf(ctx, accountID, contentHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) *domain.IngestionBatch); ok {
+		r0 = rf(ctx, accountID, contentHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.IngestionBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, accountID, contentHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockIngestionBatchRepository_GetByContentHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByContentHash'
+type MockIngestionBatchRepository_GetByContentHash_Call struct {
+	*mock.Call
+}
+
+// GetByContentHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - accountID int64
+//   - contentHash string
+func (_e *MockIngestionBatchRepository_Expecter) GetByContentHash(ctx interface{}, accountID interface{}, contentHash interface{}) *MockIngestionBatchRepository_GetByContentHash_Call {
+	return &MockIngestionBatchRepository_GetByContentHash_Call{Call: _e.mock.On("GetByContentHash", ctx, accountID, contentHash)}
+}
+
+func (_c *MockIngestionBatchRepository_GetByContentHash_Call) Run(run func(ctx context.Context, accountID int64, contentHash string)) *MockIngestionBatchRepository_GetByContentHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_GetByContentHash_Call) Return(_a0 *domain.IngestionBatch, _a1 error) *MockIngestionBatchRepository_GetByContentHash_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_GetByContentHash_Call) RunAndReturn(run func(context.Context, int64, string) (*domain.IngestionBatch, error)) *MockIngestionBatchRepository_GetByContentHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Insert provides a mock function with given fields: ctx, m
+func (_m *MockIngestionBatchRepository) Insert(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Insert")
+	}
+
+	var r0 *domain.IngestionBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IngestionBatch) (*domain.IngestionBatch, error)); ok {
+		return rf(ctx, m)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IngestionBatch) *domain.IngestionBatch); ok {
+		r0 = rf(ctx, m)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.IngestionBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.IngestionBatch) error); ok {
+		r1 = rf(ctx, m)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockIngestionBatchRepository_Insert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Insert'
+type MockIngestionBatchRepository_Insert_Call struct {
+	*mock.Call
+}
+
+// Insert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.IngestionBatch
+func (_e *MockIngestionBatchRepository_Expecter) Insert(ctx interface{}, m interface{}) *MockIngestionBatchRepository_Insert_Call {
+	return &MockIngestionBatchRepository_Insert_Call{Call: _e.mock.On("Insert", ctx, m)}
+}
+
+func (_c *MockIngestionBatchRepository_Insert_Call) Run(run func(ctx context.Context, m *domain.IngestionBatch)) *MockIngestionBatchRepository_Insert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.IngestionBatch))
+	})
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_Insert_Call) Return(_a0 *domain.IngestionBatch, _a1 error) *MockIngestionBatchRepository_Insert_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_Insert_Call) RunAndReturn(run func(context.Context, *domain.IngestionBatch) (*domain.IngestionBatch, error)) *MockIngestionBatchRepository_Insert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, m
+func (_m *MockIngestionBatchRepository) Update(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 *domain.IngestionBatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IngestionBatch) (*domain.IngestionBatch, error)); ok {
+		return rf(ctx, m)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IngestionBatch) *domain.IngestionBatch); ok {
+		r0 = rf(ctx, m)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.IngestionBatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.IngestionBatch) error); ok {
+		r1 = rf(ctx, m)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockIngestionBatchRepository_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type MockIngestionBatchRepository_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.IngestionBatch
+func (_e *MockIngestionBatchRepository_Expecter) Update(ctx interface{}, m interface{}) *MockIngestionBatchRepository_Update_Call {
+	return &MockIngestionBatchRepository_Update_Call{Call: _e.mock.On("Update", ctx, m)}
+}
+
+func (_c *MockIngestionBatchRepository_Update_Call) Run(run func(ctx context.Context, m *domain.IngestionBatch)) *MockIngestionBatchRepository_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.IngestionBatch))
+	})
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_Update_Call) Return(_a0 *domain.IngestionBatch, _a1 error) *MockIngestionBatchRepository_Update_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockIngestionBatchRepository_Update_Call) RunAndReturn(run func(context.Context, *domain.IngestionBatch) (*domain.IngestionBatch, error)) *MockIngestionBatchRepository_Update_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockIngestionBatchRepository creates a new instance of MockIngestionBatchRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockIngestionBatchRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockIngestionBatchRepository {
+	mock := &MockIngestionBatchRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,200 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockLedgerRepository is an autogenerated mock type for the LedgerRepository type
+type MockLedgerRepository struct {
+	mock.Mock
+}
+
+type MockLedgerRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockLedgerRepository) EXPECT() *MockLedgerRepository_Expecter {
+	return &MockLedgerRepository_Expecter{mock: &_m.Mock}
+}
+
+// Post provides a mock function with given fields: ctx, entries
+func (_m *MockLedgerRepository) Post(ctx context.Context, entries []*domain.JournalEntry) error {
+	ret := _m.Called(ctx, entries)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Post")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*domain.JournalEntry) error); ok {
+		r0 = rf(ctx, entries)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLedgerRepository_Post_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Post'
+type MockLedgerRepository_Post_Call struct {
+	*mock.Call
+}
+
+// Post is a helper method to define mock.On call
+//   - ctx context.Context
+//   - entries []*domain.JournalEntry
+func (_e *MockLedgerRepository_Expecter) Post(ctx interface{}, entries interface{}) *MockLedgerRepository_Post_Call {
+	return &MockLedgerRepository_Post_Call{Call: _e.mock.On("Post", ctx, entries)}
+}
+
+func (_c *MockLedgerRepository_Post_Call) Run(run func(ctx context.Context, entries []*domain.JournalEntry)) *MockLedgerRepository_Post_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]*domain.JournalEntry))
+	})
+	return _c
+}
+
+func (_c *MockLedgerRepository_Post_Call) Return(_a0 error) *MockLedgerRepository_Post_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLedgerRepository_Post_Call) RunAndReturn(run func(context.Context, []*domain.JournalEntry) error) *MockLedgerRepository_Post_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Totals provides a mock function with given fields: ctx
+func (_m *MockLedgerRepository) Totals(ctx context.Context) ([]domain.LedgerTotal, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Totals")
+	}
+
+	var r0 []domain.LedgerTotal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]domain.LedgerTotal, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.LedgerTotal); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.LedgerTotal)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockLedgerRepository_Totals_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Totals'
+type MockLedgerRepository_Totals_Call struct {
+	*mock.Call
+}
+
+// Totals is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockLedgerRepository_Expecter) Totals(ctx interface{}) *MockLedgerRepository_Totals_Call {
+	return &MockLedgerRepository_Totals_Call{Call: _e.mock.On("Totals", ctx)}
+}
+
+func (_c *MockLedgerRepository_Totals_Call) Run(run func(ctx context.Context)) *MockLedgerRepository_Totals_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockLedgerRepository_Totals_Call) Return(_a0 []domain.LedgerTotal, _a1 error) *MockLedgerRepository_Totals_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockLedgerRepository_Totals_Call) RunAndReturn(run func(context.Context) ([]domain.LedgerTotal, error)) *MockLedgerRepository_Totals_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UnbalancedEntries provides a mock function with given fields: ctx
+func (_m *MockLedgerRepository) UnbalancedEntries(ctx context.Context) ([]int64, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnbalancedEntries")
+	}
+
+	var r0 []int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]int64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []int64); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockLedgerRepository_UnbalancedEntries_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnbalancedEntries'
+type MockLedgerRepository_UnbalancedEntries_Call struct {
+	*mock.Call
+}
+
+// UnbalancedEntries is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockLedgerRepository_Expecter) UnbalancedEntries(ctx interface{}) *MockLedgerRepository_UnbalancedEntries_Call {
+	return &MockLedgerRepository_UnbalancedEntries_Call{Call: _e.mock.On("UnbalancedEntries", ctx)}
+}
+
+func (_c *MockLedgerRepository_UnbalancedEntries_Call) Run(run func(ctx context.Context)) *MockLedgerRepository_UnbalancedEntries_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockLedgerRepository_UnbalancedEntries_Call) Return(_a0 []int64, _a1 error) *MockLedgerRepository_UnbalancedEntries_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockLedgerRepository_UnbalancedEntries_Call) RunAndReturn(run func(context.Context) ([]int64, error)) *MockLedgerRepository_UnbalancedEntries_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockLedgerRepository creates a new instance of MockLedgerRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockLedgerRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockLedgerRepository {
+	mock := &MockLedgerRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
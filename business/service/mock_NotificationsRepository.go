@@ -17,6 +17,52 @@ func (_m *MockNotificationsRepository) EXPECT() *MockNotificationsRepository_Exp
 	return &MockNotificationsRepository_Expecter{mock: &_m.Mock}
 }
 
+// Alert provides a mock function with given fields: data
+func (_m *MockNotificationsRepository) Alert(data interface{}) error {
+	ret := _m.Called(data)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Alert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(interface{}) error); ok {
+		r0 = rf(data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockNotificationsRepository_Alert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Alert'
+type MockNotificationsRepository_Alert_Call struct {
+	*mock.Call
+}
+
+// Alert is a helper method to define mock.On call
+//   - data interface{}
+func (_e *MockNotificationsRepository_Expecter) Alert(data interface{}) *MockNotificationsRepository_Alert_Call {
+	return &MockNotificationsRepository_Alert_Call{Call: _e.mock.On("Alert", data)}
+}
+
+func (_c *MockNotificationsRepository_Alert_Call) Run(run func(data interface{})) *MockNotificationsRepository_Alert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(interface{}))
+	})
+	return _c
+}
+
+func (_c *MockNotificationsRepository_Alert_Call) Return(_a0 error) *MockNotificationsRepository_Alert_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockNotificationsRepository_Alert_Call) RunAndReturn(run func(interface{}) error) *MockNotificationsRepository_Alert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Notify provides a mock function with given fields: data
 func (_m *MockNotificationsRepository) Notify(data interface{}) error {
 	ret := _m.Called(data)
@@ -0,0 +1,96 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockReversalRepository is an autogenerated mock type for the ReversalRepository type
+type MockReversalRepository struct {
+	mock.Mock
+}
+
+type MockReversalRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockReversalRepository) EXPECT() *MockReversalRepository_Expecter {
+	return &MockReversalRepository_Expecter{mock: &_m.Mock}
+}
+
+// Insert provides a mock function with given fields: ctx, m
+func (_m *MockReversalRepository) Insert(ctx context.Context, m *domain.Reversal) (*domain.Reversal, error) {
+	ret := _m.Called(ctx, m)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Insert")
+	}
+
+	var r0 *domain.Reversal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Reversal) (*domain.Reversal, error)); ok {
+		return rf(ctx, m)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Reversal) *domain.Reversal); ok {
+		r0 = rf(ctx, m)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Reversal)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Reversal) error); ok {
+		r1 = rf(ctx, m)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockReversalRepository_Insert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Insert'
+type MockReversalRepository_Insert_Call struct {
+	*mock.Call
+}
+
+// Insert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - m *domain.Reversal
+func (_e *MockReversalRepository_Expecter) Insert(ctx interface{}, m interface{}) *MockReversalRepository_Insert_Call {
+	return &MockReversalRepository_Insert_Call{Call: _e.mock.On("Insert", ctx, m)}
+}
+
+func (_c *MockReversalRepository_Insert_Call) Run(run func(ctx context.Context, m *domain.Reversal)) *MockReversalRepository_Insert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.Reversal))
+	})
+	return _c
+}
+
+func (_c *MockReversalRepository_Insert_Call) Return(_a0 *domain.Reversal, _a1 error) *MockReversalRepository_Insert_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockReversalRepository_Insert_Call) RunAndReturn(run func(context.Context, *domain.Reversal) (*domain.Reversal, error)) *MockReversalRepository_Insert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockReversalRepository creates a new instance of MockReversalRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockReversalRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockReversalRepository {
+	mock := &MockReversalRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
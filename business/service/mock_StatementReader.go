@@ -0,0 +1,137 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	domain "github.com/fedepezzola/transactions/business/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockStatementReader is an autogenerated mock type for the StatementReader type
+type MockStatementReader struct {
+	mock.Mock
+}
+
+type MockStatementReader_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockStatementReader) EXPECT() *MockStatementReader_Expecter {
+	return &MockStatementReader_Expecter{mock: &_m.Mock}
+}
+
+// Parse provides a mock function with given fields: rec, txn
+func (_m *MockStatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	ret := _m.Called(rec, txn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Parse")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(StatementRecord, *domain.Transaction) error); ok {
+		r0 = rf(rec, txn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStatementReader_Parse_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Parse'
+type MockStatementReader_Parse_Call struct {
+	*mock.Call
+}
+
+// Parse is a helper method to define mock.On call
+//   - rec StatementRecord
+//   - txn *domain.Transaction
+func (_e *MockStatementReader_Expecter) Parse(rec interface{}, txn interface{}) *MockStatementReader_Parse_Call {
+	return &MockStatementReader_Parse_Call{Call: _e.mock.On("Parse", rec, txn)}
+}
+
+func (_c *MockStatementReader_Parse_Call) Run(run func(rec StatementRecord, txn *domain.Transaction)) *MockStatementReader_Parse_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(StatementRecord), args[1].(*domain.Transaction))
+	})
+	return _c
+}
+
+func (_c *MockStatementReader_Parse_Call) Return(_a0 error) *MockStatementReader_Parse_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStatementReader_Parse_Call) RunAndReturn(run func(StatementRecord, *domain.Transaction) error) *MockStatementReader_Parse_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Read provides a mock function with given fields:
+func (_m *MockStatementReader) Read() (StatementRecord, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Read")
+	}
+
+	var r0 StatementRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (StatementRecord, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() StatementRecord); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(StatementRecord)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStatementReader_Read_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Read'
+type MockStatementReader_Read_Call struct {
+	*mock.Call
+}
+
+// Read is a helper method to define mock.On call
+func (_e *MockStatementReader_Expecter) Read() *MockStatementReader_Read_Call {
+	return &MockStatementReader_Read_Call{Call: _e.mock.On("Read")}
+}
+
+func (_c *MockStatementReader_Read_Call) Run(run func()) *MockStatementReader_Read_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockStatementReader_Read_Call) Return(_a0 StatementRecord, _a1 error) *MockStatementReader_Read_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStatementReader_Read_Call) RunAndReturn(run func() (StatementRecord, error)) *MockStatementReader_Read_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockStatementReader creates a new instance of MockStatementReader. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockStatementReader(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockStatementReader {
+	mock := &MockStatementReader{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -22,6 +22,231 @@ func (_m *MockTransactionRepository) EXPECT() *MockTransactionRepository_Expecte
 	return &MockTransactionRepository_Expecter{mock: &_m.Mock}
 }
 
+// BulkInsert provides a mock function with given fields: ctx, txns
+func (_m *MockTransactionRepository) BulkInsert(ctx context.Context, txns []*domain.Transaction) error {
+	ret := _m.Called(ctx, txns)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BulkInsert")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*domain.Transaction) error); ok {
+		r0 = rf(ctx, txns)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockTransactionRepository_BulkInsert_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BulkInsert'
+type MockTransactionRepository_BulkInsert_Call struct {
+	*mock.Call
+}
+
+// BulkInsert is a helper method to define mock.On call
+//   - ctx context.Context
+//   - txns []*domain.Transaction
+func (_e *MockTransactionRepository_Expecter) BulkInsert(ctx interface{}, txns interface{}) *MockTransactionRepository_BulkInsert_Call {
+	return &MockTransactionRepository_BulkInsert_Call{Call: _e.mock.On("BulkInsert", ctx, txns)}
+}
+
+func (_c *MockTransactionRepository_BulkInsert_Call) Run(run func(ctx context.Context, txns []*domain.Transaction)) *MockTransactionRepository_BulkInsert_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]*domain.Transaction))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_BulkInsert_Call) Return(_a0 error) *MockTransactionRepository_BulkInsert_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockTransactionRepository_BulkInsert_Call) RunAndReturn(run func(context.Context, []*domain.Transaction) error) *MockTransactionRepository_BulkInsert_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByBatchID provides a mock function with given fields: ctx, batchID
+func (_m *MockTransactionRepository) GetByBatchID(ctx context.Context, batchID int64) ([]*domain.Transaction, error) {
+	ret := _m.Called(ctx, batchID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByBatchID")
+	}
+
+	var r0 []*domain.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]*domain.Transaction, error)); ok {
+		return rf(ctx, batchID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*domain.Transaction); ok {
+		r0 = rf(ctx, batchID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, batchID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockTransactionRepository_GetByBatchID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByBatchID'
+type MockTransactionRepository_GetByBatchID_Call struct {
+	*mock.Call
+}
+
+// GetByBatchID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - batchID int64
+func (_e *MockTransactionRepository_Expecter) GetByBatchID(ctx interface{}, batchID interface{}) *MockTransactionRepository_GetByBatchID_Call {
+	return &MockTransactionRepository_GetByBatchID_Call{Call: _e.mock.On("GetByBatchID", ctx, batchID)}
+}
+
+func (_c *MockTransactionRepository_GetByBatchID_Call) Run(run func(ctx context.Context, batchID int64)) *MockTransactionRepository_GetByBatchID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_GetByBatchID_Call) Return(_a0 []*domain.Transaction, _a1 error) *MockTransactionRepository_GetByBatchID_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockTransactionRepository_GetByBatchID_Call) RunAndReturn(run func(context.Context, int64) ([]*domain.Transaction, error)) *MockTransactionRepository_GetByBatchID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *MockTransactionRepository) GetByID(ctx context.Context, id int64) (*domain.Transaction, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *domain.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*domain.Transaction, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *domain.Transaction); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockTransactionRepository_GetByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByID'
+type MockTransactionRepository_GetByID_Call struct {
+	*mock.Call
+}
+
+// GetByID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+func (_e *MockTransactionRepository_Expecter) GetByID(ctx interface{}, id interface{}) *MockTransactionRepository_GetByID_Call {
+	return &MockTransactionRepository_GetByID_Call{Call: _e.mock.On("GetByID", ctx, id)}
+}
+
+func (_c *MockTransactionRepository_GetByID_Call) Run(run func(ctx context.Context, id int64)) *MockTransactionRepository_GetByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_GetByID_Call) Return(_a0 *domain.Transaction, _a1 error) *MockTransactionRepository_GetByID_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockTransactionRepository_GetByID_Call) RunAndReturn(run func(context.Context, int64) (*domain.Transaction, error)) *MockTransactionRepository_GetByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ImportedFileIDs provides a mock function with given fields: ctx, accountID, fileTransactionIDs
+func (_m *MockTransactionRepository) ImportedFileIDs(ctx context.Context, accountID int64, fileTransactionIDs []string) ([]string, error) {
+	ret := _m.Called(ctx, accountID, fileTransactionIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportedFileIDs")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) ([]string, error)); ok {
+		return rf(ctx, accountID, fileTransactionIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []string) []string); ok {
+		r0 = rf(ctx, accountID, fileTransactionIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []string) error); ok {
+		r1 = rf(ctx, accountID, fileTransactionIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockTransactionRepository_ImportedFileIDs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportedFileIDs'
+type MockTransactionRepository_ImportedFileIDs_Call struct {
+	*mock.Call
+}
+
+// ImportedFileIDs is a helper method to define mock.On call
+//   - ctx context.Context
+//   - accountID int64
+//   - fileTransactionIDs []string
+func (_e *MockTransactionRepository_Expecter) ImportedFileIDs(ctx interface{}, accountID interface{}, fileTransactionIDs interface{}) *MockTransactionRepository_ImportedFileIDs_Call {
+	return &MockTransactionRepository_ImportedFileIDs_Call{Call: _e.mock.On("ImportedFileIDs", ctx, accountID, fileTransactionIDs)}
+}
+
+func (_c *MockTransactionRepository_ImportedFileIDs_Call) Run(run func(ctx context.Context, accountID int64, fileTransactionIDs []string)) *MockTransactionRepository_ImportedFileIDs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].([]string))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_ImportedFileIDs_Call) Return(_a0 []string, _a1 error) *MockTransactionRepository_ImportedFileIDs_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockTransactionRepository_ImportedFileIDs_Call) RunAndReturn(run func(context.Context, int64, []string) ([]string, error)) *MockTransactionRepository_ImportedFileIDs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Insert provides a mock function with given fields: ctx, m
 func (_m *MockTransactionRepository) Insert(ctx context.Context, m *domain.Transaction) (*domain.Transaction, error) {
 	ret := _m.Called(ctx, m)
@@ -81,6 +306,112 @@ func (_c *MockTransactionRepository_Insert_Call) RunAndReturn(run func(context.C
 	return _c
 }
 
+// ListByAccount provides a mock function with given fields: ctx, query
+func (_m *MockTransactionRepository) ListByAccount(ctx context.Context, query domain.TransactionQuery) (*domain.TransactionPage, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByAccount")
+	}
+
+	var r0 *domain.TransactionPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.TransactionQuery) (*domain.TransactionPage, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.TransactionQuery) *domain.TransactionPage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.TransactionPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.TransactionQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockTransactionRepository_ListByAccount_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByAccount'
+type MockTransactionRepository_ListByAccount_Call struct {
+	*mock.Call
+}
+
+// ListByAccount is a helper method to define mock.On call
+//   - ctx context.Context
+//   - query domain.TransactionQuery
+func (_e *MockTransactionRepository_Expecter) ListByAccount(ctx interface{}, query interface{}) *MockTransactionRepository_ListByAccount_Call {
+	return &MockTransactionRepository_ListByAccount_Call{Call: _e.mock.On("ListByAccount", ctx, query)}
+}
+
+func (_c *MockTransactionRepository_ListByAccount_Call) Run(run func(ctx context.Context, query domain.TransactionQuery)) *MockTransactionRepository_ListByAccount_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.TransactionQuery))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_ListByAccount_Call) Return(_a0 *domain.TransactionPage, _a1 error) *MockTransactionRepository_ListByAccount_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockTransactionRepository_ListByAccount_Call) RunAndReturn(run func(context.Context, domain.TransactionQuery) (*domain.TransactionPage, error)) *MockTransactionRepository_ListByAccount_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkReversed provides a mock function with given fields: ctx, ids
+func (_m *MockTransactionRepository) MarkReversed(ctx context.Context, ids []int64) error {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkReversed")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) error); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockTransactionRepository_MarkReversed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkReversed'
+type MockTransactionRepository_MarkReversed_Call struct {
+	*mock.Call
+}
+
+// MarkReversed is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []int64
+func (_e *MockTransactionRepository_Expecter) MarkReversed(ctx interface{}, ids interface{}) *MockTransactionRepository_MarkReversed_Call {
+	return &MockTransactionRepository_MarkReversed_Call{Call: _e.mock.On("MarkReversed", ctx, ids)}
+}
+
+func (_c *MockTransactionRepository_MarkReversed_Call) Run(run func(ctx context.Context, ids []int64)) *MockTransactionRepository_MarkReversed_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]int64))
+	})
+	return _c
+}
+
+func (_c *MockTransactionRepository_MarkReversed_Call) Return(_a0 error) *MockTransactionRepository_MarkReversed_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockTransactionRepository_MarkReversed_Call) RunAndReturn(run func(context.Context, []int64) error) *MockTransactionRepository_MarkReversed_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockTransactionRepository creates a new instance of MockTransactionRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockTransactionRepository(t interface {
@@ -0,0 +1,83 @@
+// Code generated by mockery v2.42.1. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockTransactor is an autogenerated mock type for the Transactor type
+type MockTransactor struct {
+	mock.Mock
+}
+
+type MockTransactor_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockTransactor) EXPECT() *MockTransactor_Expecter {
+	return &MockTransactor_Expecter{mock: &_m.Mock}
+}
+
+// WithinTran provides a mock function with given fields: ctx, fn
+func (_m *MockTransactor) WithinTran(ctx context.Context, fn func(Repositories) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithinTran")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(Repositories) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockTransactor_WithinTran_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WithinTran'
+type MockTransactor_WithinTran_Call struct {
+	*mock.Call
+}
+
+// WithinTran is a helper method to define mock.On call
+//   - ctx context.Context
+//   - fn func(Repositories) error
+func (_e *MockTransactor_Expecter) WithinTran(ctx interface{}, fn interface{}) *MockTransactor_WithinTran_Call {
+	return &MockTransactor_WithinTran_Call{Call: _e.mock.On("WithinTran", ctx, fn)}
+}
+
+func (_c *MockTransactor_WithinTran_Call) Run(run func(ctx context.Context, fn func(Repositories) error)) *MockTransactor_WithinTran_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(func(Repositories) error))
+	})
+	return _c
+}
+
+func (_c *MockTransactor_WithinTran_Call) Return(_a0 error) *MockTransactor_WithinTran_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockTransactor_WithinTran_Call) RunAndReturn(run func(context.Context, func(Repositories) error) error) *MockTransactor_WithinTran_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockTransactor creates a new instance of MockTransactor. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockTransactor(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockTransactor {
+	mock := &MockTransactor{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"go.uber.org/zap"
+)
+
+// ErrTransactionNotFound is returned when reversing a transaction or a batch
+// that does not exist.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrAlreadyReversed is returned when reversing transactions that were
+// already offset.
+var ErrAlreadyReversed = errors.New("already reversed")
+
+// ErrNotReversible is returned when reversing a transaction posted by another
+// reversal.
+var ErrNotReversible = errors.New("transaction can not be reversed")
+
+// ReversalService offsets imported transactions without deleting them.
+type ReversalService struct {
+	log        *zap.SugaredLogger
+	Transactor Transactor
+}
+
+func NewReversalService(log *zap.SugaredLogger, transactor Transactor) *ReversalService {
+	return &ReversalService{
+		log:        log,
+		Transactor: transactor,
+	}
+}
+
+// ReverseTransaction offsets one transaction.
+func (s *ReversalService) ReverseTransaction(ctx context.Context, transactionID int64, reversedBy string, reason string) (*domain.Reversal, error) {
+	reversal := &domain.Reversal{
+		TransactionID: transactionID,
+		Reason:        reason,
+		ReversedBy:    reversedBy,
+	}
+
+	return s.reverse(ctx, reversal, func(repos Repositories) ([]*domain.Transaction, error) {
+		txn, err := repos.Transaction.GetByID(ctx, transactionID)
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, fmt.Errorf("%w: id %d", ErrTransactionNotFound, transactionID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving transaction: %w", err)
+		}
+
+		switch {
+		case txn.IsReversal():
+			return nil, fmt.Errorf("%w: transaction %d reverses transaction %d", ErrNotReversible, txn.ID, txn.ReversesTransactionID)
+		case txn.Reversed():
+			return nil, fmt.Errorf("%w: transaction %d by transaction %d", ErrAlreadyReversed, txn.ID, txn.ReversedByID)
+		}
+		return []*domain.Transaction{txn}, nil
+	})
+}
+
+// ReverseBatch offsets every transaction imported by an ingestion batch that
+// was not reversed on its own before.
+func (s *ReversalService) ReverseBatch(ctx context.Context, batchID int64, reversedBy string, reason string) (*domain.Reversal, error) {
+	reversal := &domain.Reversal{
+		BatchID:    batchID,
+		Reason:     reason,
+		ReversedBy: reversedBy,
+	}
+
+	return s.reverse(ctx, reversal, func(repos Repositories) ([]*domain.Transaction, error) {
+		txns, err := repos.Transaction.GetByBatchID(ctx, batchID)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving transactions: %w", err)
+		}
+		if len(txns) == 0 {
+			return nil, fmt.Errorf("%w: batch %d", ErrTransactionNotFound, batchID)
+		}
+
+		txns = slices.DeleteFunc(txns, func(txn *domain.Transaction) bool {
+			return txn.Reversed()
+		})
+		if len(txns) == 0 {
+			return nil, fmt.Errorf("%w: batch %d", ErrAlreadyReversed, batchID)
+		}
+		return txns, nil
+	})
+}
+
+// reverse records the reversal and posts a transaction offsetting each of the
+// ones returned by load, with its ledger entries, moving the balance of their
+// accounts and marking them reversed, all in one database transaction. The
+// accounts are locked in id order before anything is written.
+func (s *ReversalService) reverse(ctx context.Context, reversal *domain.Reversal, load func(Repositories) ([]*domain.Transaction, error)) (*domain.Reversal, error) {
+	if err := reversal.Validate(); err != nil {
+		return nil, err
+	}
+	reversal.ReversedAt = time.Now()
+
+	err := s.Transactor.WithinTran(ctx, func(repos Repositories) error {
+		txns, err := load(repos)
+		if err != nil {
+			return err
+		}
+
+		accounts, err := lockAccountsByID(ctx, repos, txns)
+		if err != nil {
+			return err
+		}
+
+		if _, err := repos.Reversal.Insert(ctx, reversal); err != nil {
+			return fmt.Errorf("error recording reversal: %w", err)
+		}
+
+		offsets := make([]*domain.Transaction, len(txns))
+		for i, txn := range txns {
+			offsets[i] = txn.Offset(reversal)
+		}
+		err = repos.Transaction.BulkInsert(ctx, offsets)
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			// Another reversal offset some of them in the meantime.
+			return fmt.Errorf("%w: %w", ErrAlreadyReversed, err)
+		}
+		if err != nil {
+			return fmt.Errorf("error storing transactions: %w", err)
+		}
+		if err := markReversed(ctx, repos, txns); err != nil {
+			return err
+		}
+
+		entries := make([]*domain.JournalEntry, len(offsets))
+		for i, offset := range offsets {
+			account := accounts[offset.AccountID]
+			clearing, err := clearingAccount(ctx, repos, account.Currency, reversal.ReversedAt)
+			if err != nil {
+				return err
+			}
+			entries[i] = domain.NewTransactionEntry(offset, account, clearing, reversal.ReversedAt)
+			entries[i].Description = "reversal"
+			account.Balance += offset.Amount
+		}
+		if err := repos.Ledger.Post(ctx, entries); err != nil {
+			return fmt.Errorf("error posting ledger entries: %w", err)
+		}
+
+		for _, account := range accounts {
+			if _, err := repos.Account.Update(ctx, account); err != nil {
+				return fmt.Errorf("error updating account: %w", err)
+			}
+		}
+
+		reversal.Transactions = offsets
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("transactions reversed", "reversal", reversal.ID, "batch", reversal.BatchID, "transaction", reversal.TransactionID, "count", len(reversal.Transactions), "reversed_by", reversal.ReversedBy, "reason", reversal.Reason)
+
+	return reversal, nil
+}
+
+// markReversed flags txns as reversed, and forgets the content of the batches
+// left with no transaction that is not reversed, so the rows and files can be
+// imported again.
+func markReversed(ctx context.Context, repos Repositories, txns []*domain.Transaction) error {
+	ids := make([]int64, 0, len(txns))
+	var batchIDs []int64
+	for _, txn := range txns {
+		ids = append(ids, txn.ID)
+		if txn.BatchID != 0 {
+			batchIDs = append(batchIDs, txn.BatchID)
+		}
+	}
+	slices.Sort(batchIDs)
+	batchIDs = slices.Compact(batchIDs)
+
+	if err := repos.Transaction.MarkReversed(ctx, ids); err != nil {
+		return fmt.Errorf("error marking transactions reversed: %w", err)
+	}
+	if len(batchIDs) == 0 {
+		return nil
+	}
+	if err := repos.Batch.ClearReversedContentHashes(ctx, batchIDs); err != nil {
+		return fmt.Errorf("error clearing content hash: %w", err)
+	}
+	return nil
+}
+
+// lockAccountsByID locks the accounts of txns in id order. Frozen and closed
+// accounts take no reversals, like they take no imports.
+func lockAccountsByID(ctx context.Context, repos Repositories, txns []*domain.Transaction) (map[int64]*domain.Account, error) {
+	ids := make([]int64, 0, len(txns))
+	for _, txn := range txns {
+		ids = append(ids, txn.AccountID)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	accounts := make(map[int64]*domain.Account, len(ids))
+	for _, id := range ids {
+		account, err := repos.Account.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving account: %w", err)
+		}
+		if !account.IsActive() {
+			return nil, fmt.Errorf("%w: account %s is %s", ErrAccountInactive, account.AccountNumber, account.Status)
+		}
+		if account.Currency == "" {
+			account.Currency = DefaultCurrency
+		}
+		accounts[id] = account
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func reversalSetup(t *testing.T) (*testHelper, *service.ReversalService) {
+	t.Helper()
+
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByIDForUpdate(mock.Anything, int64(1)).Return(h.account, nil)
+	h.reversalRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Reversal")).RunAndReturn(func(_ context.Context, r *domain.Reversal) (*domain.Reversal, error) {
+		r.ID = 5
+		return r, nil
+	})
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+	h.transactionRepository.EXPECT().MarkReversed(mock.Anything, mock.AnythingOfType("[]int64")).Return(nil)
+	h.batchRepository.EXPECT().ClearReversedContentHashes(mock.Anything, mock.AnythingOfType("[]int64")).Return(nil)
+
+	return h, service.NewReversalService(h.log, h.transactor)
+}
+
+func Test_ReversalService_ReverseTransaction(t *testing.T) {
+	t.Parallel()
+	h, s := reversalSetup(t)
+	h.transactionRepository.EXPECT().GetByID(mock.Anything, int64(3)).Return(&domain.Transaction{
+		ID:                3,
+		AccountID:         1,
+		BatchID:           7,
+		FileTransactionID: "1",
+		Amount:            domain.MustParseAmount("-10.3"),
+		Currency:          "USD",
+		OriginalAmount:    domain.MustParseAmount("-10.3"),
+		FXRate:            domain.OneRate,
+	}, nil)
+
+	var posted []*domain.JournalEntry
+	h.ledgerRepository.ExpectedCalls = nil
+	h.ledgerRepository.EXPECT().Post(mock.Anything, mock.AnythingOfType("[]*domain.JournalEntry")).RunAndReturn(func(_ context.Context, entries []*domain.JournalEntry) error {
+		posted = append(posted, entries...)
+		return nil
+	})
+
+	reversal, err := s.ReverseTransaction(h.ctx, 3, "ops", "duplicated by the bank")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), reversal.ID)
+	assert.Equal(t, "ops", reversal.ReversedBy)
+	if assert.Len(t, reversal.Transactions, 1) {
+		offset := reversal.Transactions[0]
+		assert.Equal(t, domain.MustParseAmount("10.3"), offset.Amount)
+		assert.Equal(t, int64(3), offset.ReversesTransactionID)
+		assert.Equal(t, int64(5), offset.ReversalID)
+		assert.Zero(t, offset.BatchID)
+	}
+	assert.Equal(t, domain.MustParseAmount("20.3"), h.account.Balance)
+	h.accountRepository.AssertNumberOfCalls(t, "Update", 1)
+	h.transactionRepository.AssertCalled(t, "MarkReversed", mock.Anything, []int64{3})
+	h.batchRepository.AssertCalled(t, "ClearReversedContentHashes", mock.Anything, []int64{7})
+	if assert.Len(t, posted, 1) {
+		assert.NoError(t, posted[0].Validate())
+		assert.Equal(t, "reversal", posted[0].Description)
+		assert.Equal(t, domain.MustParseAmount("10.3"), posted[0].Lines[0].Amount)
+	}
+}
+
+func Test_ReversalService_ReverseTransaction_refuses(t *testing.T) {
+	t.Parallel()
+	h, s := reversalSetup(t)
+	h.transactionRepository.EXPECT().GetByID(mock.Anything, int64(3)).Return(&domain.Transaction{ID: 3, AccountID: 1, ReversedByID: 9}, nil)
+	h.transactionRepository.EXPECT().GetByID(mock.Anything, int64(9)).Return(&domain.Transaction{ID: 9, AccountID: 1, ReversesTransactionID: 3}, nil)
+	h.transactionRepository.EXPECT().GetByID(mock.Anything, int64(4)).Return(nil, database.ErrDBNotFound)
+
+	_, err := s.ReverseTransaction(h.ctx, 3, "ops", "again")
+	assert.ErrorIs(t, err, service.ErrAlreadyReversed)
+
+	_, err = s.ReverseTransaction(h.ctx, 9, "ops", "undo the reversal")
+	assert.ErrorIs(t, err, service.ErrNotReversible)
+
+	_, err = s.ReverseTransaction(h.ctx, 4, "ops", "missing")
+	assert.ErrorIs(t, err, service.ErrTransactionNotFound)
+
+	_, err = s.ReverseTransaction(h.ctx, 3, "ops", "")
+	assert.ErrorIs(t, err, domain.ErrInvalidReversal)
+
+	h.account.Status = domain.AccountFrozen
+	h.transactionRepository.EXPECT().GetByID(mock.Anything, int64(5)).Return(&domain.Transaction{ID: 5, AccountID: 1}, nil)
+	_, err = s.ReverseTransaction(h.ctx, 5, "ops", "frozen")
+	assert.ErrorIs(t, err, service.ErrAccountInactive)
+
+	h.reversalRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	h.transactionRepository.AssertNotCalled(t, "BulkInsert", mock.Anything, mock.Anything)
+	h.transactionRepository.AssertNotCalled(t, "MarkReversed", mock.Anything, mock.Anything)
+}
+
+func Test_ReversalService_ReverseBatch(t *testing.T) {
+	t.Parallel()
+	h, s := reversalSetup(t)
+	h.transactionRepository.EXPECT().GetByBatchID(mock.Anything, int64(7)).Return([]*domain.Transaction{
+		{ID: 1, AccountID: 1, BatchID: 7, Amount: domain.MustParseAmount("60.5")},
+		{ID: 2, AccountID: 1, BatchID: 7, Amount: domain.MustParseAmount("-10.3"), ReversedByID: 11},
+		{ID: 3, AccountID: 1, BatchID: 7, Amount: domain.MustParseAmount("-20.46")},
+	}, nil)
+	h.transactionRepository.EXPECT().GetByBatchID(mock.Anything, int64(8)).Return([]*domain.Transaction{
+		{ID: 4, AccountID: 1, BatchID: 8, ReversedByID: 12},
+	}, nil)
+	h.transactionRepository.EXPECT().GetByBatchID(mock.Anything, int64(9)).Return(nil, nil)
+
+	reversal, err := s.ReverseBatch(h.ctx, 7, "ops", "wrong file")
+	assert.NoError(t, err)
+	var reversed []int64
+	for _, offset := range reversal.Transactions {
+		reversed = append(reversed, offset.ReversesTransactionID)
+	}
+	assert.Equal(t, []int64{1, 3}, reversed)
+	assert.Equal(t, domain.MustParseAmount("-30.04"), h.account.Balance)
+	h.transactionRepository.AssertCalled(t, "MarkReversed", mock.Anything, []int64{1, 3})
+	h.batchRepository.AssertCalled(t, "ClearReversedContentHashes", mock.Anything, []int64{7})
+
+	_, err = s.ReverseBatch(h.ctx, 8, "ops", "wrong file")
+	assert.ErrorIs(t, err, service.ErrAlreadyReversed)
+
+	_, err = s.ReverseBatch(h.ctx, 9, "ops", "wrong file")
+	assert.ErrorIs(t, err, service.ErrTransactionNotFound)
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// Statement formats accepted by NewStatementReader.
+const (
+	FormatAuto    = "auto"
+	FormatCSV     = "csv"
+	FormatOFX     = "ofx"
+	FormatCAMT053 = "camt053"
+	FormatMT940   = "mt940"
+)
+
+type (
+	// StatementRecord is one raw entry read from a statement file, before it
+	// is turned into a transaction.
+	StatementRecord struct {
+		// Line is the line of the file where the entry starts.
+		Line int
+		// Raw is the entry as it appears in the file.
+		Raw string
+		// Fields holds the values of the entry in file order.
+		Fields []string
+		// Account is the account number the entry belongs to, when the file
+		// names one.
+		Account string
+	}
+
+	// StatementReader reads the entries of a statement file. Read returns
+	// io.EOF once the statement is over. Parse turns a record into a
+	// transaction and is safe to call from several goroutines.
+	StatementReader interface {
+		Read() (StatementRecord, error)
+		Parse(rec StatementRecord, txn *domain.Transaction) error
+	}
+
+	// StatementBalances are the balances a bank reports for an account in a
+	// statement.
+	StatementBalances struct {
+		// Currency is the currency of the balances, when the file names it.
+		Currency    string
+		Opening     *domain.Amount
+		Closing     *domain.Amount
+		ClosingDate time.Time
+	}
+
+	// BalanceReporter is implemented by statement readers that know the
+	// balances reported by the bank, keyed by the account id used in the
+	// file. They are complete once Read returned io.EOF.
+	BalanceReporter interface {
+		Balances() map[string]StatementBalances
+	}
+)
+
+// NewStatementReader returns a reader for r in the given format. FormatAuto
+// looks at the start of the content to tell OFX from CSV.
+func NewStatementReader(r io.Reader, format string, csvFormat CSVFormat) (StatementReader, error) {
+	if format == "" || format == FormatAuto {
+		br := bufio.NewReader(r)
+		format = sniffFormat(br)
+		r = br
+	}
+
+	switch format {
+	case FormatCSV:
+		return NewCSVStatementReader(r, csvFormat)
+	case FormatOFX:
+		return NewOFXStatementReader(r)
+	case FormatCAMT053:
+		return NewCAMT053StatementReader(r)
+	case FormatMT940:
+		return NewMT940StatementReader(r)
+	default:
+		return nil, fmt.Errorf("unknown statement format %q", format)
+	}
+}
+
+// sniffFormat guesses the format of a statement from its first bytes.
+func sniffFormat(br *bufio.Reader) string {
+	head, _ := br.Peek(1024)
+	head = bytes.ToUpper(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\ufeff"))))
+
+	switch {
+	case bytes.HasPrefix(head, []byte("OFXHEADER")),
+		bytes.Contains(head, []byte("<?OFX")),
+		bytes.Contains(head, []byte("<OFX>")):
+		return FormatOFX
+	case bytes.Contains(head, []byte("CAMT.053")),
+		bytes.Contains(head, []byte("<BKTOCSTMRSTMT")):
+		return FormatCAMT053
+	case bytes.HasPrefix(head, []byte(":20:")),
+		bytes.Contains(head, []byte("{4:")),
+		bytes.Contains(head, []byte("\n:20:")):
+		return FormatMT940
+	default:
+		return FormatCSV
+	}
+}
+
+// StatementRowError is returned by StatementReader.Read when one entry of the
+// file is malformed but the entries after it can still be read.
+type StatementRowError struct {
+	Line int
+	Raw  string
+	Err  error
+}
+
+func (e *StatementRowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *StatementRowError) Unwrap() error {
+	return e.Err
+}
+
+// Positions of the values of a bank statement entry in the fields of a
+// record, shared by the camt.053 and MT940 readers.
+const (
+	bankFieldID = iota
+	bankFieldBookingDate
+	bankFieldValueDate
+	bankFieldAmount
+	bankFieldCurrency
+	bankFieldRemittance
+	bankFieldCounterparty
+	bankFieldReference
+	bankFieldType
+	bankFieldCount
+)
+
+// bankEntry holds the values of a bank statement entry as read from the file.
+// Dates are written as YYYY-MM-DD.
+type bankEntry struct {
+	id           string
+	booking      string
+	value        string
+	amount       string
+	debit        bool
+	currency     string
+	remittance   string
+	counterparty string
+	reference    string
+	kind         string
+}
+
+// fields returns the values of the entry in record order, with the amount
+// signed by its credit or debit indicator.
+func (e bankEntry) fields() []string {
+	fields := make([]string, bankFieldCount)
+	fields[bankFieldID] = e.id
+	fields[bankFieldBookingDate] = e.booking
+	fields[bankFieldValueDate] = e.value
+	fields[bankFieldAmount] = e.amount
+	if e.debit {
+		fields[bankFieldAmount] = "-" + e.amount
+	}
+	fields[bankFieldCurrency] = e.currency
+	fields[bankFieldRemittance] = e.remittance
+	fields[bankFieldCounterparty] = e.counterparty
+	fields[bankFieldReference] = e.reference
+	fields[bankFieldType] = e.kind
+	return fields
+}
+
+// parseBankEntry reads a record built from a bankEntry into txn.
+func parseBankEntry(rec StatementRecord, txn *domain.Transaction) error {
+	if len(rec.Fields) != bankFieldCount {
+		return fmt.Errorf("unexpected record with %d fields", len(rec.Fields))
+	}
+
+	txn.FileTransactionID = rec.Fields[bankFieldID]
+
+	var err error
+	txn.Date, err = parseBankDate(rec.Fields[bankFieldBookingDate])
+	if err != nil {
+		return fmt.Errorf("error reading booking date: %w", err)
+	}
+
+	if v := rec.Fields[bankFieldValueDate]; v != "" {
+		valueDate, err := parseBankDate(v)
+		if err != nil {
+			return fmt.Errorf("error reading value date: %w", err)
+		}
+		txn.ValueDate = &valueDate
+	}
+
+	txn.Amount, err = domain.ParseAmount(strings.Replace(rec.Fields[bankFieldAmount], ",", ".", 1))
+	if err != nil {
+		return fmt.Errorf("error reading amount: %w", err)
+	}
+
+	txn.Currency = rec.Fields[bankFieldCurrency]
+	txn.Description = rec.Fields[bankFieldRemittance]
+	txn.Counterparty = rec.Fields[bankFieldCounterparty]
+	txn.Reference = rec.Fields[bankFieldReference]
+	txn.Type = rec.Fields[bankFieldType]
+
+	return nil
+}
+
+// parseBankDate reads a date written as YYYY-MM-DD.
+func parseBankDate(s string) (time.Time, error) {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// ErrInvalidCAMT053 is returned when a file can not be read as an ISO 20022
+// camt.053 statement.
+var ErrInvalidCAMT053 = errors.New("invalid camt.053")
+
+// CAMT053StatementReader reads the entries of an ISO 20022 camt.053 bank to
+// customer statement. Every Ntry is one transaction.
+type CAMT053StatementReader struct {
+	records  []StatementRecord
+	next     int
+	balances map[string]StatementBalances
+}
+
+// camtEntry collects the values of the Ntry being read.
+type camtEntry struct {
+	start      int64
+	line       int
+	ref        string
+	svcrRef    string
+	amount     string
+	currency   string
+	debit      bool
+	booking    string
+	value      string
+	remittance []string
+	debtor     string
+	creditor   string
+	endToEnd   string
+	cdtrRef    string
+	txCode     []string
+	prtryCode  string
+}
+
+// camtBalance collects the values of the Bal being read.
+type camtBalance struct {
+	code     string
+	amount   string
+	currency string
+	debit    bool
+	date     string
+}
+
+// NewCAMT053StatementReader reads the whole statement in r. The balances of a
+// statement may come before or after its entries, so the file is parsed up
+// front.
+func NewCAMT053StatementReader(r io.Reader) (*CAMT053StatementReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	s := &CAMT053StatementReader{
+		balances: make(map[string]StatementBalances),
+	}
+	if err := s.parse(data); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// parse walks the elements of the document, keeping the path of local names
+// from the root to the current element.
+func (s *CAMT053StatementReader) parse(data []byte) error {
+	var (
+		path     []string
+		found    bool
+		stmtID   string
+		account  string
+		entries  int
+		entry    *camtEntry
+		balance  *camtBalance
+		previous int64
+	)
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCAMT053, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			switch t.Name.Local {
+			case "BkToCstmrStmt":
+				found = true
+			case "Stmt":
+				stmtID, account, entries = "", "", 0
+			case "Ntry":
+				line, _ := dec.InputPos()
+				entry = &camtEntry{start: previous, line: line}
+			case "Bal":
+				balance = &camtBalance{}
+			case "Amt":
+				for _, a := range t.Attr {
+					switch {
+					case a.Name.Local != "Ccy":
+					case entry != nil && parentIs(path, "Ntry"):
+						entry.currency = a.Value
+					case balance != nil && parentIs(path, "Bal"):
+						balance.currency = a.Value
+					}
+				}
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "Ntry":
+				if entry != nil {
+					entries++
+					s.addEntry(entry, data[entry.start:dec.InputOffset()], account, stmtID, entries)
+					entry = nil
+				}
+			case "Bal":
+				if balance != nil {
+					s.setBalance(account, balance)
+					balance = nil
+				}
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(path) == 0 {
+				break
+			}
+			switch {
+			case entry != nil:
+				entry.set(path, text)
+			case balance != nil:
+				balance.set(path, text)
+			case pathEndsWith(path, "Stmt", "Id"):
+				stmtID = text
+			case pathEndsWith(path, "Stmt", "Acct", "Id", "IBAN"),
+				pathEndsWith(path, "Stmt", "Acct", "Id", "Othr", "Id"):
+				account = text
+			}
+		}
+
+		previous = dec.InputOffset()
+	}
+
+	if !found {
+		return fmt.Errorf("%w: no BkToCstmrStmt element", ErrInvalidCAMT053)
+	}
+
+	return nil
+}
+
+// set keeps text when it is one of the values of the entry.
+func (e *camtEntry) set(path []string, text string) {
+	switch {
+	case pathEndsWith(path, "Ntry", "NtryRef"):
+		e.ref = text
+	case pathEndsWith(path, "Ntry", "AcctSvcrRef"):
+		e.svcrRef = text
+	case pathEndsWith(path, "Ntry", "Amt"):
+		e.amount = text
+	case pathEndsWith(path, "Ntry", "CdtDbtInd"):
+		e.debit = text == "DBIT"
+	case pathEndsWith(path, "Ntry", "BookgDt", "Dt"),
+		pathEndsWith(path, "Ntry", "BookgDt", "DtTm"):
+		e.booking = camtDate(text)
+	case pathEndsWith(path, "Ntry", "ValDt", "Dt"),
+		pathEndsWith(path, "Ntry", "ValDt", "DtTm"):
+		e.value = camtDate(text)
+	case pathEndsWith(path, "RmtInf", "Ustrd"):
+		e.remittance = append(e.remittance, text)
+	case pathEndsWith(path, "RltdPties", "Dbtr", "Nm"),
+		pathEndsWith(path, "RltdPties", "Dbtr", "Pty", "Nm"):
+		e.debtor = text
+	case pathEndsWith(path, "RltdPties", "Cdtr", "Nm"),
+		pathEndsWith(path, "RltdPties", "Cdtr", "Pty", "Nm"):
+		e.creditor = text
+	case pathEndsWith(path, "Refs", "EndToEndId"):
+		if text != "NOTPROVIDED" {
+			e.endToEnd = text
+		}
+	case pathEndsWith(path, "CdtrRefInf", "Ref"):
+		e.cdtrRef = text
+	case pathEndsWith(path, "BkTxCd", "Domn", "Cd"),
+		pathEndsWith(path, "BkTxCd", "Domn", "Fmly", "Cd"),
+		pathEndsWith(path, "BkTxCd", "Domn", "Fmly", "SubFmlyCd"):
+		// The code of the entry comes before the ones of its details.
+		if len(e.txCode) < 3 {
+			e.txCode = append(e.txCode, text)
+		}
+	case pathEndsWith(path, "BkTxCd", "Prtry", "Cd"):
+		if e.prtryCode == "" {
+			e.prtryCode = text
+		}
+	}
+}
+
+// bankEntry returns the values of the entry. The counterparty is the debtor of
+// a credit and the creditor of a debit, and the type is the ISO bank
+// transaction code, like PMNT-RCDT-ESCT, or the proprietary one.
+func (e *camtEntry) bankEntry(id string) bankEntry {
+	counterparty := e.debtor
+	if e.debit {
+		counterparty = e.creditor
+	}
+	reference := e.endToEnd
+	if reference == "" {
+		reference = e.cdtrRef
+	}
+	kind := strings.Join(e.txCode, "-")
+	if kind == "" {
+		kind = e.prtryCode
+	}
+
+	return bankEntry{
+		id:           id,
+		booking:      e.booking,
+		value:        e.value,
+		amount:       e.amount,
+		debit:        e.debit,
+		currency:     e.currency,
+		remittance:   strings.Join(e.remittance, " "),
+		counterparty: counterparty,
+		reference:    reference,
+		kind:         kind,
+	}
+}
+
+// set keeps text when it is one of the values of the balance.
+func (b *camtBalance) set(path []string, text string) {
+	switch {
+	case pathEndsWith(path, "Bal", "Tp", "CdOrPrtry", "Cd"):
+		b.code = text
+	case pathEndsWith(path, "Bal", "Amt"):
+		b.amount = text
+	case pathEndsWith(path, "Bal", "CdtDbtInd"):
+		b.debit = text == "DBIT"
+	case pathEndsWith(path, "Bal", "Dt", "Dt"),
+		pathEndsWith(path, "Bal", "Dt", "DtTm"):
+		b.date = camtDate(text)
+	}
+}
+
+// addEntry stores an entry as a record. Entries without a reference are
+// given one made of the statement id and their position in the statement.
+func (s *CAMT053StatementReader) addEntry(e *camtEntry, raw []byte, account string, stmtID string, n int) {
+	id := e.ref
+	if id == "" {
+		id = e.svcrRef
+	}
+	if id == "" {
+		id = stmtID + "/" + strconv.Itoa(n)
+	}
+
+	s.records = append(s.records, StatementRecord{
+		Line:    e.line,
+		Raw:     strings.TrimSpace(string(raw)),
+		Fields:  e.bankEntry(id).fields(),
+		Account: account,
+	})
+}
+
+// setBalance keeps the opening (OPBD) and closing (CLBD) booked balances of
+// the account. Values that can not be read are left out, as the balances are
+// only used to reconcile.
+func (s *CAMT053StatementReader) setBalance(account string, b *camtBalance) {
+	amount, err := domain.ParseAmount(b.amount)
+	if err != nil {
+		return
+	}
+	if b.debit {
+		amount = -amount
+	}
+
+	balances := s.balances[account]
+	balances.Currency = b.currency
+	switch b.code {
+	case "OPBD":
+		balances.Opening = &amount
+	case "CLBD":
+		balances.Closing = &amount
+		if date, err := parseBankDate(b.date); err == nil {
+			balances.ClosingDate = date
+		}
+	default:
+		return
+	}
+	s.balances[account] = balances
+}
+
+// Read returns the next Ntry of the statement.
+func (s *CAMT053StatementReader) Read() (StatementRecord, error) {
+	if s.next >= len(s.records) {
+		return StatementRecord{}, io.EOF
+	}
+	rec := s.records[s.next]
+	s.next++
+	return rec, nil
+}
+
+// Parse reads an Ntry record into txn. The booking date is used as the
+// transaction date, the unstructured remittance information as the
+// description and the end to end id, or the creditor reference, as the
+// reference.
+func (s *CAMT053StatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	return parseBankEntry(rec, txn)
+}
+
+// Balances returns the opening and closing booked balances of every account
+// in the statement.
+func (s *CAMT053StatementReader) Balances() map[string]StatementBalances {
+	return s.balances
+}
+
+// camtDate keeps the date part of an ISODate or ISODateTime.
+func camtDate(s string) string {
+	if len(s) > 10 {
+		return s[:10]
+	}
+	return s
+}
+
+// parentIs tells whether the element at the end of path is a child of name.
+func parentIs(path []string, name string) bool {
+	return len(path) >= 2 && path[len(path)-2] == name
+}
+
+// pathEndsWith tells whether path ends with the given element names.
+func pathEndsWith(path []string, names ...string) bool {
+	if len(path) < len(names) {
+		return false
+	}
+	tail := path[len(path)-len(names):]
+	for i, name := range names {
+		if tail[i] != name {
+			return false
+		}
+	}
+	return true
+}
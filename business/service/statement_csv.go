@@ -0,0 +1,339 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// Sign conventions supported by CSVFormat.
+const (
+	// SignSigned means positive amounts add to the balance.
+	SignSigned = "signed"
+	// SignInverted means positive amounts take from the balance, as in
+	// exports that list spending as positive numbers.
+	SignInverted = "inverted"
+)
+
+// ErrMissingColumn is returned when the header of a CSV file lacks a column
+// required by the format.
+var ErrMissingColumn = errors.New("missing column")
+
+type (
+	// CSVFormat describes how to read a bank CSV export.
+	CSVFormat struct {
+		Delimiter    rune
+		IDColumn     string
+		DateColumn   string
+		AmountColumn string
+		// AccountColumn optionally names the column holding the account
+		// number of each row.
+		AccountColumn string
+		// DescriptionColumn, CounterpartyColumn, ReferenceColumn and
+		// TypeColumn optionally name the columns holding the details of each
+		// row.
+		DescriptionColumn  string
+		CounterpartyColumn string
+		ReferenceColumn    string
+		TypeColumn         string
+		// CurrencyColumn optionally names the column holding the currency
+		// code of each row. Rows without one are in the currency of the
+		// account.
+		CurrencyColumn   string
+		DateLayout       string
+		DecimalSeparator rune
+		SignConvention   string
+	}
+
+	// CSVStatementReader reads transactions from a CSV file, mapping columns
+	// by the names in its header line.
+	CSVStatementReader struct {
+		format  CSVFormat
+		reader  *csv.Reader
+		lines   *lineRecorder
+		idCol   int
+		dateCol int
+		amtCol  int
+		accCol  int
+		descCol int
+		ctpyCol int
+		refCol  int
+		typeCol int
+		ccyCol  int
+	}
+)
+
+// DefaultCSVFormat returns the format of the original Id,Date,Transaction
+// files.
+func DefaultCSVFormat() CSVFormat {
+	return CSVFormat{
+		Delimiter:        ',',
+		IDColumn:         "Id",
+		DateColumn:       "Date",
+		AmountColumn:     "Transaction",
+		DecimalSeparator: '.',
+		SignConvention:   SignSigned,
+	}
+}
+
+// NewCSVStatementReader reads the header line of r and maps the columns of
+// format to their positions.
+func NewCSVStatementReader(r io.Reader, format CSVFormat) (*CSVStatementReader, error) {
+	if format.SignConvention != SignSigned && format.SignConvention != SignInverted {
+		return nil, fmt.Errorf("unknown sign convention %q", format.SignConvention)
+	}
+
+	lines := &lineRecorder{r: r, first: 1}
+	reader := csv.NewReader(lines)
+	reader.Comma = format.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	s := &CSVStatementReader{
+		format:  format,
+		reader:  reader,
+		lines:   lines,
+		accCol:  -1,
+		descCol: -1,
+		ctpyCol: -1,
+		refCol:  -1,
+		typeCol: -1,
+		ccyCol:  -1,
+	}
+	for _, c := range []struct {
+		name string
+		idx  *int
+	}{
+		{format.IDColumn, &s.idCol},
+		{format.DateColumn, &s.dateCol},
+		{format.AmountColumn, &s.amtCol},
+	} {
+		idx, ok := columns[strings.ToLower(c.name)]
+		if !ok {
+			return nil, fmt.Errorf("%w %q in header %v", ErrMissingColumn, c.name, header)
+		}
+		*c.idx = idx
+	}
+
+	for _, c := range []struct {
+		name string
+		idx  *int
+	}{
+		{format.AccountColumn, &s.accCol},
+		{format.DescriptionColumn, &s.descCol},
+		{format.CounterpartyColumn, &s.ctpyCol},
+		{format.ReferenceColumn, &s.refCol},
+		{format.TypeColumn, &s.typeCol},
+		{format.CurrencyColumn, &s.ccyCol},
+	} {
+		if c.name == "" {
+			continue
+		}
+		idx, ok := columns[strings.ToLower(c.name)]
+		if !ok {
+			return nil, fmt.Errorf("%w %q in header %v", ErrMissingColumn, c.name, header)
+		}
+		*c.idx = idx
+	}
+
+	return s, nil
+}
+
+// Read returns the next record of the file, with its text as it is in the
+// file. Lines that are not valid CSV come back as a StatementRowError with the
+// text of the line.
+func (s *CSVStatementReader) Read() (StatementRecord, error) {
+	fields, err := s.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			raw := s.lines.text(parseErr.StartLine, parseErr.Line)
+			s.lines.forget(parseErr.Line + 1)
+			return StatementRecord{}, &StatementRowError{Line: parseErr.StartLine, Raw: raw, Err: parseErr.Err}
+		}
+		//nolint:wrapcheck
+		return StatementRecord{}, err
+	}
+
+	// A quoted field can span lines, so the record ends on the line of its
+	// last field plus the line breaks inside it.
+	line, _ := s.reader.FieldPos(0)
+	last, _ := s.reader.FieldPos(len(fields) - 1)
+	last += strings.Count(fields[len(fields)-1], "\n")
+	raw := s.lines.text(line, last)
+	s.lines.forget(last + 1)
+
+	rec := StatementRecord{
+		Line:   line,
+		Raw:    raw,
+		Fields: fields,
+	}
+	rec.Account = optionalField(fields, s.accCol)
+
+	return rec, nil
+}
+
+// Parse reads the mapped columns of rec into txn.
+func (s *CSVStatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	return parseTransaction(rec, s, txn)
+}
+
+func parseTransaction(rec StatementRecord, s *CSVStatementReader, txn *domain.Transaction) error {
+	maxCol := max(s.idCol, s.dateCol, s.amtCol)
+	if len(rec.Fields) <= maxCol {
+		return fmt.Errorf("line format error: expected at least %d fields, received %d", maxCol+1, len(rec.Fields))
+	}
+
+	txn.FileTransactionID = strings.TrimSpace(rec.Fields[s.idCol])
+	if txn.FileTransactionID == "" {
+		return errors.New("error reading transaction id: empty value")
+	}
+
+	var err error
+	txn.Date, err = parseTransactionDate(rec.Fields[s.dateCol], s.format.DateLayout, txn.ProcessingTimestamp)
+	if err != nil {
+		return fmt.Errorf("error reading date: %w", err)
+	}
+
+	txn.Amount, err = parseCSVAmount(rec.Fields[s.amtCol], s.format)
+	if err != nil {
+		return fmt.Errorf("error reading amount: %w", err)
+	}
+
+	txn.Description = optionalField(rec.Fields, s.descCol)
+	txn.Counterparty = optionalField(rec.Fields, s.ctpyCol)
+	txn.Reference = optionalField(rec.Fields, s.refCol)
+	txn.Type = optionalField(rec.Fields, s.typeCol)
+	txn.Currency = strings.ToUpper(optionalField(rec.Fields, s.ccyCol))
+
+	return nil
+}
+
+// optionalField returns the trimmed value of an optional column, or an empty
+// string when the column is not mapped or the row is short.
+func optionalField(fields []string, col int) string {
+	if col < 0 || col >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[col])
+}
+
+// parseCSVAmount reads an amount written with the decimal separator of the
+// format. With a decimal comma, dots and spaces are taken as digit grouping,
+// so "-1.234,56" reads as -1234.56.
+func parseCSVAmount(s string, format CSVFormat) (domain.Amount, error) {
+	s = strings.TrimSpace(s)
+	if format.DecimalSeparator == ',' {
+		s = strings.NewReplacer(".", "", " ", "", "\u00a0", "", ",", ".").Replace(s)
+	}
+
+	amount, err := domain.ParseAmount(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if format.SignConvention == SignInverted {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// parseTransactionDate reads a date with layout, or with M/D/YYYY, YYYY-MM-DD
+// or M/D when no layout is given. When the year is missing it is taken from
+// the processing timestamp, falling back to the previous year for dates that
+// would otherwise be in the future, so a January run still places a 12/28
+// transaction in December of last year.
+func parseTransactionDate(s string, layout string, processing time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	layouts := []string{"1/2/2006", "2006-01-02", "1/2"}
+	if layout != "" {
+		layouts = []string{layout}
+	}
+
+	var d time.Time
+	var err error
+	for _, l := range layouts {
+		if d, err = time.Parse(l, s); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+	if d.Year() != 0 {
+		return d, nil
+	}
+
+	year := processing.Year()
+	today := time.Date(year, processing.Month(), processing.Day(), 0, 0, 0, 0, time.UTC)
+	date := time.Date(year, d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	if date.After(today) {
+		date = time.Date(year-1, d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if date.Day() != d.Day() {
+		return time.Time{}, fmt.Errorf("date %q does not exist in %d", s, date.Year())
+	}
+
+	return date, nil
+}
+
+// lineRecorder keeps the text read through it from the line of the record
+// being read on, so records the csv package can not parse are reported as
+// they are in the file.
+type lineRecorder struct {
+	r io.Reader
+	// first is the number of the first line kept in buf.
+	first int
+	buf   []byte
+}
+
+func (l *lineRecorder) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.buf = append(l.buf, p[:n]...)
+	//nolint:wrapcheck
+	return n, err
+}
+
+// text returns the lines from one line through another, without their line
+// endings.
+func (l *lineRecorder) text(from int, to int) string {
+	var lines []string
+	rest := l.buf
+	for n := l.first; n <= to && len(rest) > 0; n++ {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte("\n"))
+		if n >= from {
+			lines = append(lines, strings.TrimSuffix(string(line), "\r"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// forget drops the lines before line, which were already read.
+func (l *lineRecorder) forget(line int) {
+	for l.first < line {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return
+		}
+		l.buf = l.buf[i+1:]
+		l.first++
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// StatementEntry is one entry of a statement that is not read from a file,
+// but sent already split into its values. Dates are written as YYYY-MM-DD
+// and the amount is signed, with a dot as decimal separator.
+type StatementEntry struct {
+	ID           string
+	BookingDate  string
+	ValueDate    string
+	Amount       string
+	Currency     string
+	Description  string
+	Counterparty string
+	Reference    string
+	Type         string
+}
+
+// EntryStatementReader reads a statement whose entries come one at a time
+// from a function, like the rows streamed by a client. The n-th entry is
+// reported as line n.
+type EntryStatementReader struct {
+	next func() (StatementEntry, error)
+	line int
+}
+
+// NewEntryStatementReader returns a reader of the entries returned by next,
+// which returns io.EOF once there are no more.
+func NewEntryStatementReader(next func() (StatementEntry, error)) *EntryStatementReader {
+	return &EntryStatementReader{next: next}
+}
+
+// Read returns the next entry. Its raw form is the CSV line of its values, so
+// sending the same entries again is recognized as the same statement.
+func (s *EntryStatementReader) Read() (StatementRecord, error) {
+	e, err := s.next()
+	if err != nil {
+		return StatementRecord{}, err
+	}
+	s.line++
+
+	fields := bankEntry{
+		id:           e.ID,
+		booking:      e.BookingDate,
+		value:        e.ValueDate,
+		amount:       e.Amount,
+		currency:     e.Currency,
+		remittance:   e.Description,
+		counterparty: e.Counterparty,
+		reference:    e.Reference,
+		kind:         e.Type,
+	}.fields()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(fields)
+	w.Flush()
+
+	return StatementRecord{
+		Line:   s.line,
+		Raw:    strings.TrimRight(buf.String(), "\n"),
+		Fields: fields,
+	}, nil
+}
+
+// Parse reads an entry into txn.
+func (s *EntryStatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	return parseBankEntry(rec, txn)
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// ErrInvalidMT940 is returned when a file can not be read as a SWIFT MT940
+// statement.
+var ErrInvalidMT940 = errors.New("invalid mt940")
+
+// mt940Entry matches the first line of a :61: statement line: value date,
+// optional booking date, credit or debit mark, optional funds code, amount,
+// transaction type, customer reference and optional bank reference.
+var mt940Entry = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})(.*?)(?://(.*))?$`)
+
+// mt940Balance matches an opening or closing balance: credit or debit mark,
+// date, currency and amount.
+var mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+// MT940StatementReader reads the statement lines of a SWIFT MT940 statement.
+// Every :61: line, with the :86: line after it, is one transaction.
+type MT940StatementReader struct {
+	records  []StatementRecord
+	next     int
+	balances map[string]StatementBalances
+}
+
+// mt940Field is one tag of the statement with its value, continuation lines
+// included.
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// mt940Statement holds the values of the statement being read.
+type mt940Statement struct {
+	reference string
+	account   string
+	currency  string
+	entries   int
+}
+
+// NewMT940StatementReader reads the whole statement in r. The closing balance
+// comes after the statement lines, so the file is parsed up front.
+func NewMT940StatementReader(r io.Reader) (*MT940StatementReader, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no fields", ErrInvalidMT940)
+	}
+
+	s := &MT940StatementReader{
+		balances: make(map[string]StatementBalances),
+	}
+	if err := s.parse(fields); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// readMT940Fields splits the text blocks of the file into fields. The
+// {1:}..{4: block headers and the -} trailers of a SWIFT envelope are
+// dropped.
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if i := strings.Index(text, "{4:"); i >= 0 {
+			text = text[i+len("{4:"):]
+		}
+		switch trimmed := strings.TrimSpace(text); {
+		case trimmed == "", trimmed == "-", strings.HasPrefix(trimmed, "-}"), strings.HasPrefix(trimmed, "{"):
+			continue
+		}
+
+		if tag, value, ok := cutMT940Tag(text); ok {
+			fields = append(fields, mt940Field{tag: tag, value: value, line: line})
+			continue
+		}
+		if len(fields) == 0 {
+			// Anything before the first tag is envelope.
+			continue
+		}
+		fields[len(fields)-1].value += "\n" + text
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return fields, nil
+}
+
+// cutMT940Tag splits a line like ":61:value" into its tag and value.
+func cutMT940Tag(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, ":") {
+		return "", "", false
+	}
+	end := strings.IndexByte(text[1:], ':')
+	if end < 2 || end > 3 {
+		return "", "", false
+	}
+	tag := text[1 : end+1]
+	if tag[0] < '0' || tag[0] > '9' || tag[1] < '0' || tag[1] > '9' {
+		return "", "", false
+	}
+	return tag, text[end+2:], true
+}
+
+// parse turns the fields into records and balances.
+func (s *MT940StatementReader) parse(fields []mt940Field) error {
+	var stmt mt940Statement
+
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch f.tag {
+		case "20":
+			stmt = mt940Statement{reference: strings.TrimSpace(f.value)}
+		case "25":
+			stmt.account = strings.TrimSpace(f.value)
+		case "60F", "60M":
+			amount, _, currency, err := parseMT940Balance(f.value)
+			if err != nil {
+				return fmt.Errorf("%w: line %d: opening balance: %w", ErrInvalidMT940, f.line, err)
+			}
+			stmt.currency = currency
+			// A file may hold consecutive statements of the account, which
+			// open where the one before closed.
+			b := s.balances[stmt.account]
+			b.Currency = currency
+			if b.Opening == nil {
+				b.Opening = &amount
+			}
+			s.balances[stmt.account] = b
+		case "62F", "62M":
+			amount, date, _, err := parseMT940Balance(f.value)
+			if err != nil {
+				return fmt.Errorf("%w: line %d: closing balance: %w", ErrInvalidMT940, f.line, err)
+			}
+			b := s.balances[stmt.account]
+			b.Closing = &amount
+			b.ClosingDate = date
+			s.balances[stmt.account] = b
+		case "61":
+			var info *mt940Field
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				info = &fields[i+1]
+				i++
+			}
+			stmt.entries++
+			s.records = append(s.records, stmt.record(f, info))
+		}
+	}
+
+	return nil
+}
+
+// record builds the record of a statement line and its information to
+// account owner. Lines that can not be read keep their fields empty so Parse
+// reports them, and the rest of the statement can still be imported.
+func (stmt *mt940Statement) record(line mt940Field, info *mt940Field) StatementRecord {
+	raw := ":61:" + line.value
+	var details mt940Details
+	if info != nil {
+		raw += "\n:86:" + info.value
+		details = parseMT940Information(info.value)
+	}
+
+	rec := StatementRecord{
+		Line:    line.line,
+		Raw:     raw,
+		Account: stmt.account,
+	}
+
+	first, _, _ := strings.Cut(line.value, "\n")
+	m := mt940Entry.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return rec
+	}
+
+	value, err := parseMT940Date(m[1])
+	if err != nil {
+		return rec
+	}
+	booking := value
+	if m[2] != "" {
+		booking, err = mt940BookingDate(m[2], value)
+		if err != nil {
+			return rec
+		}
+	}
+
+	reference := strings.TrimSpace(m[7])
+	if strings.EqualFold(reference, "NONREF") {
+		reference = ""
+	}
+	id := strings.TrimSpace(m[8])
+	if id == "" {
+		id = reference
+	}
+	if id == "" {
+		id = stmt.reference + "/" + strconv.Itoa(stmt.entries)
+	}
+
+	rec.Fields = bankEntry{
+		id:           id,
+		booking:      booking.Format(time.DateOnly),
+		value:        value.Format(time.DateOnly),
+		amount:       m[5],
+		debit:        m[3] == "D" || m[3] == "RC",
+		currency:     stmt.currency,
+		remittance:   details.remittance,
+		counterparty: details.counterparty,
+		reference:    reference,
+		kind:         m[6],
+	}.fields()
+
+	return rec
+}
+
+// mt940Details are the values read from the :86: information to account
+// owner.
+type mt940Details struct {
+	remittance   string
+	counterparty string
+}
+
+// mt940Subfield matches the ?NN subfield markers of structured :86: lines.
+var mt940Subfield = regexp.MustCompile(`\?(\d{2})`)
+
+// parseMT940Information reads a :86: value. Structured values, like
+// 166?00SEPA-GUTSCHRIFT?20Invoice 4711?32ACME GMBH, are split into their
+// subfields: ?20 to ?29 and ?60 to ?63 hold the remittance information and
+// ?32 and ?33 the name of the counterparty. Anything else is taken as
+// remittance information.
+func parseMT940Information(s string) mt940Details {
+	if len(s) < 4 || !isDigits(s[:3]) || s[3] != '?' {
+		return mt940Details{remittance: strings.Join(strings.Fields(s), " ")}
+	}
+
+	// Subfields are wrapped at a fixed width, so continuation lines are
+	// joined without a separator.
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r", ""), "\n", "")
+
+	var remittance, counterparty strings.Builder
+	marks := mt940Subfield.FindAllStringSubmatchIndex(s, -1)
+	for i, mark := range marks {
+		end := len(s)
+		if i+1 < len(marks) {
+			end = marks[i+1][0]
+		}
+		code, _ := strconv.Atoi(s[mark[2]:mark[3]])
+		value := s[mark[1]:end]
+		switch {
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			remittance.WriteString(value)
+		case code == 32, code == 33:
+			counterparty.WriteString(value)
+		}
+	}
+
+	return mt940Details{
+		remittance:   strings.TrimSpace(remittance.String()),
+		counterparty: strings.TrimSpace(counterparty.String()),
+	}
+}
+
+// isDigits tells whether s is made only of ASCII digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Read returns the next statement line.
+func (s *MT940StatementReader) Read() (StatementRecord, error) {
+	if s.next >= len(s.records) {
+		return StatementRecord{}, io.EOF
+	}
+	rec := s.records[s.next]
+	s.next++
+	return rec, nil
+}
+
+// Parse reads a statement line into txn. The entry date is used as the
+// transaction date, falling back to the value date, the :86: information as
+// the description, the customer reference as the reference and the
+// identification code, like NTRF, as the type.
+func (s *MT940StatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	if rec.Fields == nil {
+		first, _, _ := strings.Cut(rec.Raw, "\n")
+		return fmt.Errorf("%w: unsupported statement line %q", ErrInvalidMT940, first)
+	}
+	return parseBankEntry(rec, txn)
+}
+
+// Balances returns the opening and closing balances of every account in the
+// statement.
+func (s *MT940StatementReader) Balances() map[string]StatementBalances {
+	return s.balances
+}
+
+// parseMT940Balance reads a balance like C230131EUR1234,56.
+func parseMT940Balance(s string) (domain.Amount, time.Time, string, error) {
+	m := mt940Balance.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, time.Time{}, "", fmt.Errorf("unsupported balance %q", s)
+	}
+
+	date, err := parseMT940Date(m[2])
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+
+	amount, err := domain.ParseAmount(strings.Replace(m[4], ",", ".", 1))
+	if err != nil {
+		return 0, time.Time{}, "", fmt.Errorf("unsupported balance %q: %w", s, err)
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+
+	return amount, date, m[3], nil
+}
+
+// parseMT940Date reads a YYMMDD date.
+func parseMT940Date(s string) (time.Time, error) {
+	d, err := time.Parse("060102", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+	return d, nil
+}
+
+// mt940BookingDate reads the MMDD entry date of a statement line. It has no
+// year, so it takes the one of the value date, moved by a year when that
+// places it more than six months away, as in a December value date booked in
+// January.
+func mt940BookingDate(s string, value time.Time) (time.Time, error) {
+	d, err := time.Parse("0102", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+
+	booking := time.Date(value.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case booking.Sub(value) > 183*24*time.Hour:
+		booking = booking.AddDate(-1, 0, 0)
+	case value.Sub(booking) > 183*24*time.Hour:
+		booking = booking.AddDate(1, 0, 0)
+	}
+
+	return booking, nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+)
+
+// Positions of the STMTTRN values in the fields of an OFX record.
+const (
+	ofxFieldFITID = iota
+	ofxFieldDatePosted
+	ofxFieldAmount
+	ofxFieldName
+	ofxFieldMemo
+	ofxFieldType
+	ofxFieldRefNum
+	ofxFieldCheckNum
+	ofxFieldCurrency
+	ofxFieldCount
+)
+
+// ofxFields maps the STMTTRN elements kept by the reader to their position.
+var ofxFields = map[string]int{
+	"FITID":    ofxFieldFITID,
+	"DTPOSTED": ofxFieldDatePosted,
+	"TRNAMT":   ofxFieldAmount,
+	"NAME":     ofxFieldName,
+	"MEMO":     ofxFieldMemo,
+	"TRNTYPE":  ofxFieldType,
+	"REFNUM":   ofxFieldRefNum,
+	"CHECKNUM": ofxFieldCheckNum,
+}
+
+// ofxCurrencyFields are the aggregates holding the currency of a STMTTRN that
+// is not in the default currency of the statement. ORIGCURRENCY is left out:
+// the amount of such a STMTTRN is already converted to the default currency,
+// and the original currency is kept in the raw record only.
+var ofxCurrencyFields = map[string]bool{
+	"CURRENCY": true,
+}
+
+// ErrInvalidOFX is returned when a file can not be read as an OFX statement.
+var ErrInvalidOFX = errors.New("invalid ofx")
+
+// OFXStatementReader reads the transactions of an OFX or QFX statement, both
+// the SGML flavour of version 1, where values have no closing tags, and the
+// XML flavour of version 2.
+type OFXStatementReader struct {
+	records  []StatementRecord
+	next     int
+	balances map[string]StatementBalances
+}
+
+// NewOFXStatementReader reads the whole statement in r. Statements are small,
+// and the ledger balance comes after the transactions, so the file is parsed
+// up front.
+func NewOFXStatementReader(r io.Reader) (*OFXStatementReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	content := string(data)
+
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no <OFX> element", ErrInvalidOFX)
+	}
+
+	s := &OFXStatementReader{
+		balances: make(map[string]StatementBalances),
+	}
+	s.parse(content, start)
+
+	return s, nil
+}
+
+// parse walks the elements of the statement from pos. An element followed by
+// text is a value; anything else opens or closes an aggregate.
+func (s *OFXStatementReader) parse(content string, pos int) {
+	var (
+		account  string
+		currency string
+		inTrn    bool
+		inLedger bool
+		inCurr   bool
+		trn      StatementRecord
+		raw      strings.Builder
+	)
+
+	for pos < len(content) {
+		open := strings.IndexByte(content[pos:], '<')
+		if open < 0 {
+			break
+		}
+		open += pos
+		end := strings.IndexByte(content[open:], '>')
+		if end < 0 {
+			break
+		}
+		end += open
+
+		tag := strings.ToUpper(strings.TrimSpace(content[open+1 : end]))
+		pos = end + 1
+
+		next := strings.IndexByte(content[pos:], '<')
+		if next < 0 {
+			next = len(content) - pos
+		}
+		text := strings.TrimSpace(html.UnescapeString(content[pos : pos+next]))
+
+		switch {
+		case strings.HasPrefix(tag, "/"):
+			switch tag[1:] {
+			case "STMTTRN":
+				if inTrn {
+					raw.WriteString("</STMTTRN>")
+					trn.Raw = raw.String()
+					trn.Account = account
+					if trn.Fields[ofxFieldCurrency] == "" {
+						trn.Fields[ofxFieldCurrency] = currency
+					}
+					s.records = append(s.records, trn)
+					inTrn = false
+				}
+			case "LEDGERBAL":
+				inLedger = false
+			case "CURRENCY":
+				inCurr = false
+			}
+
+		case tag == "STMTTRN":
+			inTrn = true
+			trn = StatementRecord{
+				Line:   strings.Count(content[:open], "\n") + 1,
+				Fields: make([]string, ofxFieldCount),
+			}
+			raw.Reset()
+			raw.WriteString("<STMTTRN>")
+
+		case tag == "LEDGERBAL":
+			inLedger = true
+
+		case ofxCurrencyFields[tag] && text == "":
+			inCurr = true
+
+		case text != "":
+			switch {
+			case inTrn:
+				if i, ok := ofxFields[tag]; ok {
+					trn.Fields[i] = text
+				}
+				if inCurr && tag == "CURSYM" {
+					trn.Fields[ofxFieldCurrency] = text
+				}
+				raw.WriteString("<" + tag + ">" + text)
+			case inLedger:
+				s.setLedgerBalance(account, currency, tag, text)
+			case tag == "ACCTID":
+				account = text
+			case tag == "CURDEF":
+				currency = text
+			}
+		}
+	}
+}
+
+// setLedgerBalance keeps the LEDGERBAL values of the account. Values that can
+// not be read are left out, as the balance is only used to reconcile.
+func (s *OFXStatementReader) setLedgerBalance(account string, currency string, tag string, text string) {
+	b := s.balances[account]
+	b.Currency = currency
+	switch tag {
+	case "BALAMT":
+		if amount, err := parseOFXAmount(text); err == nil {
+			b.Closing = &amount
+		}
+	case "DTASOF":
+		if date, err := parseOFXDate(text); err == nil {
+			b.ClosingDate = date
+		}
+	}
+	s.balances[account] = b
+}
+
+// Read returns the next STMTTRN of the statement.
+func (s *OFXStatementReader) Read() (StatementRecord, error) {
+	if s.next >= len(s.records) {
+		return StatementRecord{}, io.EOF
+	}
+	rec := s.records[s.next]
+	s.next++
+	return rec, nil
+}
+
+// Parse reads a STMTTRN record into txn. The FITID is used as the file
+// transaction ID, NAME as the counterparty, MEMO as the description, TRNTYPE
+// as the type and REFNUM, or the CHECKNUM of checks, as the reference. The
+// currency is the one of the STMTTRN, or the CURDEF of the statement.
+func (s *OFXStatementReader) Parse(rec StatementRecord, txn *domain.Transaction) error {
+	if len(rec.Fields) != ofxFieldCount {
+		return fmt.Errorf("%w: unexpected record", ErrInvalidOFX)
+	}
+
+	txn.FileTransactionID = rec.Fields[ofxFieldFITID]
+	if txn.FileTransactionID == "" {
+		return errors.New("error reading transaction id: missing FITID")
+	}
+
+	var err error
+	txn.Date, err = parseOFXDate(rec.Fields[ofxFieldDatePosted])
+	if err != nil {
+		return fmt.Errorf("error reading date: %w", err)
+	}
+
+	txn.Amount, err = parseOFXAmount(rec.Fields[ofxFieldAmount])
+	if err != nil {
+		return fmt.Errorf("error reading amount: %w", err)
+	}
+
+	txn.Counterparty = rec.Fields[ofxFieldName]
+	txn.Description = rec.Fields[ofxFieldMemo]
+	txn.Type = rec.Fields[ofxFieldType]
+	txn.Currency = rec.Fields[ofxFieldCurrency]
+	txn.Reference = rec.Fields[ofxFieldRefNum]
+	if txn.Reference == "" {
+		txn.Reference = rec.Fields[ofxFieldCheckNum]
+	}
+
+	return nil
+}
+
+// Balances returns the LEDGERBAL of every account in the statement.
+func (s *OFXStatementReader) Balances() map[string]StatementBalances {
+	return s.balances
+}
+
+// parseOFXDate reads the date part of an OFX datetime, which looks like
+// YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]].
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+	d, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported date %q", s)
+	}
+	return d, nil
+}
+
+// parseOFXAmount reads an OFX amount. Some banks write them with a decimal
+// comma.
+func parseOFXAmount(s string) (domain.Amount, error) {
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	//nolint:wrapcheck
+	return domain.ParseAmount(s)
+}
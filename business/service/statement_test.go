@@ -0,0 +1,472 @@
+package service_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/business/service"
+	"github.com/stretchr/testify/assert"
+)
+
+const ofxSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20240801120000</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000248
+<ACCTID>4455667788
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240701
+<DTEND>20240731
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240715120000.000[-5:EST]
+<TRNAMT>60.50
+<FITID>A-0001
+<NAME>ACME PAYROLL
+<MEMO>July salary
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240728
+<TRNAMT>-10.30
+<FITID>A-0002
+<CHECKNUM>1042
+<NAME>Joe&apos;s Coffee
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>1050.20
+<DTASOF>20240731
+</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const ofxXML = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <BANKMSGSRSV1>
+    <STMTTRNRS>
+      <STMTRS>
+        <CURDEF>USD</CURDEF>
+        <BANKACCTFROM>
+          <BANKID>121000248</BANKID>
+          <ACCTID>4455667788</ACCTID>
+          <ACCTTYPE>CHECKING</ACCTTYPE>
+        </BANKACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>CREDIT</TRNTYPE>
+            <DTPOSTED>20240715120000.000[-5:EST]</DTPOSTED>
+            <TRNAMT>60.50</TRNAMT>
+            <FITID>A-0001</FITID>
+            <NAME>ACME PAYROLL</NAME>
+            <MEMO>July salary</MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240728</DTPOSTED>
+            <TRNAMT>-10.30</TRNAMT>
+            <FITID>A-0002</FITID>
+            <CHECKNUM>1042</CHECKNUM>
+            <NAME>Joe&apos;s Coffee</NAME>
+          </STMTTRN>
+        </BANKTRANLIST>
+        <LEDGERBAL>
+          <BALAMT>1050.20</BALAMT>
+          <DTASOF>20240731</DTASOF>
+        </LEDGERBAL>
+      </STMTRS>
+    </STMTTRNRS>
+  </BANKMSGSRSV1>
+</OFX>
+`
+
+// readAll reads and parses every record of a statement.
+func readAll(t *testing.T, reader service.StatementReader) ([]service.StatementRecord, []domain.Transaction) {
+	t.Helper()
+
+	var recs []service.StatementRecord
+	var txns []domain.Transaction
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		var txn domain.Transaction
+		if !assert.NoError(t, reader.Parse(rec, &txn)) {
+			t.FailNow()
+		}
+		recs = append(recs, rec)
+		txns = append(txns, txn)
+	}
+	return recs, txns
+}
+
+func Test_OFXStatementReader_reads_sgml_and_xml(t *testing.T) {
+	t.Parallel()
+
+	var raws [][]string
+	for name, data := range map[string]string{"sgml": ofxSGML, "xml": ofxXML} {
+		reader, err := service.NewStatementReader(strings.NewReader(data), service.FormatAuto, service.DefaultCSVFormat())
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.IsType(t, &service.OFXStatementReader{}, reader, name)
+
+		recs, txns := readAll(t, reader)
+		assert.Equal(t, []domain.Transaction{
+			{
+				FileTransactionID: "A-0001",
+				Date:              time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+				Amount:            domain.MustParseAmount("60.5"),
+				Currency:          "USD",
+				Description:       "July salary",
+				Counterparty:      "ACME PAYROLL",
+				Type:              "CREDIT",
+			},
+			{
+				FileTransactionID: "A-0002",
+				Date:              time.Date(2024, 7, 28, 0, 0, 0, 0, time.UTC),
+				Amount:            domain.MustParseAmount("-10.3"),
+				Currency:          "USD",
+				Counterparty:      "Joe's Coffee",
+				Reference:         "1042",
+				Type:              "DEBIT",
+			},
+		}, txns, name)
+		assert.Equal(t, "4455667788", recs[0].Account, name)
+
+		balances := reader.(service.BalanceReporter).Balances()
+		assert.Equal(t, domain.MustParseAmount("1050.20"), *balances["4455667788"].Closing, name)
+		assert.Equal(t, time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC), balances["4455667788"].ClosingDate, name)
+		assert.Equal(t, "USD", balances["4455667788"].Currency, name)
+
+		raws = append(raws, []string{recs[0].Raw, recs[1].Raw})
+	}
+
+	// Both flavours hash the same so a statement can not be imported twice by
+	// downloading it in the other version.
+	assert.Equal(t, raws[0], raws[1])
+}
+
+func Test_OFXStatementReader_keeps_the_statement_currency_of_origcurrency_rows(t *testing.T) {
+	t.Parallel()
+
+	data := strings.Replace(ofxSGML, "<MEMO>July salary\n", "<MEMO>July salary\n<CURRENCY><CURRATE>1.1<CURSYM>EUR</CURRENCY>\n", 1)
+	data = strings.Replace(data, "<NAME>Joe&apos;s Coffee\n", "<NAME>Joe&apos;s Coffee\n<ORIGCURRENCY><CURRATE>1.1<CURSYM>EUR</ORIGCURRENCY>\n", 1)
+
+	reader, err := service.NewOFXStatementReader(strings.NewReader(data))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, txns := readAll(t, reader)
+	assert.Equal(t, "EUR", txns[0].Currency)
+	assert.Equal(t, domain.MustParseAmount("60.5"), txns[0].Amount)
+
+	// The TRNAMT of an ORIGCURRENCY row is already in the statement currency,
+	// so it is imported as is.
+	assert.Equal(t, "USD", txns[1].Currency)
+	assert.Equal(t, domain.MustParseAmount("-10.3"), txns[1].Amount)
+}
+
+func Test_NewStatementReader_sniffs_csv(t *testing.T) {
+	t.Parallel()
+
+	reader, err := service.NewStatementReader(strings.NewReader("Id,Date,Transaction\n0,7/15,+60.5\n"), service.FormatAuto, service.DefaultCSVFormat())
+	assert.NoError(t, err)
+	assert.IsType(t, &service.CSVStatementReader{}, reader)
+
+	_, err = service.NewStatementReader(strings.NewReader("Id,Date,Transaction\n"), service.FormatOFX, service.DefaultCSVFormat())
+	assert.ErrorIs(t, err, service.ErrInvalidOFX)
+}
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG-20240731</MsgId><CreDtTm>2024-07-31T20:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>STMT-0731</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">10.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-07-30</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">83.25</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-07-31</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <NtryRef>E-0001</NtryRef>
+        <Amt Ccy="EUR">100.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-07-31</Dt></BookgDt>
+        <ValDt><Dt>2024-08-01</Dt></ValDt>
+        <BkTxCd><Domn><Cd>PMNT</Cd><Fmly><Cd>RCDT</Cd><SubFmlyCd>ESCT</SubFmlyCd></Fmly></Domn></BkTxCd>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>INV-4711</EndToEndId></Refs>
+          <AmtDtls><InstdAmt><Amt Ccy="USD">109.00</Amt></InstdAmt></AmtDtls>
+          <RltdPties><Dbtr><Nm>ACME Corp</Nm></Dbtr><Cdtr><Nm>Our Company</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Invoice 4711</Ustrd><Ustrd>July</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">25.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><DtTm>2024-07-31T09:30:00</DtTm></BookgDt>
+        <ValDt><Dt>2024-07-31</Dt></ValDt>
+        <AcctSvcrRef>BANK-998</AcctSvcrRef>
+        <NtryDtls><TxDtls><RmtInf><Ustrd>Card payment</Ustrd></RmtInf></TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">1.25</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2024-07-31</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+const mt940 = `{1:F01BANKDEFFAXXX0000000000}{2:O9400000000000BANKDEFFAXXX00000000000000000000N}{4:
+:20:STMT-0731
+:25:DE89370400440532013000
+:28C:00001/001
+:60F:C240730EUR10,00
+:61:2407310731C100,00NTRFINV4711//E-0001
+:86:Invoice 4711
+ July
+:61:2408010731RC25,5NMSCNONREF//BANK-998
+:86:166?00KARTENZAHLUNG?20Card pay
+?21ment?32COFFEE SHOP
+:61:240731DD1,25NCHGNONREF
+:62F:C240731EUR83,25
+-}
+`
+
+func Test_CAMT053StatementReader_reads_entries_and_balances(t *testing.T) {
+	t.Parallel()
+
+	reader, err := service.NewStatementReader(strings.NewReader(camt053), service.FormatAuto, service.DefaultCSVFormat())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.IsType(t, &service.CAMT053StatementReader{}, reader)
+
+	recs, txns := readAll(t, reader)
+	valueDate := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	sameDay := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []domain.Transaction{
+		{
+			FileTransactionID: "E-0001",
+			Date:              time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC),
+			ValueDate:         &valueDate,
+			Amount:            domain.MustParseAmount("100"),
+			Currency:          "EUR",
+			Description:       "Invoice 4711 July",
+			Counterparty:      "ACME Corp",
+			Reference:         "INV-4711",
+			Type:              "PMNT-RCDT-ESCT",
+		},
+		{
+			FileTransactionID: "BANK-998",
+			Date:              time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC),
+			ValueDate:         &sameDay,
+			Amount:            domain.MustParseAmount("-25.5"),
+			Currency:          "EUR",
+			Description:       "Card payment",
+		},
+		{
+			FileTransactionID: "STMT-0731/3",
+			Date:              time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC),
+			Amount:            domain.MustParseAmount("-1.25"),
+			Currency:          "EUR",
+		},
+	}, txns)
+	assert.Equal(t, "DE89370400440532013000", recs[0].Account)
+	assert.Equal(t, 20, recs[0].Line)
+	assert.True(t, strings.HasPrefix(recs[0].Raw, "<Ntry>"))
+	assert.True(t, strings.HasSuffix(recs[0].Raw, "</Ntry>"))
+
+	balances := reader.(service.BalanceReporter).Balances()["DE89370400440532013000"]
+	assert.Equal(t, domain.MustParseAmount("10"), *balances.Opening)
+	assert.Equal(t, domain.MustParseAmount("83.25"), *balances.Closing)
+	assert.Equal(t, sameDay, balances.ClosingDate)
+}
+
+func Test_MT940StatementReader_reads_entries_and_balances(t *testing.T) {
+	t.Parallel()
+
+	reader, err := service.NewStatementReader(strings.NewReader(mt940), service.FormatAuto, service.DefaultCSVFormat())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.IsType(t, &service.MT940StatementReader{}, reader)
+
+	recs, txns := readAll(t, reader)
+	july31 := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+	aug1 := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []domain.Transaction{
+		{
+			FileTransactionID: "E-0001",
+			Date:              july31,
+			ValueDate:         &july31,
+			Amount:            domain.MustParseAmount("100"),
+			Currency:          "EUR",
+			Description:       "Invoice 4711 July",
+			Reference:         "INV4711",
+			Type:              "NTRF",
+		},
+		{
+			// A reversed credit takes from the balance.
+			FileTransactionID: "BANK-998",
+			Date:              july31,
+			ValueDate:         &aug1,
+			Amount:            domain.MustParseAmount("-25.5"),
+			Currency:          "EUR",
+			Description:       "Card payment",
+			Counterparty:      "COFFEE SHOP",
+			Type:              "NMSC",
+		},
+		{
+			// The second D is the funds code.
+			FileTransactionID: "STMT-0731/3",
+			Date:              july31,
+			ValueDate:         &july31,
+			Amount:            domain.MustParseAmount("-1.25"),
+			Currency:          "EUR",
+			Type:              "NCHG",
+		},
+	}, txns)
+	assert.Equal(t, "DE89370400440532013000", recs[0].Account)
+	assert.Equal(t, 6, recs[0].Line)
+	assert.Equal(t, ":61:2407310731C100,00NTRFINV4711//E-0001\n:86:Invoice 4711\n July", recs[0].Raw)
+
+	balances := reader.(service.BalanceReporter).Balances()["DE89370400440532013000"]
+	assert.Equal(t, domain.MustParseAmount("10"), *balances.Opening)
+	assert.Equal(t, domain.MustParseAmount("83.25"), *balances.Closing)
+	assert.Equal(t, july31, balances.ClosingDate)
+}
+
+func Test_MT940StatementReader_rejects_unreadable_statement_lines(t *testing.T) {
+	t.Parallel()
+
+	reader, err := service.NewMT940StatementReader(strings.NewReader(":20:REF\n:25:123\n:60F:C240730EUR0,\n:61:not a statement line\n:62F:C240731EUR0,\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rec, err := reader.Read()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, reader.Parse(rec, &domain.Transaction{}), service.ErrInvalidMT940)
+}
+
+func Test_CSVStatementReader_reports_the_text_of_every_record(t *testing.T) {
+	t.Parallel()
+
+	data := "Id,Date,Transaction\r\n0,7/15,\"60\"5\r\n1,7/28,\"ten\r\nthousand\"x\r\n2,8/2,-20.46\r\n3, \"8/5\",\"-1\",\"two\r\nlines\"\r\n4,8/9,1\r\n"
+	reader, err := service.NewCSVStatementReader(strings.NewReader(data), service.DefaultCSVFormat())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var rowErr *service.StatementRowError
+	_, err = reader.Read()
+	if assert.ErrorAs(t, err, &rowErr) {
+		assert.Equal(t, 2, rowErr.Line)
+		assert.Equal(t, `0,7/15,"60"5`, rowErr.Raw)
+	}
+
+	_, err = reader.Read()
+	if assert.ErrorAs(t, err, &rowErr) {
+		assert.Equal(t, 3, rowErr.Line)
+		assert.Equal(t, "1,7/28,\"ten\nthousand\"x", rowErr.Raw)
+	}
+
+	rec, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rec.Line)
+	assert.Equal(t, "2,8/2,-20.46", rec.Raw)
+
+	rec, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, rec.Line)
+	assert.Equal(t, "3, \"8/5\",\"-1\",\"two\nlines\"", rec.Raw)
+
+	rec, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 8, rec.Line)
+	assert.Equal(t, "4,8/9,1", rec.Raw)
+}
+
+func Test_EntryStatementReader_reads_entries(t *testing.T) {
+	t.Parallel()
+
+	entries := []service.StatementEntry{
+		{ID: "a1", BookingDate: "2024-07-15", ValueDate: "2024-07-16", Amount: "-10.3", Currency: "EUR", Description: "rent, july", Counterparty: "ACME", Reference: "R1", Type: "DEBIT"},
+		{ID: "a2", BookingDate: "15/07/2024", Amount: "5"},
+	}
+	reader := service.NewEntryStatementReader(func() (service.StatementEntry, error) {
+		if len(entries) == 0 {
+			return service.StatementEntry{}, io.EOF
+		}
+		e := entries[0]
+		entries = entries[1:]
+		return e, nil
+	})
+
+	rec, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rec.Line)
+	assert.Equal(t, `a1,2024-07-15,2024-07-16,-10.3,EUR,"rent, july",ACME,R1,DEBIT`, rec.Raw)
+
+	var txn domain.Transaction
+	assert.NoError(t, reader.Parse(rec, &txn))
+	valueDate := time.Date(2024, 7, 16, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, domain.Transaction{
+		FileTransactionID: "a1",
+		Date:              time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+		ValueDate:         &valueDate,
+		Amount:            domain.MustParseAmount("-10.3"),
+		Currency:          "EUR",
+		Description:       "rent, july",
+		Counterparty:      "ACME",
+		Reference:         "R1",
+		Type:              "DEBIT",
+	}, txn)
+
+	rec, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rec.Line)
+	assert.Error(t, reader.Parse(rec, &domain.Transaction{}))
+
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
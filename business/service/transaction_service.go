@@ -1,142 +1,434 @@
 package service
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/fedepezzola/transactions/business/domain"
+	"github.com/fedepezzola/transactions/foundation/database"
 	"go.uber.org/zap"
 )
 
+// ErrFileAlreadyImported is returned when the content of a file was already
+// imported into the account by a previous batch.
+var ErrFileAlreadyImported = errors.New("file already imported")
+
+// ErrStatementBalanceMismatch is returned when ImportOptions.RequireBalanceMatch
+// is set and the balance of an account after the import differs from the
+// closing balance reported in the statement.
+var ErrStatementBalanceMismatch = errors.New("balance does not match the statement")
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ErrFXRateNotFound is returned for rows in a currency that can not be
+// converted to the one of the account on their date.
+var ErrFXRateNotFound = errors.New("fx rate not found")
+
+// ErrAccountNotFound is returned for accounts that do not exist when
+// ImportOptions.RejectUnknownAccounts is set.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrOverdraftLimitExceeded is returned for rows that take the balance of an
+// account below its overdraft limit.
+var ErrOverdraftLimitExceeded = errors.New("overdraft limit exceeded")
+
+// DefaultCurrency is the currency of accounts that do not name one.
+const DefaultCurrency = "USD"
+
+// RecentTransactionsLimit is the number of transactions kept in
+// AccountStats.RecentTransactions.
+const RecentTransactionsLimit = 10
+
+// DefaultBatchSize is the number of transactions stored at once when
+// ImportOptions.BatchSize is not set.
+const DefaultBatchSize = 1000
+
+// Error handling modes for ImportOptions.OnError.
+const (
+	// OnErrorAbort fails the whole file on the first row that can not be read.
+	OnErrorAbort = "abort"
+	// OnErrorSkip rejects rows that can not be read and imports the rest.
+	OnErrorSkip = "skip"
+)
+
 type (
 	AccountRepository interface {
 		Insert(ctx context.Context, m *domain.Account) (*domain.Account, error)
 		Update(ctx context.Context, m *domain.Account) (*domain.Account, error)
+		// GetByAccountNumber returns database.ErrDBNotFound when there is no
+		// account with the number.
 		GetByAccountNumber(_ context.Context, accountNumber string) (*domain.Account, error)
+		// GetByAccountNumberForUpdate reads the account and locks it until
+		// the transaction ends. It fails like GetByAccountNumber.
+		GetByAccountNumberForUpdate(_ context.Context, accountNumber string) (*domain.Account, error)
+		// GetByIDForUpdate reads the account with the id and locks it like
+		// GetByAccountNumberForUpdate.
+		GetByIDForUpdate(ctx context.Context, id int64) (*domain.Account, error)
+		// List returns up to limit customer accounts in account number
+		// order, starting after the given number.
+		List(ctx context.Context, after string, limit int) ([]*domain.Account, error)
+		// ComputeBalances recomputes the balance of every customer account
+		// from what was posted to it, in account number order.
+		ComputeBalances(ctx context.Context) ([]domain.AccountBalance, error)
+		// ComputeBalance recomputes the balance of one account. It returns
+		// database.ErrDBNotFound when there is no such account.
+		ComputeBalance(ctx context.Context, accountID int64) (*domain.AccountBalance, error)
 	}
 
 	TransactionRepository interface {
 		Insert(ctx context.Context, m *domain.Transaction) (*domain.Transaction, error)
+		// BulkInsert stores txns in as few round trips as possible and sets
+		// their ids.
+		BulkInsert(ctx context.Context, txns []*domain.Transaction) error
+		// GetByID returns database.ErrDBNotFound when there is no
+		// transaction with the id.
+		GetByID(ctx context.Context, id int64) (*domain.Transaction, error)
+		// GetByBatchID returns the transactions of an ingestion batch in
+		// file order.
+		GetByBatchID(ctx context.Context, batchID int64) ([]*domain.Transaction, error)
+		// ImportedFileIDs returns the ones of fileTransactionIDs already
+		// imported into the account by any batch.
+		ImportedFileIDs(ctx context.Context, accountID int64, fileTransactionIDs []string) ([]string, error)
+		// MarkReversed flags the transactions offset by a reversal, so their
+		// rows are no longer seen as imported.
+		MarkReversed(ctx context.Context, ids []int64) error
+		// ListByAccount returns a page of the transactions matching a
+		// validated query.
+		ListByAccount(ctx context.Context, query domain.TransactionQuery) (*domain.TransactionPage, error)
+	}
+
+	ReversalRepository interface {
+		Insert(ctx context.Context, m *domain.Reversal) (*domain.Reversal, error)
+	}
+
+	IngestionBatchRepository interface {
+		Insert(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error)
+		Update(ctx context.Context, m *domain.IngestionBatch) (*domain.IngestionBatch, error)
+		GetByContentHash(ctx context.Context, accountID int64, contentHash string) (*domain.IngestionBatch, error)
+		// ClearReversedContentHashes forgets the content hash of the batches
+		// whose transactions are all reversed, so their file can be imported
+		// again.
+		ClearReversedContentHashes(ctx context.Context, batchIDs []int64) error
+	}
+
+	FXRateRepository interface {
+		Upsert(ctx context.Context, m *domain.FXRate) (*domain.FXRate, error)
+		GetRate(ctx context.Context, baseCurrency string, quoteCurrency string, date time.Time) (*domain.FXRate, error)
+	}
+
+	// LedgerRepository stores journal entries and sums the ledger.
+	LedgerRepository interface {
+		// Post stores the entries with their lines and sets their ids.
+		Post(ctx context.Context, entries []*domain.JournalEntry) error
+		// Totals adds up the ledger entries of every currency.
+		Totals(ctx context.Context) ([]domain.LedgerTotal, error)
+		// UnbalancedEntries returns the ids of the journal entries whose
+		// lines do not add up to zero.
+		UnbalancedEntries(ctx context.Context) ([]int64, error)
+	}
+
+	// ImportJobRepository stores the import jobs and hands them to the
+	// workers.
+	ImportJobRepository interface {
+		Insert(ctx context.Context, m *domain.ImportJob) (*domain.ImportJob, error)
+		// GetByID returns the job without its content, or
+		// database.ErrDBNotFound.
+		GetByID(ctx context.Context, id int64) (*domain.ImportJob, error)
+		// Claim marks the oldest queued job, or a running one whose lease
+		// expired, as running for another attempt and leases it for lease.
+		// It returns database.ErrDBNotFound when no job is waiting.
+		Claim(ctx context.Context, lease time.Duration) (*domain.ImportJob, error)
+		// Heartbeat extends the lease of a running job and stores its
+		// progress. It returns database.ErrDBNotFound when the attempt of m
+		// no longer holds the job.
+		Heartbeat(ctx context.Context, m *domain.ImportJob, lease time.Duration) error
+		// Finish stores the status, progress, stats and error of m and
+		// drops its content. It fails like Heartbeat.
+		Finish(ctx context.Context, m *domain.ImportJob) error
+		// Release puts a running job back in the queue. It fails like
+		// Heartbeat.
+		Release(ctx context.Context, m *domain.ImportJob) error
 	}
 
 	NotificationsRepository interface {
 		Notify(data interface{}) error
+		// Alert sends an alert that needs attention, apart from the regular
+		// notifications.
+		Alert(data interface{}) error
+	}
+
+	// Transactor runs fn inside a single database transaction. The
+	// repositories handed to fn are bound to that transaction, and any error
+	// returned by fn rolls back everything they wrote.
+	Transactor interface {
+		WithinTran(ctx context.Context, fn func(Repositories) error) error
+	}
+
+	// Repositories groups the repositories available inside a transaction.
+	Repositories struct {
+		Account     AccountRepository
+		Transaction TransactionRepository
+		Batch       IngestionBatchRepository
+		FXRate      FXRateRepository
+		Ledger      LedgerRepository
+		Reversal    ReversalRepository
+		ImportJob   ImportJobRepository
 	}
 
 	TransactionService struct {
 		log                     *zap.SugaredLogger
+		Transactor              Transactor
 		AccountRepository       AccountRepository
 		TransactionRepository   TransactionRepository
 		NotificationsRepository NotificationsRepository
 	}
 
 	AccountStats struct {
-		Balance              float32
-		FileBalance          float32
+		AccountNumber        string
+		Currency             string
+		BatchID              int64
+		Balance              domain.Amount
+		FileBalance          domain.Amount
+		DryRun               bool
 		TransactionCount     int
 		TransactionsPerMonth [12]int
 		DebitCount           int
-		DebitAvg             float32
+		DebitTotal           domain.Amount
+		DebitAvg             domain.Amount
 		CreditCount          int
-		CreditAvg            float32
+		CreditTotal          domain.Amount
+		CreditAvg            domain.Amount
+		DuplicatesSkipped    int
+		DuplicateIDs         []string
+		RejectedCount        int
+		Rejected             []RejectedRow
+		StatementBalance     *domain.Amount
+		// StatementOpeningBalance is the opening balance reported in the
+		// statement, when it has one.
+		StatementOpeningBalance *domain.Amount
+		// Reconciled tells whether Balance matches StatementBalance, and
+		// BalanceDifference is what the statement reports over Balance. Both
+		// are left empty when the statement has no closing balance.
+		Reconciled        bool
+		BalanceDifference domain.Amount
+		// RecentTransactions holds the last RecentTransactionsLimit
+		// transactions stored, with their details, for the notifications.
+		RecentTransactions []domain.Transaction
+		// CurrencyTotals adds up the rows of each statement currency.
+		CurrencyTotals map[string]CurrencyTotal
+		// OverdraftBreaches lists the rows that took the balance below the
+		// overdraft limit of the account, whether they were imported or not.
+		OverdraftBreaches []OverdraftBreach
+	}
+
+	// OverdraftBreach is a row that took the balance of an account below its
+	// overdraft limit. Balance is the balance the row would leave.
+	OverdraftBreach struct {
+		Line              int
+		FileTransactionID string
+		Amount            domain.Amount
+		Balance           domain.Amount
+		Limit             domain.Amount
+		Policy            domain.OverdraftPolicy
+		// Rejected tells whether the row was left out of the import.
+		Rejected bool
+	}
+
+	// OverdraftAlert is sent through NotificationsRepository.Alert once an
+	// import that breached the overdraft limit of an account is committed.
+	OverdraftAlert struct {
+		AccountNumber string
+		Currency      string
+		Balance       domain.Amount
+		Breaches      []OverdraftBreach
+	}
+
+	// CurrencyTotal adds up the rows of an import in one currency, both in
+	// that currency and converted to the one of the account.
+	CurrencyTotal struct {
+		Count     int
+		Total     domain.Amount
+		Converted domain.Amount
+	}
+
+	// ImportSummary is the result of importing a statement that may hold
+	// transactions for several accounts.
+	ImportSummary struct {
+		Accounts         []*AccountStats
+		TransactionCount int
+		FileBalance      domain.Amount
+		RejectedCount    int
+		Rejected         []RejectedRow
+		DryRun           bool
+	}
+
+	// ImportOptions tunes a single import.
+	ImportOptions struct {
+		// OnError is OnErrorAbort or OnErrorSkip. Empty means OnErrorAbort.
+		OnError string
+		// DryRun runs the whole import and returns its stats, then rolls it
+		// back and sends no notification.
+		DryRun bool
+		// RequireBalanceMatch fails the import with
+		// ErrStatementBalanceMismatch when the closing balance of the
+		// statement differs from the computed balance of the account.
+		RequireBalanceMatch bool
+		// Currency is the currency of the accounts created by the import.
+		// Empty means DefaultCurrency.
+		Currency string
+		// RejectUnknownAccounts fails rows of accounts that do not exist
+		// with ErrAccountNotFound instead of creating the accounts. Like
+		// other bad rows they are rejected with OnErrorSkip, except the
+		// default account of the import, which always fails it. Rows of
+		// frozen or closed accounts are handled the same way with
+		// ErrAccountInactive.
+		RejectUnknownAccounts bool
+		// BatchSize is the number of parsed rows kept in memory before they
+		// are stored. Zero means DefaultBatchSize.
+		BatchSize int
+		// Workers is the number of goroutines parsing records. Zero means
+		// one per CPU.
+		Workers int
+		// OnProgress, when set, is called as rows are stored, and once more
+		// when the import stops, with the number of rows read so far and
+		// how many of them were rejected.
+		OnProgress func(processed int, rejected int)
+		// OnCommit, when set, runs inside the database transaction of the
+		// import once every row is stored, so what it writes with repos is
+		// saved along with the import. An error rolls the import back. It
+		// is not called on dry runs.
+		OnCommit func(repos Repositories, summary *ImportSummary) error
+	}
+
+	// RejectedRow is a row left out of an import because it could not be
+	// read, kept so it can be fixed and submitted again.
+	RejectedRow struct {
+		Line   int
+		Raw    string
+		Reason string
 	}
 )
 
 func NewTransactionService(log *zap.SugaredLogger,
+	transactor Transactor,
 	accountRepository AccountRepository,
 	transactionRepository TransactionRepository,
 	notificationsRepository NotificationsRepository,
 ) *TransactionService {
 	return &TransactionService{
 		log:                     log,
+		Transactor:              transactor,
 		AccountRepository:       accountRepository,
 		TransactionRepository:   transactionRepository,
 		NotificationsRepository: notificationsRepository,
 	}
 }
 
-func (s *TransactionService) ProcessTransactionsStream(ctx context.Context, accountNumber string, scanner *bufio.Scanner) (*AccountStats, error) {
-	account, err := s.AccountRepository.GetByAccountNumber(ctx, accountNumber)
+// ProcessTransactionsStream imports every transaction read from the statement
+// into the account. The account lookup, the inserts and the balance update
+// run in one database transaction so a file that fails half way leaves no
+// trace. With OnErrorSkip, rows that can not be read are reported in the stats
+// instead of failing the file. A dry run goes through the same steps inside a
+// transaction that is always rolled back.
+//
+// Each file is recorded as an ingestion batch. Rows repeating a file
+// transaction ID already seen in the file, or already imported into the
+// account by an earlier file, are skipped and reported in the stats, and a
+// file whose content was already imported into the account is rejected with
+// ErrFileAlreadyImported.
+func (s *TransactionService) ProcessTransactionsStream(ctx context.Context, accountNumber string, reader StatementReader, opts ImportOptions) (*AccountStats, error) {
+	summary, err := s.importStatement(ctx, accountNumber, reader, opts, false)
 	if err != nil {
-		// Assuming it fails because it does not exist
-		account, err = s.AccountRepository.Insert(ctx, &domain.Account{AccountNumber: accountNumber, Balance: 0.0})
-		if err != nil {
-			return nil, fmt.Errorf("error retrieving account: %w", err)
-		}
+		return nil, err
 	}
 
-	accountStats := AccountStats{
-		Balance:              account.Balance,
-		FileBalance:          0.0,
-		TransactionCount:     0,
-		TransactionsPerMonth: [12]int{0},
-		DebitCount:           0,
-		DebitAvg:             0.0,
-		CreditCount:          0,
-		CreditAvg:            0.0,
-	}
-
-	transaction := domain.Transaction{
-		ProcessingTimestamp: time.Now(),
-		AccountID:           account.ID,
-	}
+	return summary.Accounts[0], nil
+}
 
-	for scanner.Scan() {
-		txn, err := parseTransaction(scanner.Text(), &transaction)
-		if err != nil {
-			return nil, fmt.Errorf("error reading from file: %w", err)
-		}
+// ProcessStatement imports a statement whose rows may belong to different
+// accounts. Rows naming an account are routed to it, creating it when needed,
+// and rows without one go to defaultAccountNumber. Every account gets its own
+// ingestion batch, stats and notification, all within one database
+// transaction.
+func (s *TransactionService) ProcessStatement(ctx context.Context, defaultAccountNumber string, reader StatementReader, opts ImportOptions) (*ImportSummary, error) {
+	return s.importStatement(ctx, defaultAccountNumber, reader, opts, true)
+}
 
-		txn, err = s.TransactionRepository.Insert(ctx, txn)
-		if err != nil {
-			return nil, fmt.Errorf("error storing transaction: %w", err)
-		}
+// ListTransactions returns a page of the transactions of the account matching
+// the query, with the cursor of the next page. Transactions reversed later
+// are listed with the id of the one offsetting them.
+func (s *TransactionService) ListTransactions(ctx context.Context, accountNumber string, query domain.TransactionQuery) (*domain.TransactionPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
 
-		accountStats.Balance += txn.Amount
-		accountStats.FileBalance += txn.Amount
-		accountStats.TransactionCount++
-		accountStats.TransactionsPerMonth[txn.Month-1]++
-		if txn.Amount < 0 {
-			accountStats.CreditCount++
-			accountStats.CreditAvg = (accountStats.CreditAvg*float32(accountStats.CreditCount-1) + txn.Amount) / float32(accountStats.CreditCount)
-		} else {
-			accountStats.DebitCount++
-			accountStats.DebitAvg = (accountStats.DebitAvg*float32(accountStats.DebitCount-1) + txn.Amount) / float32(accountStats.DebitCount)
-		}
+	account, err := s.account(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	query.AccountID = account.ID
 
-		s.log.Info(accountStats)
+	page, err := s.TransactionRepository.ListByAccount(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing transactions: %w", err)
 	}
 
-	account.Balance = accountStats.Balance
+	return page, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading from file: %w", err)
+// Stats adds up the transactions of the account matching the filters of the
+// query, in date order, like an import does for the rows of a statement.
+// Balance is the current balance of the account and FileBalance the sum of
+// the transactions. The order and page of the query are not used.
+func (s *TransactionService) Stats(ctx context.Context, accountNumber string, query domain.TransactionQuery) (*AccountStats, error) {
+	query.SortBy = domain.SortByDate
+	query.Descending = false
+	query.Limit = domain.MaxPageSize
+	query.After = nil
+	if err := query.Validate(); err != nil {
+		return nil, err
 	}
 
-	_, err = s.AccountRepository.Update(ctx, account)
+	account, err := s.account(ctx, accountNumber)
 	if err != nil {
-		return nil, fmt.Errorf("error updating account: %w", err)
+		return nil, err
 	}
+	query.AccountID = account.ID
 
-	err = s.NotificationsRepository.Notify(accountStats)
-	if err != nil {
-		return nil, fmt.Errorf("error notifying: %w", err)
+	stats := &AccountStats{
+		AccountNumber: account.AccountNumber,
+		Currency:      account.Currency,
+		BatchID:       query.BatchID,
 	}
+	for {
+		page, err := s.TransactionRepository.ListByAccount(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("error listing transactions: %w", err)
+		}
+		for _, txn := range page.Transactions {
+			stats.apply(txn)
+		}
+		if page.Next == nil {
+			break
+		}
+		query.After = page.Next
+	}
+	stats.Balance = account.Balance
 
-	return &accountStats, nil
+	return stats, nil
 }
 
-func parseTransaction(txt string, txn *domain.Transaction) (*domain.Transaction, error) {
-	p, err := fmt.Sscanf(txt, "%d,%d/%d,%f",
-		&txn.FileTransactionID, &txn.Month, &txn.Day, &txn.Amount,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+// account returns the account with the number, or ErrAccountNotFound.
+func (s *TransactionService) account(ctx context.Context, accountNumber string) (*domain.Account, error) {
+	account, err := s.AccountRepository.GetByAccountNumber(ctx, accountNumber)
+	if errors.Is(err, database.ErrDBNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, accountNumber)
 	}
-	if p != 4 {
-		return nil, fmt.Errorf("line format error: expected data fields 4, received %d", p)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving account: %w", err)
 	}
-	return txn, nil
+	return account, nil
 }
@@ -1,17 +1,22 @@
 package service_test
 
 import (
-	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/fedepezzola/transactions/business/domain"
 	"github.com/fedepezzola/transactions/business/service"
+	"github.com/fedepezzola/transactions/foundation/database"
 	"github.com/fedepezzola/transactions/foundation/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -19,9 +24,17 @@ type testHelper struct {
 	ctx     context.Context
 	log     *zap.SugaredLogger
 	service *service.TransactionService
+	account *domain.Account
+	// imported are the file ids found in earlier batches of every account.
+	imported []string
 
+	transactor              *service.MockTransactor
 	accountRepository       *service.MockAccountRepository
 	transactionRepository   *service.MockTransactionRepository
+	batchRepository         *service.MockIngestionBatchRepository
+	fxRateRepository        *service.MockFXRateRepository
+	ledgerRepository        *service.MockLedgerRepository
+	reversalRepository      *service.MockReversalRepository
 	notificationsRepository *service.MockNotificationsRepository
 }
 
@@ -34,86 +47,1001 @@ func testSetup(t *testing.T) *testHelper {
 
 	h.log, _ = logger.New("TRANSACTIONS-TEST")
 
+	h.transactor = &service.MockTransactor{}
 	h.accountRepository = &service.MockAccountRepository{}
 	h.transactionRepository = &service.MockTransactionRepository{}
+	h.batchRepository = &service.MockIngestionBatchRepository{}
+	h.fxRateRepository = &service.MockFXRateRepository{}
+	h.ledgerRepository = &service.MockLedgerRepository{}
+	h.reversalRepository = &service.MockReversalRepository{}
 	h.notificationsRepository = &service.MockNotificationsRepository{}
 
-	account := domain.Account{
+	h.account = &domain.Account{
 		ID:            1,
 		AccountNumber: "123456",
-		Balance:       10.0,
+		Currency:      "USD",
+		Balance:       domain.MustParseAmount("10"),
 	}
 
-	h.accountRepository.EXPECT().GetByAccountNumber(h.ctx, mock.AnythingOfType("string")).Return(&account, nil)
-	h.accountRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.Account")).Return(&account, nil)
-	h.accountRepository.EXPECT().Update(h.ctx, mock.AnythingOfType("*domain.Account")).Return(&account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, mock.MatchedBy(func(n string) bool {
+		return strings.HasPrefix(n, domain.ClearingAccountNumber(""))
+	})).RunAndReturn(func(_ context.Context, n string) (*domain.Account, error) {
+		return &domain.Account{
+			ID:            100,
+			AccountNumber: n,
+			Kind:          domain.AccountClearing,
+			Currency:      strings.TrimPrefix(n, domain.ClearingAccountNumber("")),
+		}, nil
+	})
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(h.account, nil)
+	h.accountRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(h.account, nil)
+
+	h.transactionRepository.EXPECT().ImportedFileIDs(mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("[]string")).RunAndReturn(func(_ context.Context, _ int64, ids []string) ([]string, error) {
+		var found []string
+		for _, id := range ids {
+			if slices.Contains(h.imported, id) {
+				found = append(found, id)
+			}
+		}
+		return found, nil
+	})
+
+	h.batchRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		b.ID = 7
+		return b, nil
+	})
+	h.batchRepository.EXPECT().Update(mock.Anything, mock.AnythingOfType("*domain.IngestionBatch")).RunAndReturn(func(_ context.Context, b *domain.IngestionBatch) (*domain.IngestionBatch, error) {
+		return b, nil
+	})
+
+	h.ledgerRepository.EXPECT().Post(mock.Anything, mock.AnythingOfType("[]*domain.JournalEntry")).Return(nil)
 
 	h.notificationsRepository.EXPECT().Notify(mock.Anything).Return(nil)
+	h.notificationsRepository.EXPECT().Alert(mock.Anything).Return(nil)
 
-	h.service = service.NewTransactionService(h.log, h.accountRepository, h.transactionRepository, h.notificationsRepository)
+	h.transactor.EXPECT().WithinTran(h.ctx, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		return fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			FXRate:      h.fxRateRepository,
+			Ledger:      h.ledgerRepository,
+			Reversal:    h.reversalRepository,
+		})
+	})
+
+	h.service = service.NewTransactionService(h.log, h.transactor, h.accountRepository, h.transactionRepository, h.notificationsRepository)
 
 	return h
 }
 
+func csvReader(t *testing.T, data string, format service.CSVFormat) *service.CSVStatementReader {
+	t.Helper()
+
+	reader, err := service.NewCSVStatementReader(strings.NewReader(data), format)
+	if err != nil {
+		t.Fatalf("creating csv reader: %s", err)
+	}
+	return reader
+}
+
 func Test_ProcessTransactionsStream_returns_stats(t *testing.T) {
 	t.Parallel()
 	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
 
-	data := `0,7/15,+60.5
+	data := `Id,Date,Transaction
+0,7/15,+60.5
 1,7/28,-10.3
 2,8/2,-20.46
 3,8/13,+10
 `
 
-	h.transactionRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.Transaction")).Return(&domain.Transaction{
-		ID:                  1,
-		AccountID:           1,
-		ProcessingTimestamp: time.Now(),
-		FileTransactionID:   0,
-		Month:               7,
-		Day:                 28,
-		Amount:              60.5,
-	}, nil).Once()
-	h.transactionRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.Transaction")).Return(&domain.Transaction{
-		ID:                  2,
-		AccountID:           1,
-		ProcessingTimestamp: time.Now(),
-		FileTransactionID:   1,
-		Month:               7,
-		Day:                 15,
-		Amount:              -10.3,
-	}, nil).Once()
-	h.transactionRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.Transaction")).Return(&domain.Transaction{
-		ID:                  3,
-		AccountID:           1,
-		ProcessingTimestamp: time.Now(),
-		FileTransactionID:   2,
-		Month:               8,
-		Day:                 2,
-		Amount:              -20.46,
-	}, nil).Once()
-	h.transactionRepository.EXPECT().Insert(h.ctx, mock.AnythingOfType("*domain.Transaction")).Return(&domain.Transaction{
-		ID:                  4,
-		AccountID:           1,
-		ProcessingTimestamp: time.Now(),
-		FileTransactionID:   3,
-		Month:               8,
-		Day:                 13,
-		Amount:              10,
-	}, nil).Once()
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		assert.Len(t, txns, 4)
+		for i, txn := range txns {
+			txn.ID = int64(i + 1)
+		}
+		return nil
+	}).Once()
 
-	scanner := bufio.NewScanner(strings.NewReader(data))
-
-	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", scanner)
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
 	assert.NoError(t, err)
+
+	var recentIDs []int64
+	for _, txn := range stats.RecentTransactions {
+		recentIDs = append(recentIDs, txn.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4}, recentIDs)
+	stats.RecentTransactions = nil
+
 	assert.Equal(t, &service.AccountStats{
-		Balance:              49.74,
-		FileBalance:          39.74,
+		AccountNumber:        "123456",
+		Currency:             "USD",
+		BatchID:              7,
+		Balance:              domain.MustParseAmount("49.74"),
+		FileBalance:          domain.MustParseAmount("39.74"),
 		TransactionCount:     4,
 		TransactionsPerMonth: [12]int{0, 0, 0, 0, 0, 0, 2, 2, 0, 0, 0, 0},
 		DebitCount:           2,
-		DebitAvg:             35.25,
+		DebitTotal:           domain.MustParseAmount("70.5"),
+		DebitAvg:             domain.MustParseAmount("35.25"),
 		CreditCount:          2,
-		CreditAvg:            -15.379999,
+		CreditTotal:          domain.MustParseAmount("-30.76"),
+		CreditAvg:            domain.MustParseAmount("-15.38"),
+		CurrencyTotals: map[string]service.CurrencyTotal{
+			"USD": {Count: 4, Total: domain.MustParseAmount("39.74"), Converted: domain.MustParseAmount("39.74")},
+		},
 	}, stats)
 }
+
+func Test_ProcessTransactionsStream_fails_whole_file_on_bad_line(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,not-an-amount
+`
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.Error(t, err)
+	assert.Nil(t, stats)
+	h.transactionRepository.AssertNotCalled(t, "BulkInsert", mock.Anything, mock.Anything)
+	h.accountRepository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ProcessTransactionsStream_skips_duplicated_rows(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+0,7/15,+60.5
+1,7/28,-10.3
+`
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			assert.Equal(t, int64(7), txn.BatchID)
+		}
+		return nil
+	}).Once()
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+	assert.Equal(t, 1, stats.DuplicatesSkipped)
+	assert.Equal(t, []string{"0"}, stats.DuplicateIDs)
+	assert.Equal(t, domain.MustParseAmount("60.2"), stats.Balance)
+}
+
+func Test_ProcessTransactionsStream_rejects_already_imported_file(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.imported = []string{"0"}
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(&domain.IngestionBatch{ID: 3}, nil)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+`
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.ErrorIs(t, err, service.ErrFileAlreadyImported)
+	assert.Nil(t, stats)
+	h.transactionRepository.AssertNotCalled(t, "BulkInsert", mock.Anything, mock.Anything)
+	h.batchRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+	h.accountRepository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ProcessTransactionsStream_skips_rows_imported_by_other_files(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.imported = []string{"1"}
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.account.OverdraftLimit = new(domain.Amount)
+
+	// Row 1 would take the account over its limit if it was counted again.
+	data := `Id,Date,Transaction
+1,7/28,-10
+2,8/2,-5
+`
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		assert.Len(t, txns, 1)
+		assert.Equal(t, "2", txns[0].FileTransactionID)
+		return nil
+	}).Once()
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TransactionCount)
+	assert.Equal(t, 1, stats.DuplicatesSkipped)
+	assert.Equal(t, []string{"1"}, stats.DuplicateIDs)
+	assert.Empty(t, stats.OverdraftBreaches)
+	assert.Equal(t, domain.MustParseAmount("5"), stats.Balance)
+}
+
+func Test_ProcessTransactionsStream_does_not_hash_empty_files(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, "Id,Date,Transaction\n", service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), stats.BatchID)
+	h.batchRepository.AssertNotCalled(t, "GetByContentHash", mock.Anything, mock.Anything, mock.Anything)
+	h.batchRepository.AssertCalled(t, "Update", mock.Anything, mock.MatchedBy(func(b *domain.IngestionBatch) bool {
+		return b.ContentHash == "" && b.RowCount == 0 && b.FinishedAt != nil
+	}))
+}
+
+func Test_ProcessTransactionsStream_reads_dates(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	data := `Id,Date,Transaction
+0,12/31/2023,+60.5
+1,2024-01-02,-10.3
+2,1/1,+1
+`
+
+	var dates []time.Time
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			dates = append(dates, txn.Date)
+		}
+		return nil
+	}).Once()
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, [12]int{2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, stats.TransactionsPerMonth)
+	assert.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), dates[0])
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), dates[1])
+	assert.Equal(t, time.January, dates[2].Month())
+	assert.Equal(t, 1, dates[2].Day())
+	assert.False(t, dates[2].After(time.Now()))
+}
+
+func Test_ProcessTransactionsStream_reads_mapped_csv_columns(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	data := `Buchungstag;Verwendungszweck;Betrag;Ref;Empfaenger
+15.07.2024;"Rent; July";1.200,50;10;Landlord Ltd
+28.07.2024;Coffee;-3,75;11;
+`
+
+	var stored []domain.Transaction
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			stored = append(stored, *txn)
+		}
+		return nil
+	}).Once()
+
+	format := service.CSVFormat{
+		Delimiter:          ';',
+		IDColumn:           "ref",
+		DateColumn:         "Buchungstag",
+		AmountColumn:       "Betrag",
+		DescriptionColumn:  "Verwendungszweck",
+		CounterpartyColumn: "Empfaenger",
+		DateLayout:         "02.01.2006",
+		DecimalSeparator:   ',',
+		SignConvention:     service.SignInverted,
+	}
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MustParseAmount("-1196.75"), stats.FileBalance)
+	assert.Equal(t, "10", stored[0].FileTransactionID)
+	assert.Equal(t, time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC), stored[0].Date)
+	assert.Equal(t, domain.MustParseAmount("-1200.50"), stored[0].Amount)
+	assert.Equal(t, domain.MustParseAmount("3.75"), stored[1].Amount)
+	assert.Equal(t, "Rent; July", stored[0].Description)
+	assert.Equal(t, "Landlord Ltd", stored[0].Counterparty)
+	assert.Equal(t, "", stored[1].Counterparty)
+}
+
+func Test_NewCSVStatementReader_requires_mapped_columns(t *testing.T) {
+	t.Parallel()
+
+	_, err := service.NewCSVStatementReader(strings.NewReader("Id,When,Transaction\n"), service.DefaultCSVFormat())
+	assert.ErrorIs(t, err, service.ErrMissingColumn)
+}
+
+func Test_ProcessTransactionsStream_skips_bad_rows(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,not-an-amount
+2,8/2,"-20"46
+3,8/13,+10
+4, "8/20","not, an amount"
+`
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{OnError: service.OnErrorSkip})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("80.5"), stats.Balance)
+	assert.Equal(t, 3, stats.RejectedCount)
+	assert.Equal(t, 3, stats.Rejected[0].Line)
+	assert.Equal(t, "1,7/28,not-an-amount", stats.Rejected[0].Raw)
+	assert.Contains(t, stats.Rejected[0].Reason, "error reading amount")
+	assert.Equal(t, 4, stats.Rejected[1].Line)
+	assert.Equal(t, `2,8/2,"-20"46`, stats.Rejected[1].Raw)
+	assert.Equal(t, 6, stats.Rejected[2].Line)
+	assert.Equal(t, `4, "8/20","not, an amount"`, stats.Rejected[2].Raw)
+}
+
+func Test_ProcessTransactionsStream_dry_run_rolls_back(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	var tranErr error
+	h.transactor.ExpectedCalls = nil
+	h.transactor.EXPECT().WithinTran(h.ctx, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		tranErr = fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			FXRate:      h.fxRateRepository,
+			Ledger:      h.ledgerRepository,
+			Reversal:    h.reversalRepository,
+		})
+		return tranErr
+	})
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,-10.3
+`
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Error(t, tranErr)
+	assert.True(t, stats.DryRun)
+	assert.Equal(t, domain.MustParseAmount("50.2"), stats.FileBalance)
+	assert.Equal(t, domain.MustParseAmount("60.2"), stats.Balance)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ProcessStatement_routes_rows_by_account(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, mock.AnythingOfType("int64"), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "777").Return(&domain.Account{
+		ID:            2,
+		AccountNumber: "777",
+		Balance:       domain.MustParseAmount("100"),
+	}, nil)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "888").Return(nil, database.ErrDBNotFound)
+	h.accountRepository.ExpectedCalls = slices.DeleteFunc(h.accountRepository.ExpectedCalls, func(c *mock.Call) bool {
+		return c.Method == "Insert"
+	})
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Account")).RunAndReturn(func(_ context.Context, a *domain.Account) (*domain.Account, error) {
+		a.ID = 3
+		return a, nil
+	}).Once()
+
+	data := `Id,Date,Transaction,Account
+0,7/15,+60.5,777
+1,7/28,-10.3,
+2,8/2,-20.46,888
+3,8/13,+10,777
+`
+
+	accounts := map[int64]int{}
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			accounts[txn.AccountID]++
+		}
+		return nil
+	}).Once()
+
+	format := service.DefaultCSVFormat()
+	format.AccountColumn = "Account"
+
+	summary, err := h.service.ProcessStatement(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]int{1: 1, 2: 2, 3: 1}, accounts)
+	assert.Equal(t, 4, summary.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("39.74"), summary.FileBalance)
+	assert.Len(t, summary.Accounts, 3)
+	assert.Equal(t, "777", summary.Accounts[0].AccountNumber)
+	assert.Equal(t, domain.MustParseAmount("170.5"), summary.Accounts[0].Balance)
+	assert.Equal(t, "123456", summary.Accounts[1].AccountNumber)
+	assert.Equal(t, domain.MustParseAmount("-0.3"), summary.Accounts[1].Balance)
+	assert.Equal(t, "888", summary.Accounts[2].AccountNumber)
+	assert.Equal(t, domain.MustParseAmount("-20.46"), summary.Accounts[2].Balance)
+	h.notificationsRepository.AssertNumberOfCalls(t, "Notify", 3)
+}
+
+func Test_ProcessTransactionsStream_keeps_ofx_ledger_balance(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+
+	reader, err := service.NewOFXStatementReader(strings.NewReader(ofxSGML))
+	assert.NoError(t, err)
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", reader, service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("50.2"), stats.FileBalance)
+	assert.Equal(t, domain.MustParseAmount("1050.20"), *stats.StatementBalance)
+}
+
+func Test_ProcessTransactionsStream_reconciles_closing_balance(t *testing.T) {
+	t.Parallel()
+
+	for name, data := range map[string]string{"camt053": camt053, "mt940": mt940} {
+		h := testSetup(t)
+		h.account.Currency = "EUR"
+		h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+		var stored []domain.Transaction
+		h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+			for _, txn := range txns {
+				stored = append(stored, *txn)
+			}
+			return nil
+		}).Once()
+
+		reader, err := service.NewStatementReader(strings.NewReader(data), service.FormatAuto, service.DefaultCSVFormat())
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", reader, service.ImportOptions{RequireBalanceMatch: true})
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.Equal(t, domain.MustParseAmount("83.25"), stats.Balance, name)
+		assert.Equal(t, domain.MustParseAmount("10"), *stats.StatementOpeningBalance, name)
+		assert.Equal(t, domain.MustParseAmount("83.25"), *stats.StatementBalance, name)
+		assert.True(t, stats.Reconciled, name)
+		assert.Equal(t, "EUR", stored[0].Currency, name)
+		assert.NotNil(t, stored[0].ValueDate, name)
+	}
+}
+
+func Test_ProcessTransactionsStream_reports_balance_mismatch(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+
+	reader, err := service.NewOFXStatementReader(strings.NewReader(ofxSGML))
+	assert.NoError(t, err)
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", reader, service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.False(t, stats.Reconciled)
+	assert.Equal(t, domain.MustParseAmount("990"), stats.BalanceDifference)
+
+	reader, err = service.NewOFXStatementReader(strings.NewReader(ofxSGML))
+	assert.NoError(t, err)
+
+	_, err = h.service.ProcessTransactionsStream(h.ctx, "123456", reader, service.ImportOptions{RequireBalanceMatch: true})
+	assert.ErrorIs(t, err, service.ErrStatementBalanceMismatch)
+	h.notificationsRepository.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func Test_ProcessTransactionsStream_converts_foreign_currency_rows(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	july15 := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+	// Only the USD/EUR rate was loaded, so EUR rows use its inverse, looked
+	// up once for both rows of the day.
+	h.fxRateRepository.EXPECT().GetRate(mock.Anything, "EUR", "USD", july15).Return(nil, database.ErrDBNotFound).Once()
+	h.fxRateRepository.EXPECT().GetRate(mock.Anything, "USD", "EUR", july15).Return(&domain.FXRate{
+		Date:          july15.AddDate(0, 0, -2),
+		BaseCurrency:  "USD",
+		QuoteCurrency: "EUR",
+		Rate:          domain.MustParseRate("0.8"),
+	}, nil).Once()
+	h.fxRateRepository.EXPECT().GetRate(mock.Anything, "ARS", "USD", july15).Return(nil, database.ErrDBNotFound).Once()
+	h.fxRateRepository.EXPECT().GetRate(mock.Anything, "USD", "ARS", july15).Return(nil, database.ErrDBNotFound).Once()
+
+	var stored []domain.Transaction
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			stored = append(stored, *txn)
+		}
+		return nil
+	}).Once()
+
+	data := `Id,Date,Transaction,Currency
+0,2024-07-15,+100,eur
+1,2024-07-15,-10,EUR
+2,2024-07-15,+5000,ARS
+3,2024-07-15,-2.5,
+`
+	format := service.DefaultCSVFormat()
+	format.CurrencyColumn = "Currency"
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, format), service.ImportOptions{OnError: service.OnErrorSkip})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "EUR", stored[0].Currency)
+	assert.Equal(t, domain.MustParseAmount("100"), stored[0].OriginalAmount)
+	assert.Equal(t, domain.MustParseAmount("125"), stored[0].Amount)
+	assert.Equal(t, domain.MustParseRate("1.25"), stored[0].FXRate)
+	assert.Equal(t, domain.MustParseAmount("-12.5"), stored[1].Amount)
+	assert.Equal(t, "USD", stored[2].Currency)
+	assert.Equal(t, domain.OneRate, stored[2].FXRate)
+
+	assert.Equal(t, domain.MustParseAmount("120"), stats.Balance)
+	assert.Equal(t, map[string]service.CurrencyTotal{
+		"EUR": {Count: 2, Total: domain.MustParseAmount("90"), Converted: domain.MustParseAmount("112.5")},
+		"USD": {Count: 1, Total: domain.MustParseAmount("-2.5"), Converted: domain.MustParseAmount("-2.5")},
+	}, stats.CurrencyTotals)
+
+	assert.Equal(t, 1, stats.RejectedCount)
+	assert.Equal(t, 4, stats.Rejected[0].Line)
+	assert.Contains(t, stats.Rejected[0].Reason, service.ErrFXRateNotFound.Error())
+}
+
+func Test_ProcessTransactionsStream_stores_rows_in_batches(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	var sizes []int
+	var id int64
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		sizes = append(sizes, len(txns))
+		for _, txn := range txns {
+			id++
+			txn.ID = id
+		}
+		return nil
+	})
+
+	data := `Id,Date,Transaction
+0,7/15,+1
+1,7/16,+2
+2,7/17,+3
+2,7/17,+3
+3,7/18,+4
+4,7/19,-5
+`
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{BatchSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Equal(t, 5, stats.TransactionCount)
+	assert.Equal(t, 1, stats.DuplicatesSkipped)
+	assert.Equal(t, domain.MustParseAmount("15"), stats.Balance)
+	assert.Equal(t, int64(5), stats.RecentTransactions[4].ID)
+}
+
+func Test_ProcessTransactionsStream_keeps_file_order_with_workers(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	var ids []string
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			ids = append(ids, txn.FileTransactionID)
+		}
+		return nil
+	})
+
+	var data strings.Builder
+	var want []string
+	data.WriteString("Id,Date,Transaction\n")
+	for i := range 500 {
+		if i == 250 {
+			data.WriteString("bad,7/15,x\n")
+		}
+		fmt.Fprintf(&data, "%d,7/15,+%d.25\n", i, i%7)
+		want = append(want, strconv.Itoa(i))
+	}
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data.String(), service.DefaultCSVFormat()), service.ImportOptions{OnError: service.OnErrorSkip, BatchSize: 7, Workers: 8})
+	assert.NoError(t, err)
+	assert.Equal(t, want, ids)
+	assert.Equal(t, 500, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("1619"), stats.FileBalance)
+	assert.Equal(t, 252, stats.Rejected[0].Line)
+}
+
+func Test_ProcessTransactionsStream_stops_when_cancelled(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	h.transactor.ExpectedCalls = nil
+	h.transactor.EXPECT().WithinTran(ctx, mock.Anything).RunAndReturn(func(_ context.Context, fn func(service.Repositories) error) error {
+		return fn(service.Repositories{
+			Account:     h.accountRepository,
+			Transaction: h.transactionRepository,
+			Batch:       h.batchRepository,
+			FXRate:      h.fxRateRepository,
+			Ledger:      h.ledgerRepository,
+			Reversal:    h.reversalRepository,
+		})
+	})
+
+	stored := 0
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		stored += len(txns)
+		cancel()
+		return nil
+	})
+
+	var data strings.Builder
+	data.WriteString("Id,Date,Transaction\n")
+	for i := range 100 {
+		fmt.Fprintf(&data, "%d,7/15,+1\n", i)
+	}
+
+	stats, err := h.service.ProcessTransactionsStream(ctx, "123456", csvReader(t, data.String(), service.DefaultCSVFormat()), service.ImportOptions{BatchSize: 1, Workers: 2})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, stats)
+	assert.Less(t, stored, 100)
+	h.accountRepository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func Test_ProcessTransactionsStream_locks_account_created_by_another_import(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(4), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	h.accountRepository.ExpectedCalls = slices.DeleteFunc(h.accountRepository.ExpectedCalls, func(c *mock.Call) bool {
+		return c.Method == "Insert"
+	})
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "999").Return(nil, database.ErrDBNotFound).Once()
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Account")).Return(nil, database.ErrDBDuplicatedEntry).Once()
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "999").Return(&domain.Account{
+		ID:            4,
+		AccountNumber: "999",
+		Currency:      "USD",
+		Balance:       domain.MustParseAmount("5"),
+	}, nil).Once()
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+
+	data := `Id,Date,Transaction
+0,7/15,+1.5
+`
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "999", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MustParseAmount("6.5"), stats.Balance)
+	h.accountRepository.AssertNumberOfCalls(t, "GetByAccountNumberForUpdate", 2)
+}
+
+func Test_ProcessTransactionsStream_does_not_create_account_on_db_error(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+
+	dbErr := errors.New("connection refused")
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "555").Return(nil, dbErr)
+
+	data := `Id,Date,Transaction
+0,7/15,+1.5
+`
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "555", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.ErrorIs(t, err, dbErr)
+	assert.Nil(t, stats)
+	h.accountRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func Test_ProcessStatement_rejects_unknown_accounts(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "888").Return(nil, database.ErrDBNotFound)
+
+	var stored []string
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for _, txn := range txns {
+			stored = append(stored, txn.FileTransactionID)
+		}
+		return nil
+	}).Once()
+
+	data := `Id,Date,Transaction,Account
+0,7/15,+60.5,123456
+1,7/28,-10.3,888
+2,8/2,-20.46,
+`
+	format := service.DefaultCSVFormat()
+	format.AccountColumn = "Account"
+	opts := service.ImportOptions{RejectUnknownAccounts: true}
+
+	_, err := h.service.ProcessStatement(h.ctx, "123456", csvReader(t, data, format), opts)
+	assert.ErrorIs(t, err, service.ErrAccountNotFound)
+
+	opts.OnError = service.OnErrorSkip
+	summary, err := h.service.ProcessStatement(h.ctx, "123456", csvReader(t, data, format), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "2"}, stored)
+	assert.Equal(t, 1, summary.RejectedCount)
+	assert.Equal(t, 3, summary.Rejected[0].Line)
+	h.accountRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+
+	_, err = h.service.ProcessTransactionsStream(h.ctx, "888", csvReader(t, data, format), opts)
+	assert.ErrorIs(t, err, service.ErrAccountNotFound)
+}
+
+const overdraftData = `Id,Date,Transaction
+0,7/15,+5
+1,7/16,-40
+2,7/17,+3
+`
+
+func overdraftSetup(t *testing.T, policy domain.OverdraftPolicy) *testHelper {
+	t.Helper()
+
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil)
+
+	limit := domain.MustParseAmount("20")
+	h.account.OverdraftLimit = &limit
+	h.account.OverdraftPolicy = policy
+
+	return h
+}
+
+func Test_ProcessTransactionsStream_flags_overdraft(t *testing.T) {
+	t.Parallel()
+	h := overdraftSetup(t, domain.OverdraftFlag)
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, overdraftData, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("-22"), stats.Balance)
+
+	breaches := []service.OverdraftBreach{{
+		Line:              3,
+		FileTransactionID: "1",
+		Amount:            domain.MustParseAmount("-40"),
+		Balance:           domain.MustParseAmount("-25"),
+		Limit:             domain.MustParseAmount("20"),
+		Policy:            domain.OverdraftFlag,
+	}}
+	assert.Equal(t, breaches, stats.OverdraftBreaches)
+	h.notificationsRepository.AssertCalled(t, "Alert", service.OverdraftAlert{
+		AccountNumber: "123456",
+		Currency:      "USD",
+		Balance:       domain.MustParseAmount("-22"),
+		Breaches:      breaches,
+	})
+}
+
+func Test_ProcessTransactionsStream_rejects_overdraft_row(t *testing.T) {
+	t.Parallel()
+	h := overdraftSetup(t, domain.OverdraftRejectRow)
+
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, overdraftData, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.TransactionCount)
+	assert.Equal(t, domain.MustParseAmount("18"), stats.Balance)
+	assert.Equal(t, 1, stats.RejectedCount)
+	assert.Equal(t, "1,7/16,-40", stats.Rejected[0].Raw)
+	assert.Contains(t, stats.Rejected[0].Reason, "overdraft limit exceeded")
+	if assert.Len(t, stats.OverdraftBreaches, 1) {
+		assert.True(t, stats.OverdraftBreaches[0].Rejected)
+	}
+	h.notificationsRepository.AssertNumberOfCalls(t, "Alert", 1)
+}
+
+func Test_ProcessTransactionsStream_rejects_overdraft_file(t *testing.T) {
+	t.Parallel()
+	h := overdraftSetup(t, "")
+
+	_, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, overdraftData, service.DefaultCSVFormat()), service.ImportOptions{OnError: service.OnErrorSkip})
+	assert.ErrorIs(t, err, service.ErrOverdraftLimitExceeded)
+	assert.ErrorContains(t, err, "line 3")
+	h.notificationsRepository.AssertNotCalled(t, "Notify", mock.Anything)
+	h.notificationsRepository.AssertNotCalled(t, "Alert", mock.Anything)
+}
+
+func Test_ProcessTransactionsStream_lets_overdrawn_account_be_paid_back(t *testing.T) {
+	t.Parallel()
+	h := overdraftSetup(t, domain.OverdraftRejectFile)
+	h.account.Balance = domain.MustParseAmount("-30")
+
+	data := `Id,Date,Transaction
+0,7/15,+5
+1,7/16,+15
+2,7/17,-5
+`
+	stats, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.MustParseAmount("-15"), stats.Balance)
+	assert.Empty(t, stats.OverdraftBreaches)
+	h.notificationsRepository.AssertNotCalled(t, "Alert", mock.Anything)
+}
+
+func Test_ProcessTransactionsStream_posts_ledger_entries(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).RunAndReturn(func(_ context.Context, txns []*domain.Transaction) error {
+		for i, txn := range txns {
+			txn.ID = int64(i + 1)
+		}
+		return nil
+	}).Once()
+
+	var posted []*domain.JournalEntry
+	h.ledgerRepository.ExpectedCalls = nil
+	h.ledgerRepository.EXPECT().Post(mock.Anything, mock.AnythingOfType("[]*domain.JournalEntry")).RunAndReturn(func(_ context.Context, entries []*domain.JournalEntry) error {
+		posted = append(posted, entries...)
+		return nil
+	})
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,-10.3
+`
+	_, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+
+	if assert.Len(t, posted, 2) {
+		for i, entry := range posted {
+			assert.NoError(t, entry.Validate())
+			assert.Equal(t, int64(i+1), entry.TransactionID)
+			assert.Equal(t, int64(7), entry.BatchID)
+		}
+		assert.Equal(t, []domain.LedgerEntry{
+			{AccountID: 1, Amount: domain.MustParseAmount("-10.3"), Currency: "USD"},
+			{AccountID: 100, Amount: domain.MustParseAmount("10.3"), Currency: "USD"},
+		}, posted[1].Lines)
+	}
+}
+
+func Test_ProcessTransactionsStream_creates_clearing_account(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+	h.transactionRepository.EXPECT().BulkInsert(mock.Anything, mock.AnythingOfType("[]*domain.Transaction")).Return(nil).Once()
+	h.account.Currency = "EUR"
+
+	h.accountRepository.ExpectedCalls = slices.DeleteFunc(h.accountRepository.ExpectedCalls, func(c *mock.Call) bool {
+		return c.Method == "GetByAccountNumber" || c.Method == "Insert"
+	})
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "CLEARING-EUR").Return(nil, database.ErrDBNotFound)
+	h.accountRepository.EXPECT().Insert(mock.Anything, mock.AnythingOfType("*domain.Account")).RunAndReturn(func(_ context.Context, a *domain.Account) (*domain.Account, error) {
+		a.ID = 101
+		return a, nil
+	}).Once()
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+`
+	_, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.NoError(t, err)
+	h.accountRepository.AssertCalled(t, "Insert", mock.Anything, mock.MatchedBy(func(a *domain.Account) bool {
+		return a.AccountNumber == "CLEARING-EUR" && a.Kind == domain.AccountClearing && a.Currency == "EUR"
+	}))
+}
+
+func Test_ProcessTransactionsStream_refuses_clearing_accounts(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumberForUpdate(mock.Anything, "CLEARING-USD").Return(&domain.Account{
+		ID:            100,
+		AccountNumber: "CLEARING-USD",
+		Kind:          domain.AccountClearing,
+		Currency:      "USD",
+	}, nil)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+`
+	_, err := h.service.ProcessTransactionsStream(h.ctx, "CLEARING-USD", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+	assert.ErrorIs(t, err, service.ErrAccountInactive)
+	h.transactionRepository.AssertNotCalled(t, "BulkInsert", mock.Anything, mock.Anything)
+}
+
+func Test_ListTransactions(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "555").Return(nil, database.ErrDBNotFound)
+
+	page := &domain.TransactionPage{Transactions: []*domain.Transaction{{ID: 3}}}
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, domain.TransactionQuery{
+		AccountID:  1,
+		Sign:       domain.SignNegative,
+		BatchID:    7,
+		SortBy:     domain.SortByAmount,
+		Descending: true,
+		Limit:      domain.DefaultPageSize,
+	}).Return(page, nil).Once()
+
+	got, err := h.service.ListTransactions(h.ctx, "123456", domain.TransactionQuery{
+		Sign:       domain.SignNegative,
+		BatchID:    7,
+		SortBy:     domain.SortByAmount,
+		Descending: true,
+	})
+	assert.NoError(t, err)
+	assert.Same(t, page, got)
+
+	_, err = h.service.ListTransactions(h.ctx, "555", domain.TransactionQuery{})
+	assert.ErrorIs(t, err, service.ErrAccountNotFound)
+
+	_, err = h.service.ListTransactions(h.ctx, "123456", domain.TransactionQuery{Sign: "zero"})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+	h.transactionRepository.AssertNumberOfCalls(t, "ListByAccount", 1)
+}
+
+func Test_ProcessTransactionsStream_returns_row_errors(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.batchRepository.EXPECT().GetByContentHash(mock.Anything, int64(1), mock.AnythingOfType("string")).Return(nil, database.ErrDBNotFound)
+
+	data := `Id,Date,Transaction
+0,7/15,+60.5
+1,7/28,ten
+`
+
+	_, err := h.service.ProcessTransactionsStream(h.ctx, "123456", csvReader(t, data, service.DefaultCSVFormat()), service.ImportOptions{})
+
+	var rowErr *service.StatementRowError
+	if assert.ErrorAs(t, err, &rowErr) {
+		assert.Equal(t, 3, rowErr.Line)
+		assert.Equal(t, "1,7/28,ten", rowErr.Raw)
+	}
+	assert.ErrorIs(t, err, domain.ErrInvalidAmount)
+}
+
+func Test_Stats_adds_up_every_page(t *testing.T) {
+	t.Parallel()
+	h := testSetup(t)
+	h.accountRepository.EXPECT().GetByAccountNumber(mock.Anything, "123456").Return(h.account, nil)
+
+	txn := func(id int64, month time.Month, amount string) *domain.Transaction {
+		return &domain.Transaction{ID: id, Date: time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC), Amount: domain.MustParseAmount(amount), OriginalAmount: domain.MustParseAmount(amount), Currency: "USD"}
+	}
+	first := &domain.TransactionPage{Transactions: []*domain.Transaction{txn(1, 7, "60.5"), txn(2, 7, "-10.3")}}
+	first.Next = domain.CursorOf(first.Transactions[1])
+	second := &domain.TransactionPage{Transactions: []*domain.Transaction{txn(3, 8, "-20.46")}}
+
+	query := domain.TransactionQuery{AccountID: 1, BatchID: 7, SortBy: domain.SortByDate, Limit: domain.MaxPageSize}
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, query).Return(first, nil).Once()
+	query.After = first.Next
+	h.transactionRepository.EXPECT().ListByAccount(mock.Anything, query).Return(second, nil).Once()
+
+	stats, err := h.service.Stats(h.ctx, "123456", domain.TransactionQuery{BatchID: 7, SortBy: domain.SortByAmount, Descending: true, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), stats.BatchID)
+	assert.Equal(t, domain.MustParseAmount("10"), stats.Balance)
+	assert.Equal(t, domain.MustParseAmount("29.74"), stats.FileBalance)
+	assert.Equal(t, 3, stats.TransactionCount)
+	assert.Equal(t, [12]int{6: 2, 7: 1}, stats.TransactionsPerMonth)
+	assert.Equal(t, domain.MustParseAmount("-15.38"), stats.CreditAvg)
+	assert.Len(t, stats.RecentTransactions, 3)
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/ardanlabs/conf/v3"
 )
 
@@ -25,12 +27,71 @@ type NotificationsConfig struct {
 	Email EmailConfig
 }
 
+// HTTPConfig sets up the API run by the serve command.
+type HTTPConfig struct {
+	Address           string        `conf:"default:0.0.0.0:8080"`
+	ReadHeaderTimeout time.Duration `conf:"default:10s"`
+	ShutdownTimeout   time.Duration `conf:"default:30s,help:how long running requests are waited for on shutdown"`
+	MaxUploadSize     int64         `conf:"default:67108864,help:largest statement accepted, in bytes"`
+}
+
+// GRPCConfig sets up the service run by the serve-grpc command.
+type GRPCConfig struct {
+	Address         string        `conf:"default:0.0.0.0:9090"`
+	ShutdownTimeout time.Duration `conf:"default:30s,help:how long running calls are waited for on shutdown"`
+}
+
+// JobsConfig sets up the import jobs run by the run-import-jobs command.
+type JobsConfig struct {
+	Workers      int           `conf:"default:2,help:number of import jobs run at the same time"`
+	Lease        time.Duration `conf:"default:1m,help:how long a job stays with a worker that stopped before another one takes it"`
+	MaxAttempts  int           `conf:"default:3,help:number of times a job is started before it fails"`
+	PollInterval time.Duration `conf:"default:1s,help:how often idle workers look for jobs"`
+}
+
+// CSVConfig describes the layout of the CSV files to import.
+type CSVConfig struct {
+	Delimiter          string `conf:"default:comma,help:comma|semicolon|tab|pipe or a single character"`
+	IDColumn           string `conf:"default:Id"`
+	DateColumn         string `conf:"default:Date"`
+	AmountColumn       string `conf:"default:Transaction"`
+	AccountColumn      string `conf:"help:column with the account number of each row. Rows without one go to the account-number account"`
+	DescriptionColumn  string `conf:"help:optional column with the description of each row"`
+	CounterpartyColumn string `conf:"help:optional column with the name of the other party"`
+	ReferenceColumn    string `conf:"help:optional column with the external reference"`
+	TypeColumn         string `conf:"help:optional column with the transaction type"`
+	CurrencyColumn     string `conf:"help:optional column with the currency of each row. Rows without one are in the currency of the account"`
+	DateLayout         string `conf:"help:Go time layout of the date column. Detected when empty"`
+	DecimalSeparator   string `conf:"default:."`
+	SignConvention     string `conf:"default:signed,help:signed|inverted"`
+}
+
 type AppConfig struct {
 	conf.Version
-	DB            DBConfig
-	Notifications NotificationsConfig
-	AccountNumber string `conf:"default:123456"`
-	File          string `conf:"short:f"`
+	conf.Args
+	DB                    DBConfig
+	Notifications         NotificationsConfig
+	CSV                   CSVConfig
+	HTTP                  HTTPConfig
+	GRPC                  GRPCConfig
+	Jobs                  JobsConfig
+	AccountNumber         string `conf:"default:123456"`
+	Currency              string `conf:"default:USD,help:currency of the accounts created by an import"`
+	RejectUnknownAccounts bool   `conf:"help:reject rows of accounts that do not exist instead of creating them"`
+	OverdraftLimit        string `conf:"help:overdraft limit set by set-overdraft. Empty removes the limit"`
+	OverdraftPolicy       string `conf:"default:reject-file,help:reject-row|reject-file|flag, set by set-overdraft"`
+	File                  string `conf:"short:f"`
+	Format                string `conf:"default:auto,help:auto|csv|ofx|camt053|mt940"`
+	OnError               string `conf:"default:abort,help:abort|skip"`
+	DryRun                bool   `conf:"help:validate the file and print the projected changes without saving them"`
+	RejectedFile          string `conf:"help:where skipped rows are written as csv or json. Defaults to <file>.rejected.csv"`
+	RequireBalanceMatch   bool   `conf:"help:fail the import when the balance does not match the closing balance of the statement"`
+	Reason                string `conf:"help:why the transactions are reversed, required by reverse"`
+	ReversedBy            string `conf:"help:who reverses the transactions. Defaults to the USER of the system"`
+	Description           string `conf:"help:description of the journal entry posted by post-entry"`
+	Repair                bool   `conf:"help:make verify-balances store the recomputed balances"`
+	BatchSize             int    `conf:"default:1000,help:number of transactions stored per insert"`
+	Workers               int    `conf:"help:number of goroutines parsing rows. Defaults to one per CPU"`
 }
 
 func Parse(prefix string) (AppConfig, string, error) {
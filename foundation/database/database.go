@@ -18,10 +18,21 @@ import (
 // https://github.com/lib/pq/blob/master/error.go#L178
 const UniqueViolation = "23505"
 
+// DeadlockDetected error code, returned to the transaction Postgres aborts to
+// break a deadlock.
+const DeadlockDetected = "40P01"
+
+// MaxParams is the number of parameters Postgres accepts in one query.
+const MaxParams = 65535
+
+// maxLoggedQuery is the length queries are cut to in the logs.
+const maxLoggedQuery = 200
+
 // Set of error variables for CRUD operations.
 var (
 	ErrDBNotFound        = errors.New("not found")
 	ErrDBDuplicatedEntry = errors.New("duplicated entry")
+	ErrDBDeadlock        = errors.New("deadlock")
 )
 
 // Config is the required properties to use the database.
@@ -139,6 +150,11 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db Transactor, fn f
 		if ok := errors.As(err, &pqError); ok && pqError.Code == UniqueViolation {
 			return ErrDBDuplicatedEntry
 		}
+		// Checks if the error is of code 40P01 (deadlock_detected), so the
+		// caller can run the transaction again.
+		if ok := errors.As(err, &pqError); ok && pqError.Code == DeadlockDetected {
+			return ErrDBDeadlock
+		}
 		return fmt.Errorf("exec tran: %w", err)
 	}
 
@@ -197,6 +213,51 @@ func NamedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 	return nil
 }
 
+// QuerySlice is a helper function for executing queries with positional
+// arguments, like generated multi-row inserts, that return a collection of
+// data to be unmarshalled into a slice. Only the start of the query is logged,
+// as those can be long.
+func QuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, args []any, dest *[]T) error {
+	q := strings.Join(strings.Fields(query), " ")
+	if len(q) > maxLoggedQuery {
+		q = q[:maxLoggedQuery] + "..."
+	}
+	log.Infow("database.QuerySlice", "query", q, "args", len(args))
+
+	if err := sqlx.SelectContext(ctx, db, dest, query, args...); err != nil {
+		// Checks if the error is of code 23505 (unique_violation).
+		var pqError *pq.Error
+		if ok := errors.As(err, &pqError); ok && pqError.Code == UniqueViolation {
+			return ErrDBDuplicatedEntry
+		}
+		return fmt.Errorf("database error on slice query: %w", err)
+	}
+
+	return nil
+}
+
+// ExecContext is a helper function to execute a CUD operation with positional
+// arguments, like generated multi-row inserts. Only the start of the query is
+// logged, as in QuerySlice.
+func ExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, args []any) error {
+	q := strings.Join(strings.Fields(query), " ")
+	if len(q) > maxLoggedQuery {
+		q = q[:maxLoggedQuery] + "..."
+	}
+	log.Infow("database.ExecContext", "query", q, "args", len(args))
+
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		// Checks if the error is of code 23505 (unique_violation).
+		var pqError *pq.Error
+		if ok := errors.As(err, &pqError); ok && pqError.Code == UniqueViolation {
+			return ErrDBDuplicatedEntry
+		}
+		return fmt.Errorf("database error on exec: %w", err)
+	}
+
+	return nil
+}
+
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
 func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest any) error {
@@ -205,11 +266,19 @@ func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 
 	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
 	if err != nil {
+		// Checks if the error is of code 23505 (unique_violation).
+		var pqError *pq.Error
+		if ok := errors.As(err, &pqError); ok && pqError.Code == UniqueViolation {
+			return ErrDBDuplicatedEntry
+		}
 		return fmt.Errorf("database error on struct query: %w", err)
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("database error walking struct query rows: %w", err)
+		}
 		return ErrDBNotFound
 	}
 
@@ -231,9 +300,9 @@ func queryString(query string, args ...any) string {
 		var value string
 		switch v := param.(type) {
 		case string:
-			value = fmt.Sprintf("%q", v)
+			value = fmt.Sprintf("%q", loggedValue(v))
 		case []byte:
-			value = fmt.Sprintf("%q", string(v))
+			value = fmt.Sprintf("%q", loggedValue(string(v)))
 		default:
 			value = fmt.Sprintf("%v", v)
 		}
@@ -245,3 +314,12 @@ func queryString(query string, args ...any) string {
 
 	return strings.Trim(query, " ")
 }
+
+// loggedValue cuts long parameters, like the content of a statement, to the
+// length queries are logged with.
+func loggedValue(v string) string {
+	if len(v) > maxLoggedQuery {
+		return v[:maxLoggedQuery] + "..."
+	}
+	return v
+}
@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE FLOAT USING amount::FLOAT;
+
+ALTER TABLE accounts
+    ALTER COLUMN balance TYPE FLOAT USING balance::FLOAT;
@@ -0,0 +1,5 @@
+ALTER TABLE accounts
+    ALTER COLUMN balance TYPE NUMERIC(19,4) USING balance::NUMERIC(19,4);
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE NUMERIC(19,4) USING amount::NUMERIC(19,4);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS uq_transactions_account_row;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT IF EXISTS fk_batch,
+    DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS ingestion_batches;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS ingestion_batches (
+    id SERIAL,
+    account_id INT NOT NULL,
+    content_hash VARCHAR(64),
+    row_count INT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+        REFERENCES accounts(id),
+    CONSTRAINT uq_ingestion_batches_content
+      UNIQUE (account_id, content_hash)
+);
+
+ALTER TABLE transactions
+    ADD COLUMN batch_id INT,
+    ADD CONSTRAINT fk_batch
+      FOREIGN KEY(batch_id)
+        REFERENCES ingestion_batches(id);
+
+-- A row is imported once per account, whatever file it comes in. Rows imported
+-- before batches were tracked are left out, as they may repeat each other.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transactions_account_row
+    ON transactions (account_id, file_transaction_id)
+    WHERE batch_id IS NOT NULL;
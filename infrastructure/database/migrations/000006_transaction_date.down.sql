@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_transactions_account_date;
+
+ALTER TABLE transactions
+    ADD COLUMN transaction_day INT,
+    ADD COLUMN transaction_month INT;
+
+UPDATE transactions SET
+    transaction_day = EXTRACT(DAY FROM transaction_date)::INT,
+    transaction_month = EXTRACT(MONTH FROM transaction_date)::INT;
+
+ALTER TABLE transactions
+    ALTER COLUMN transaction_day SET NOT NULL,
+    ALTER COLUMN transaction_month SET NOT NULL,
+    DROP COLUMN transaction_date;
@@ -0,0 +1,30 @@
+ALTER TABLE transactions
+    ADD COLUMN transaction_date DATE;
+
+-- Rows imported before this migration only kept month and day. The year is
+-- taken from the processing timestamp, moving dates that would land after
+-- the import to the previous year. February 29 goes to the latest leap year
+-- not after the import, which is at most eight years back, taken as the day
+-- before March 1 so no February 29 is built for other years.
+UPDATE transactions SET transaction_date =
+    CASE
+        WHEN transaction_month = 2 AND transaction_day = 29
+        THEN (
+            SELECT make_date(y, 3, 1) - 1
+              FROM generate_series(EXTRACT(YEAR FROM processing_timestamp)::INT, EXTRACT(YEAR FROM processing_timestamp)::INT - 8, -1) y
+              WHERE (y % 4 = 0 AND y % 100 <> 0 OR y % 400 = 0)
+                AND make_date(y, 3, 1) - 1 <= processing_timestamp::DATE
+              ORDER BY y DESC
+              LIMIT 1
+        )
+        WHEN make_date(EXTRACT(YEAR FROM processing_timestamp)::INT, transaction_month, transaction_day) > processing_timestamp::DATE
+        THEN make_date(EXTRACT(YEAR FROM processing_timestamp)::INT - 1, transaction_month, transaction_day)
+        ELSE make_date(EXTRACT(YEAR FROM processing_timestamp)::INT, transaction_month, transaction_day)
+    END;
+
+ALTER TABLE transactions
+    ALTER COLUMN transaction_date SET NOT NULL,
+    DROP COLUMN transaction_month,
+    DROP COLUMN transaction_day;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_account_date ON transactions (account_id, transaction_date);
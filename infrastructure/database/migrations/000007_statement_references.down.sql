@@ -0,0 +1,4 @@
+-- Fails when non numeric file transaction ids were imported.
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS description,
+    ALTER COLUMN file_transaction_id TYPE INT USING file_transaction_id::INT;
@@ -0,0 +1,5 @@
+-- Bank statements identify transactions with alphanumeric ids like the OFX
+-- FITID, so the file transaction id is kept as text.
+ALTER TABLE transactions
+    ALTER COLUMN file_transaction_id TYPE VARCHAR USING file_transaction_id::VARCHAR,
+    ADD COLUMN description VARCHAR;
@@ -0,0 +1,3 @@
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS value_date;
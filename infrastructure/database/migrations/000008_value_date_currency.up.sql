@@ -0,0 +1,5 @@
+-- Bank statements report the value date and currency of every entry next to
+-- the booking date kept in transaction_date.
+ALTER TABLE transactions
+    ADD COLUMN value_date DATE,
+    ADD COLUMN currency VARCHAR(3);
@@ -0,0 +1,4 @@
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS type,
+    DROP COLUMN IF EXISTS reference,
+    DROP COLUMN IF EXISTS counterparty;
@@ -0,0 +1,6 @@
+-- Details reported by the bank next to the description, so a transaction can
+-- be told apart without going back to the file.
+ALTER TABLE transactions
+    ADD COLUMN counterparty VARCHAR,
+    ADD COLUMN reference VARCHAR,
+    ADD COLUMN type VARCHAR;
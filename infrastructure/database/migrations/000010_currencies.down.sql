@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS fx_rates;
+
+ALTER TABLE transactions
+    ALTER COLUMN currency DROP NOT NULL,
+    DROP COLUMN IF EXISTS fx_rate,
+    DROP COLUMN IF EXISTS original_amount;
+
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,31 @@
+-- Accounts opened before currencies were tracked are in US dollars.
+ALTER TABLE accounts
+    ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+-- amount is in the currency of the account, original_amount in the currency
+-- of the statement, converted at fx_rate.
+ALTER TABLE transactions
+    ADD COLUMN original_amount NUMERIC(19,4),
+    ADD COLUMN fx_rate NUMERIC(20,10);
+
+UPDATE transactions t SET
+    currency = COALESCE(t.currency, a.currency),
+    original_amount = t.amount,
+    fx_rate = 1
+    FROM accounts a
+    WHERE a.id = t.account_id;
+
+ALTER TABLE transactions
+    ALTER COLUMN currency SET NOT NULL,
+    ALTER COLUMN original_amount SET NOT NULL,
+    ALTER COLUMN fx_rate SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS fx_rates (
+    id SERIAL,
+    rate_date DATE NOT NULL,
+    base_currency VARCHAR(3) NOT NULL,
+    quote_currency VARCHAR(3) NOT NULL,
+    rate NUMERIC(20,10) NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uq_fx_rates_date_pair UNIQUE (base_currency, quote_currency, rate_date)
+);
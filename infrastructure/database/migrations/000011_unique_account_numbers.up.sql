@@ -0,0 +1,25 @@
+-- Imports racing each other could create the same account number twice.
+-- Transactions point at every copy, so they stay, but each copy after the
+-- first is renamed after its id before the constraint is added. Balances are
+-- not merged, as the copies may be in different currencies: every renamed
+-- account is reported so its transactions can be looked at.
+DO $$
+DECLARE
+    dup RECORD;
+BEGIN
+    FOR dup IN
+        SELECT id, account_number
+          FROM (
+            SELECT id, account_number, ROW_NUMBER() OVER (PARTITION BY account_number ORDER BY id) AS copy
+              FROM accounts
+          ) a
+          WHERE copy > 1
+          ORDER BY id
+    LOOP
+        UPDATE accounts SET account_number = dup.account_number || '-duplicate-' || dup.id WHERE id = dup.id;
+        RAISE WARNING 'account number % was used twice, account % renamed to %',
+            dup.account_number, dup.id, dup.account_number || '-duplicate-' || dup.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE accounts ADD CONSTRAINT accounts_account_number_key UNIQUE (account_number);
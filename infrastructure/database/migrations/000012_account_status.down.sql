@@ -0,0 +1,6 @@
+ALTER TABLE accounts
+    DROP CONSTRAINT IF EXISTS accounts_status_check,
+    DROP COLUMN IF EXISTS closed_at,
+    DROP COLUMN IF EXISTS frozen_at,
+    DROP COLUMN IF EXISTS opened_at,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+ALTER TABLE accounts
+    ADD COLUMN IF NOT EXISTS status VARCHAR NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS opened_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS frozen_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP,
+    ADD CONSTRAINT accounts_status_check CHECK (status IN ('active', 'frozen', 'closed'));
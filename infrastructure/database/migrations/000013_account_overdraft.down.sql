@@ -0,0 +1,5 @@
+ALTER TABLE accounts
+    DROP CONSTRAINT IF EXISTS accounts_overdraft_policy_check,
+    DROP CONSTRAINT IF EXISTS accounts_overdraft_limit_check,
+    DROP COLUMN IF EXISTS overdraft_policy,
+    DROP COLUMN IF EXISTS overdraft_limit;
@@ -0,0 +1,5 @@
+ALTER TABLE accounts
+    ADD COLUMN IF NOT EXISTS overdraft_limit NUMERIC(19,4),
+    ADD COLUMN IF NOT EXISTS overdraft_policy VARCHAR NOT NULL DEFAULT 'reject-file',
+    ADD CONSTRAINT accounts_overdraft_limit_check CHECK (overdraft_limit >= 0),
+    ADD CONSTRAINT accounts_overdraft_policy_check CHECK (overdraft_policy IN ('reject-row', 'reject-file', 'flag'));
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS journal_entries;
+
+DELETE FROM accounts WHERE kind = 'clearing';
+
+ALTER TABLE accounts
+    DROP CONSTRAINT IF EXISTS accounts_kind_check,
+    DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,58 @@
+ALTER TABLE accounts
+    ADD COLUMN IF NOT EXISTS kind VARCHAR NOT NULL DEFAULT 'customer',
+    ADD CONSTRAINT accounts_kind_check CHECK (kind IN ('customer', 'clearing'));
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL,
+    batch_id INT,
+    transaction_id INT,
+    description VARCHAR NOT NULL DEFAULT '',
+    posted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_batch
+      FOREIGN KEY(batch_id)
+        REFERENCES ingestion_batches(id),
+    CONSTRAINT fk_transaction
+      FOREIGN KEY(transaction_id)
+        REFERENCES transactions(id)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id SERIAL,
+    journal_entry_id INT NOT NULL,
+    account_id INT NOT NULL,
+    amount NUMERIC(19,4) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_journal_entry
+      FOREIGN KEY(journal_entry_id)
+        REFERENCES journal_entries(id),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+        REFERENCES accounts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries (account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_journal_entry ON ledger_entries (journal_entry_id);
+
+-- Every currency in use gets the clearing account on the other side of the
+-- imported transactions.
+INSERT INTO accounts (account_number, currency, balance, kind)
+    SELECT DISTINCT 'CLEARING-' || currency, currency, 0, 'clearing' FROM accounts
+    ON CONFLICT (account_number) DO NOTHING;
+
+-- Transactions imported before the ledger existed are posted now.
+INSERT INTO journal_entries (batch_id, transaction_id, description, posted_at)
+    SELECT batch_id, id, 'import', processing_timestamp FROM transactions ORDER BY id;
+
+INSERT INTO ledger_entries (journal_entry_id, account_id, amount, currency)
+    SELECT j.id, a.id, t.amount, a.currency
+      FROM journal_entries j
+      JOIN transactions t ON t.id = j.transaction_id
+      JOIN accounts a ON a.id = t.account_id
+    UNION ALL
+    SELECT j.id, c.id, -t.amount, a.currency
+      FROM journal_entries j
+      JOIN transactions t ON t.id = j.transaction_id
+      JOIN accounts a ON a.id = t.account_id
+      JOIN accounts c ON c.account_number = 'CLEARING-' || a.currency;
@@ -0,0 +1,18 @@
+DROP TRIGGER IF EXISTS transactions_no_delete ON transactions;
+DROP FUNCTION IF EXISTS forbid_transactions_delete();
+
+DROP INDEX IF EXISTS uq_transactions_account_row;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS reversed,
+    DROP CONSTRAINT IF EXISTS uq_transactions_reverses,
+    DROP CONSTRAINT IF EXISTS fk_reverses_transaction,
+    DROP CONSTRAINT IF EXISTS fk_reversal,
+    DROP COLUMN IF EXISTS reverses_transaction_id,
+    DROP COLUMN IF EXISTS reversal_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transactions_account_row
+    ON transactions (account_id, file_transaction_id)
+    WHERE batch_id IS NOT NULL;
+
+DROP TABLE IF EXISTS reversals;
@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS reversals (
+    id SERIAL,
+    batch_id INT,
+    transaction_id INT,
+    reason VARCHAR NOT NULL,
+    reversed_by VARCHAR NOT NULL,
+    reversed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_batch
+      FOREIGN KEY(batch_id)
+        REFERENCES ingestion_batches(id),
+    CONSTRAINT fk_transaction
+      FOREIGN KEY(transaction_id)
+        REFERENCES transactions(id),
+    CONSTRAINT reversals_target_check
+      CHECK ((batch_id IS NULL) <> (transaction_id IS NULL))
+);
+
+-- A transaction is offset at most once.
+ALTER TABLE transactions
+    ADD COLUMN reversal_id INT,
+    ADD COLUMN reverses_transaction_id INT,
+    ADD CONSTRAINT fk_reversal
+      FOREIGN KEY(reversal_id)
+        REFERENCES reversals(id),
+    ADD CONSTRAINT fk_reverses_transaction
+      FOREIGN KEY(reverses_transaction_id)
+        REFERENCES transactions(id),
+    ADD CONSTRAINT uq_transactions_reverses
+      UNIQUE (reverses_transaction_id);
+
+-- Reversed transactions are flagged, so the rows they imported can be imported
+-- again.
+ALTER TABLE transactions
+    ADD COLUMN reversed BOOLEAN NOT NULL DEFAULT false;
+
+-- Offsetting transactions keep the file id of the one they reverse, so they
+-- are left out of the rows imported once per account, and so are reversed
+-- transactions, whose rows can be imported again.
+DROP INDEX IF EXISTS uq_transactions_account_row;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transactions_account_row
+    ON transactions (account_id, file_transaction_id)
+    WHERE batch_id IS NOT NULL AND reverses_transaction_id IS NULL AND NOT reversed;
+
+-- Transactions are corrected by reversing them, never by deleting them.
+CREATE OR REPLACE FUNCTION forbid_transactions_delete() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'transactions can not be deleted, reverse them instead';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_no_delete
+    BEFORE DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION forbid_transactions_delete();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_import_jobs_pending;
+DROP TABLE IF EXISTS import_jobs;
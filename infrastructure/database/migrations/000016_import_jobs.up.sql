@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id SERIAL,
+    account_number VARCHAR NOT NULL,
+    format VARCHAR NOT NULL,
+    on_error VARCHAR NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    require_balance_match BOOLEAN NOT NULL DEFAULT false,
+    currency VARCHAR NOT NULL DEFAULT '',
+    content BYTEA,
+    status VARCHAR NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    lease_expires_at TIMESTAMP,
+    rows_processed INT NOT NULL DEFAULT 0,
+    rows_rejected INT NOT NULL DEFAULT 0,
+    stats JSONB,
+    error VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    PRIMARY KEY (id),
+    CONSTRAINT import_jobs_status_check
+      CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+-- Workers look for the jobs waiting, or left running by a worker that stopped.
+CREATE INDEX IF NOT EXISTS idx_import_jobs_pending ON import_jobs (id) WHERE status IN ('queued', 'running');
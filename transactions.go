@@ -3,13 +3,27 @@ package main
 //go:generate go run github.com/vektra/mockery/v2@v2.42.1 --inpackage --with-expecter --all --dir ./business
 
 import (
-	"bufio"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fedepezzola/transactions/adapters/repositories"
+	"github.com/fedepezzola/transactions/adapters/rest"
+	"github.com/fedepezzola/transactions/adapters/rpc"
+	"github.com/fedepezzola/transactions/business/domain"
 	"github.com/fedepezzola/transactions/business/service"
 	"github.com/fedepezzola/transactions/infrastructure/notifications/email"
 
@@ -26,6 +40,11 @@ func main() {
 }
 
 func mainWithExitCode() int {
+	// Stop the import on Ctrl+C or a termination request. Nothing is saved,
+	// as the whole file is rolled back.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Construct the application logger.
 	log, err := logger.New("TRANSACTIONS")
 	if err != nil {
@@ -76,7 +95,41 @@ func mainWithExitCode() int {
 	}
 
 	// Perform the startup and shutdown sequence.
-	if err := processFile(cfg.AccountNumber, cfg, log, db, file); err != nil {
+	run := processFile
+	switch cfg.Args.Num(0) {
+	case "", "import":
+	case "load-fx-rates":
+		run = loadFXRates
+	case "check-ledger":
+		run = checkLedger
+	case "post-entry":
+		run = postEntry
+	case "verify-balances":
+		run = verifyBalances
+	case "reverse":
+		run = reverse
+	case "serve":
+		run = serve
+	case "serve-grpc":
+		run = serveGRPC
+	case "submit-import":
+		run = submitImport
+	case "import-job":
+		run = importJob
+	case "run-import-jobs":
+		run = runImportJobs
+	case "open-account", "freeze-account", "unfreeze-account", "close-account", "set-overdraft":
+		run = changeAccount
+	default:
+		fmt.Println(help)
+		log.Errorw("unknown command", "command", cfg.Args.Num(0))
+		return 1
+	}
+	accountNumber := cfg.AccountNumber
+	if cfg.Args.Num(1) != "" {
+		accountNumber = cfg.Args.Num(1)
+	}
+	if err := run(ctx, accountNumber, cfg, log, db, file); err != nil {
 		log.Errorw("Fatal", "ERROR", err)
 		if err := log.Sync(); err != nil {
 			fmt.Println(err)
@@ -86,37 +139,613 @@ func mainWithExitCode() int {
 	return 0
 }
 
-func processFile(accountNumber string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, file *os.File) error {
-
-	ctx := context.TODO()
-
+func processFile(ctx context.Context, accountNumber string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, file *os.File) error {
+	postgresTransactor := repositories.NewPostgresTransactor(log, db)
 	postgresAccount := repositories.NewPostgresAccountRepository(log, db)
 	postgresTransaction := repositories.NewPostgresTransactionRepository(log, db)
 
 	emailNotification := email.NewEmailNotificationListener(cfg.Notifications.Email, log)
 	notificationsRepository := repositories.NewNotificationsRepository(log, []repositories.NotificationsListener{emailNotification})
 
-	transactionService := service.NewTransactionService(log, postgresAccount, postgresTransaction, notificationsRepository)
+	transactionService := service.NewTransactionService(log, postgresTransactor, postgresAccount, postgresTransaction, notificationsRepository)
 
-	scanner := bufio.NewScanner(file)
+	format, err := csvFormat(cfg.CSV)
+	if err != nil {
+		return fmt.Errorf("invalid csv configuration: %w", err)
+	}
 
-	// Discard titles form first line
-	scanner.Scan()
+	reader, err := service.NewStatementReader(file, cfg.Format, format)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
 
-	stats, err := transactionService.ProcessTransactionsStream(ctx, accountNumber, scanner)
+	opts := service.ImportOptions{
+		Currency:              cfg.Currency,
+		RejectUnknownAccounts: cfg.RejectUnknownAccounts,
+		OnError:               cfg.OnError,
+		DryRun:                cfg.DryRun,
+		RequireBalanceMatch:   cfg.RequireBalanceMatch,
+		BatchSize:             cfg.BatchSize,
+		Workers:               cfg.Workers,
+	}
+
+	var summary *service.ImportSummary
+	if format.AccountColumn != "" {
+		summary, err = transactionService.ProcessStatement(ctx, accountNumber, reader, opts)
+	} else {
+		var stats *service.AccountStats
+		stats, err = transactionService.ProcessTransactionsStream(ctx, accountNumber, reader, opts)
+		if err == nil {
+			summary = &service.ImportSummary{
+				Accounts:         []*service.AccountStats{stats},
+				TransactionCount: stats.TransactionCount,
+				FileBalance:      stats.FileBalance,
+				RejectedCount:    stats.RejectedCount,
+				Rejected:         stats.Rejected,
+				DryRun:           stats.DryRun,
+			}
+		}
+	}
 	if err != nil {
+		if errors.Is(err, service.ErrFileAlreadyImported) {
+			log.Warnw("skipping file", "reason", err)
+			fmt.Println("File was already imported, nothing to do")
+			return nil
+		}
 		return fmt.Errorf("error processing: %w", err)
 	}
 
+	if summary.DryRun {
+		fmt.Println("Dry run, nothing was saved")
+	}
+	for _, stats := range summary.Accounts {
+		printStats(stats, len(summary.Accounts) > 1)
+	}
+	if len(summary.Accounts) > 1 {
+		fmt.Println("Accounts in file: ", len(summary.Accounts))
+		fmt.Println("Transactions in file: ", summary.TransactionCount)
+		fmt.Println("Balance for the processed transactions is ", summary.FileBalance)
+	}
+	if summary.RejectedCount > 0 {
+		path := rejectedFilePath(cfg)
+		if err := writeRejectedRows(path, summary.Rejected); err != nil {
+			return fmt.Errorf("error writing rejected rows: %w", err)
+		}
+		fmt.Println("Rejected rows: ", summary.RejectedCount, "written to", path)
+	}
+
+	return nil
+}
+
+// loadFXRates stores the exchange rates of a date,base,quote,rate CSV file.
+func loadFXRates(ctx context.Context, _ string, _ config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, file *os.File) error {
+	fxRateService := service.NewFXRateService(log, repositories.NewPostgresTransactor(log, db))
+
+	count, err := fxRateService.LoadRates(ctx, file)
+	if err != nil {
+		return fmt.Errorf("error loading fx rates: %w", err)
+	}
+
+	fmt.Println("FX rates loaded: ", count)
+
+	return nil
+}
+
+// checkLedger prints the ledger totals of every currency and fails when the
+// ledger is not balanced.
+func checkLedger(ctx context.Context, _ string, _ config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	ledgerService := service.NewLedgerService(log, repositories.NewPostgresTransactor(log, db))
+
+	report, err := ledgerService.Check(ctx)
+	if report != nil {
+		for _, total := range report.Totals {
+			fmt.Println("Ledger ", total.Currency, "entries", total.Entries, "total", total.Total)
+		}
+		if len(report.UnbalancedEntries) > 0 {
+			fmt.Println("Unbalanced journal entries: ", report.UnbalancedEntries)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error checking ledger: %w", err)
+	}
+
+	fmt.Println("Ledger is balanced")
+
+	return nil
+}
+
+// postEntry posts a journal entry by hand, with one ACCOUNT=AMOUNT argument
+// per line, as in "post-entry 123456=-2.50 CLEARING-USD=2.50".
+func postEntry(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	ledgerService := service.NewLedgerService(log, repositories.NewPostgresTransactor(log, db))
+
+	if cfg.Description == "" {
+		return errors.New("a description of the journal entry is required")
+	}
+
+	var postings []service.Posting
+	for _, arg := range cfg.Args[1:] {
+		accountNumber, amount, ok := strings.Cut(arg, "=")
+		if !ok || accountNumber == "" {
+			return fmt.Errorf("invalid posting %q, expected ACCOUNT=AMOUNT", arg)
+		}
+		a, err := domain.ParseAmount(amount)
+		if err != nil {
+			return fmt.Errorf("invalid amount of posting %q: %w", arg, err)
+		}
+		postings = append(postings, service.Posting{AccountNumber: accountNumber, Amount: a})
+	}
+
+	entry, err := ledgerService.Post(ctx, cfg.Description, postings)
+	if err != nil {
+		return fmt.Errorf("error posting journal entry: %w", err)
+	}
+
+	fmt.Println("Journal entry ", entry.ID, "posted with", len(entry.Lines), "lines")
+
+	return nil
+}
+
+// verifyBalances compares the stored balances with the ones recomputed from
+// the transactions, storing the recomputed ones with --repair.
+func verifyBalances(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	accountService := service.NewAccountService(log, repositories.NewPostgresTransactor(log, db))
+
+	report, err := accountService.VerifyBalances(ctx, cfg.Repair)
+	if report != nil {
+		for _, b := range report.Mismatches {
+			fmt.Println("Account ", b.AccountNumber, "balance", b.Stored, "expected", b.Expected(), b.Currency, "difference", b.Difference())
+		}
+		fmt.Println("Accounts checked: ", report.Checked)
+		if report.Repaired && len(report.Mismatches) > 0 {
+			fmt.Println("Accounts repaired: ", len(report.Mismatches))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error verifying balances: %w", err)
+	}
+
+	return nil
+}
+
+// reverse offsets a transaction or a whole ingestion batch, as in
+// "reverse transaction 42" or "reverse batch 7".
+func reverse(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	reversalService := service.NewReversalService(log, repositories.NewPostgresTransactor(log, db))
+
+	id, err := strconv.ParseInt(cfg.Args.Num(2), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %q: %w", cfg.Args.Num(2), err)
+	}
+	reversedBy := cfg.ReversedBy
+	if reversedBy == "" {
+		reversedBy = os.Getenv("USER")
+	}
+
+	var reversal *domain.Reversal
+	switch cfg.Args.Num(1) {
+	case "transaction":
+		reversal, err = reversalService.ReverseTransaction(ctx, id, reversedBy, cfg.Reason)
+	case "batch":
+		reversal, err = reversalService.ReverseBatch(ctx, id, reversedBy, cfg.Reason)
+	default:
+		return fmt.Errorf("unknown reverse target %q, expected transaction or batch", cfg.Args.Num(1))
+	}
+	if err != nil {
+		return fmt.Errorf("error reversing: %w", err)
+	}
+
+	fmt.Println("Reversal ", reversal.ID, "by", reversal.ReversedBy, "offset", len(reversal.Transactions), "transactions")
+
+	return nil
+}
+
+// changeAccount opens an account, or changes its status or overdraft, as told
+// by the command.
+func changeAccount(ctx context.Context, accountNumber string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	accountService := service.NewAccountService(log, repositories.NewPostgresTransactor(log, db))
+
+	var account *domain.Account
+	var err error
+	switch cfg.Args.Num(0) {
+	case "open-account":
+		account, err = accountService.Open(ctx, accountNumber, cfg.Currency)
+	case "freeze-account":
+		account, err = accountService.Freeze(ctx, accountNumber)
+	case "unfreeze-account":
+		account, err = accountService.Unfreeze(ctx, accountNumber)
+	case "close-account":
+		account, err = accountService.Close(ctx, accountNumber)
+	case "set-overdraft":
+		var limit *domain.Amount
+		if cfg.OverdraftLimit != "" {
+			l, err := domain.ParseAmount(cfg.OverdraftLimit)
+			if err != nil {
+				return fmt.Errorf("invalid overdraft limit: %w", err)
+			}
+			limit = &l
+		}
+		account, err = accountService.SetOverdraft(ctx, accountNumber, limit, domain.OverdraftPolicy(cfg.OverdraftPolicy))
+	}
+	if err != nil {
+		return fmt.Errorf("error changing account: %w", err)
+	}
+
+	fmt.Println("Account ", account.AccountNumber, "is", account.Status)
+	if account.OverdraftLimit != nil {
+		fmt.Println("Overdraft limit: ", *account.OverdraftLimit, account.OverdraftPolicy)
+	}
+
+	return nil
+}
+
+// serve runs the HTTP API until the program is stopped, then waits for the
+// requests being served.
+func serve(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	format, err := csvFormat(cfg.CSV)
+	if err != nil {
+		return fmt.Errorf("invalid csv configuration: %w", err)
+	}
+
+	transactionService, accountService := newServices(cfg, log, db)
+	handler := rest.NewHandler(log, transactionService, accountService, rest.Config{
+		CSVFormat:     format,
+		Import:        importDefaults(cfg),
+		MaxUploadSize: cfg.HTTP.MaxUploadSize,
+	})
+
+	server := &http.Server{
+		Addr:              cfg.HTTP.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		log.Infow("startup", "status", "api started", "address", server.Addr)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErrors:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+		log.Infow("shutdown", "status", "stopping api", "timeout", cfg.HTTP.ShutdownTimeout)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+			return fmt.Errorf("could not stop the api gracefully: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// serveGRPC runs the gRPC service until the program is stopped, then waits
+// for the calls being served.
+func serveGRPC(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	transactionService, accountService := newServices(cfg, log, db)
+	server := rpc.NewServer(log, transactionService, accountService, rpc.Config{
+		Import: importDefaults(cfg),
+	})
+
+	listener, err := net.Listen("tcp", cfg.GRPC.Address)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.GRPC.Address, err)
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		log.Infow("startup", "status", "grpc service started", "address", listener.Addr())
+		serverErrors <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serverErrors:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+		log.Infow("shutdown", "status", "stopping grpc service", "timeout", cfg.GRPC.ShutdownTimeout)
+
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(cfg.GRPC.ShutdownTimeout):
+			server.Stop()
+			return errors.New("could not stop the grpc service gracefully")
+		}
+	}
+
+	return nil
+}
+
+// submitImport queues the file to be imported into the account by the
+// workers of run-import-jobs, and prints the id of the job.
+func submitImport(ctx context.Context, accountNumber string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, file *os.File) error {
+	jobService, err := newImportJobService(cfg, log, db)
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	job, err := jobService.Submit(ctx, &domain.ImportJob{
+		AccountNumber:       accountNumber,
+		Format:              cfg.Format,
+		OnError:             cfg.OnError,
+		DryRun:              cfg.DryRun,
+		RequireBalanceMatch: cfg.RequireBalanceMatch,
+		Currency:            cfg.Currency,
+		Content:             content,
+	})
+	if err != nil {
+		return fmt.Errorf("error submitting import: %w", err)
+	}
+
+	fmt.Println("Import job ", job.ID, "queued for account", job.AccountNumber)
+
+	return nil
+}
+
+// importJob prints the status of an import job, as in "import-job 12", with
+// the stats of the import once it succeeded.
+func importJob(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	jobService, err := newImportJobService(cfg, log, db)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(cfg.Args.Num(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %q: %w", cfg.Args.Num(1), err)
+	}
+
+	job, err := jobService.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error reading import job: %w", err)
+	}
+
+	fmt.Println("Import job ", job.ID, "for account", job.AccountNumber, "is", job.Status)
+	if job.Attempts > 0 {
+		fmt.Println("Attempts: ", job.Attempts)
+	}
+	fmt.Println("Rows processed: ", job.RowsProcessed, "rejected", job.RowsRejected)
+	if job.Error != "" {
+		fmt.Println("Error: ", job.Error)
+	}
+
+	stats, err := service.ImportJobStats(job)
+	if err != nil {
+		return err
+	}
+	if stats != nil {
+		if stats.DryRun {
+			fmt.Println("Dry run, nothing was saved")
+		}
+		printStats(stats, false)
+	}
+
+	return nil
+}
+
+// runImportJobs runs the workers importing the queued jobs until the program
+// is stopped. The jobs being imported then are rolled back and queued again.
+func runImportJobs(ctx context.Context, _ string, cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB, _ *os.File) error {
+	jobService, err := newImportJobService(cfg, log, db)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("startup", "status", "import workers started", "workers", cfg.Jobs.Workers)
+	jobService.Run(ctx, cfg.Jobs.Workers)
+	log.Infow("shutdown", "status", "import workers stopped")
+
+	return nil
+}
+
+// newImportJobService builds the service queueing and running import jobs,
+// which are imported with the options and CSV mapping of the configuration.
+func newImportJobService(cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB) (*service.ImportJobService, error) {
+	format, err := csvFormat(cfg.CSV)
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv configuration: %w", err)
+	}
+
+	transactionService, _ := newServices(cfg, log, db)
+	return service.NewImportJobService(log, repositories.NewPostgresImportJobRepository(log, db), transactionService, service.ImportJobConfig{
+		CSVFormat:    format,
+		Import:       importDefaults(cfg),
+		Lease:        cfg.Jobs.Lease,
+		MaxAttempts:  cfg.Jobs.MaxAttempts,
+		PollInterval: cfg.Jobs.PollInterval,
+	}), nil
+}
+
+// newServices builds the services used by the servers, sending the
+// notifications of their imports by email.
+func newServices(cfg config.AppConfig, log *zap.SugaredLogger, db *sqlx.DB) (*service.TransactionService, *service.AccountService) {
+	postgresTransactor := repositories.NewPostgresTransactor(log, db)
+
+	emailNotification := email.NewEmailNotificationListener(cfg.Notifications.Email, log)
+	notificationsRepository := repositories.NewNotificationsRepository(log, []repositories.NotificationsListener{emailNotification})
+
+	transactionService := service.NewTransactionService(log, postgresTransactor,
+		repositories.NewPostgresAccountRepository(log, db),
+		repositories.NewPostgresTransactionRepository(log, db),
+		notificationsRepository,
+	)
+	return transactionService, service.NewAccountService(log, postgresTransactor)
+}
+
+// importDefaults are the options of the imports run by the servers, which
+// their clients can change one by one.
+func importDefaults(cfg config.AppConfig) service.ImportOptions {
+	return service.ImportOptions{
+		Currency:              cfg.Currency,
+		RejectUnknownAccounts: cfg.RejectUnknownAccounts,
+		OnError:               cfg.OnError,
+		RequireBalanceMatch:   cfg.RequireBalanceMatch,
+		BatchSize:             cfg.BatchSize,
+		Workers:               cfg.Workers,
+	}
+}
+
+// printStats prints the stats of one account to stdout.
+func printStats(stats *service.AccountStats, withAccount bool) {
+	if withAccount {
+		fmt.Println("Account ", stats.AccountNumber)
+	}
+	if stats.DryRun {
+		fmt.Println("Projected balance change is ", stats.FileBalance)
+	}
+	if stats.Currency != "" {
+		fmt.Println("Account currency is ", stats.Currency)
+	}
 	fmt.Println("Total balance is ", stats.Balance)
 	fmt.Println("Balance for the processed transactions is ", stats.FileBalance)
 	fmt.Println("Average Debit amount: ", stats.DebitAvg)
 	fmt.Println("Average Credit amount: ", stats.CreditAvg)
+	if stats.DuplicatesSkipped > 0 {
+		fmt.Println("Duplicated transactions skipped: ", stats.DuplicatesSkipped, stats.DuplicateIDs)
+	}
+	for _, breach := range stats.OverdraftBreaches {
+		action := "imported"
+		if breach.Rejected {
+			action = "rejected"
+		}
+		fmt.Println("Overdraft limit exceeded at line ", breach.Line, "balance", breach.Balance, "limit", breach.Limit, action)
+	}
+	if stats.StatementOpeningBalance != nil {
+		fmt.Println("Opening balance reported by the bank: ", *stats.StatementOpeningBalance)
+	}
+	if stats.StatementBalance != nil {
+		fmt.Println("Balance reported by the bank: ", *stats.StatementBalance)
+		if !stats.Reconciled {
+			fmt.Println("Balance does not match the bank, difference: ", stats.BalanceDifference)
+		}
+	}
+	currencies := make([]string, 0, len(stats.CurrencyTotals))
+	for currency := range stats.CurrencyTotals {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	for _, currency := range currencies {
+		if currency == stats.Currency {
+			continue
+		}
+		total := stats.CurrencyTotals[currency]
+		fmt.Println("Transactions in ", currency, ": ", total.Count, "for", total.Total, currency, "converted to", total.Converted, stats.Currency)
+	}
 	for i, v := range stats.TransactionsPerMonth {
 		if v > 0 {
 			fmt.Println("Number of transactions in ", time.Month(i+1), ": ", v)
 		}
 	}
+}
 
-	return nil
+// csvFormat maps the CSV configuration to the format understood by the
+// service.
+func csvFormat(cfg config.CSVConfig) (service.CSVFormat, error) {
+	delimiters := map[string]rune{
+		"comma":     ',',
+		"semicolon": ';',
+		"tab":       '\t',
+		"pipe":      '|',
+	}
+
+	delimiter, ok := delimiters[strings.ToLower(cfg.Delimiter)]
+	if !ok {
+		r := []rune(cfg.Delimiter)
+		if len(r) != 1 {
+			return service.CSVFormat{}, fmt.Errorf("delimiter must be a single character, got %q", cfg.Delimiter)
+		}
+		delimiter = r[0]
+	}
+
+	decimal := []rune(cfg.DecimalSeparator)
+	if len(decimal) != 1 || (decimal[0] != '.' && decimal[0] != ',') {
+		return service.CSVFormat{}, fmt.Errorf("decimal separator must be '.' or ',', got %q", cfg.DecimalSeparator)
+	}
+	if decimal[0] == delimiter {
+		return service.CSVFormat{}, fmt.Errorf("decimal separator and delimiter can not be both %q", delimiter)
+	}
+
+	return service.CSVFormat{
+		Delimiter:          delimiter,
+		IDColumn:           cfg.IDColumn,
+		DateColumn:         cfg.DateColumn,
+		AmountColumn:       cfg.AmountColumn,
+		AccountColumn:      cfg.AccountColumn,
+		DescriptionColumn:  cfg.DescriptionColumn,
+		CounterpartyColumn: cfg.CounterpartyColumn,
+		ReferenceColumn:    cfg.ReferenceColumn,
+		TypeColumn:         cfg.TypeColumn,
+		CurrencyColumn:     cfg.CurrencyColumn,
+		DateLayout:         cfg.DateLayout,
+		DecimalSeparator:   decimal[0],
+		SignConvention:     cfg.SignConvention,
+	}, nil
+}
+
+// rejectedFilePath returns where the rows skipped by an import are written.
+func rejectedFilePath(cfg config.AppConfig) string {
+	switch {
+	case cfg.RejectedFile != "":
+		return cfg.RejectedFile
+	case cfg.File != "":
+		return cfg.File + ".rejected.csv"
+	default:
+		return "rejected.csv"
+	}
+}
+
+// writeRejectedRows writes the rows skipped by an import to path, as JSON when
+// the file name ends in .json and as CSV otherwise.
+func writeRejectedRows(path string, rows []service.RejectedRow) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		type rejectedRow struct {
+			Line   int    `json:"line"`
+			Raw    string `json:"raw"`
+			Reason string `json:"reason"`
+		}
+		out := make([]rejectedRow, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, rejectedRow(r))
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		return f.Close()
+	}
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"line", "reason", "raw"}); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	for _, r := range rows {
+		if err := w.Write([]string{strconv.Itoa(r.Line), r.Reason, r.Raw}); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
 }